@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Asc2Html(args []string) {
+	fs := flag.NewFlagSet("asc2html", flag.ExitOnError)
+
+	options := asctools.DefaultHTMLViewerOptions()
+
+	fs.StringVar(&options.Title, "title", options.Title, "Title of the page")
+	fs.IntVar(&options.MaxSize, "max_size", options.MaxSize, "Number of cells on the longer side of the embedded grid; larger maps are downscaled")
+	fs.Float64Var(&options.Exaggeration, "exaggeration", options.Exaggeration, "Initial vertical exaggeration, between 0.1 and 100")
+
+	var rampVal string
+	fs.StringVar(&rampVal, "ramp", "terrain", "Initial color ramp: 'gray', 'terrain' or 'viridis'")
+
+	fs.BoolVar(&options.Hillshade, "hillshade", options.Hillshade, "Shade the terrain initially")
+
+	fs.Parse(args)
+
+	ramp, err := asctools.ParseColorRamp(rampVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Ramp = ramp
+
+	elevationMap, err := asctools.ParseASCFile(bufio.NewReader(os.Stdin))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = elevationMap.WriteHTMLViewer(bufio.NewWriter(os.Stdout), options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing HTML: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Asc2Mesh(args []string) {
+	fs := flag.NewFlagSet("asc2mesh", flag.ExitOnError)
+
+	meshFlags := addMeshFlags(fs, false)
+
+	var output string
+	fs.StringVar(&output, "output", "", "Output file, the format is taken from its extension unless -format is set (required for obj, which writes .mtl and .png next to it)")
+
+	var formatVal string
+	fs.StringVar(&formatVal, "format", "", "Output format: 'stl', 'stl_ascii', 'obj', 'ply', '3mf', 'gltf' or 'glb'")
+
+	var rampVal string
+	fs.StringVar(&rampVal, "ramp", "gray", "Color ramp of the texture and vertex colors: 'gray', 'terrain' or 'viridis'")
+
+	fs.Parse(args)
+
+	if output == "" && formatVal == "" {
+		fmt.Fprintln(os.Stderr, "Error: -output or -format is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var format asctools.MeshFormat
+	var err error
+	if formatVal != "" {
+		format, err = asctools.ParseMeshFormat(formatVal)
+	} else {
+		format, err = asctools.MeshFormatFromPath(output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ramp, err := asctools.ParseColorRamp(rampVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	elevationMap, err := asctools.ParseASCFile(reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+
+	options, err := meshFlags.options(elevationMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	mesh, err := elevationMap.BuildMesh(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building mesh: %v\n", err)
+		os.Exit(1)
+	}
+	texture := elevationMap.RenderImage(asctools.ScaleNone, 1, ramp)
+
+	if output == "" {
+		err = mesh.Write(bufio.NewWriter(os.Stdout), format, texture)
+	} else {
+		err = asctools.WriteMeshFile(output, format, mesh, texture)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing mesh: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -18,6 +18,20 @@ func Asc2Png(args []string) {
 	var scalingOperationVal string
 	fs.StringVar(&scalingOperationVal, "scaling_operation", "none", "Scaling operation: 'up' to scale up, 'down' to downscale")
 
+	var rampVal string
+	fs.StringVar(&rampVal, "ramp", "gray", "Color ramp: 'gray' (16 bit grayscale), 'terrain' or 'viridis'")
+
+	var encodingVal string
+	fs.StringVar(&encodingVal, "encoding", "none", "Elevation encoding: 'none' (colour ramp), 'terrain-rgb' or 'terrarium'")
+
+	var output string
+	fs.StringVar(&output, "output", "", "Output PNG file, written with a .pgw world file next to it (default: stdout)")
+
+	var auxXML bool
+	fs.BoolVar(&auxXML, "aux_xml", false, "Also write a GDAL .aux.xml file with the georeference and value encoding next to -output")
+
+	crs := addCRSFlag(fs, "CRS of the map as an EPSG code, e.g. 'EPSG:2180', or WKT, written to a .prj file next to -output")
+
 	fs.Parse(args)
 
 	if scale < 1 {
@@ -32,22 +46,59 @@ func Asc2Png(args []string) {
 		os.Exit(1)
 	}
 
-	var scalingOperation asctools.ScalingOperation
-	switch scalingOperationVal {
-	case "up":
-		scalingOperation = asctools.ScaleUp
-	case "down":
-		scalingOperation = asctools.ScaleDown
-	case "none":
-		scalingOperation = asctools.ScaleNone
-	default:
-		scalingOperation = asctools.ScaleNone
+	scalingOperation, err := asctools.ParseScalingOperation(scalingOperationVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ramp, err := asctools.ParseColorRamp(rampVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoding, err := asctools.ParseElevationEncoding(encodingVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if encoding != asctools.EncodingNone && scalingOperation != asctools.ScaleNone {
+		fmt.Fprintln(os.Stderr, "Error: scaling is not supported with an elevation encoding")
+		os.Exit(1)
+	}
+
+	if output == "" && auxXML {
+		fmt.Fprintln(os.Stderr, "Error: -aux_xml requires -output")
+		os.Exit(1)
+	}
+	crs.apply(elevationMap)
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
 	}
 
-	err = elevationMap.WritePNG(bufio.NewWriter(os.Stdout), scalingOperation, int(scale))
+	if encoding != asctools.EncodingNone {
+		err = elevationMap.WriteEncodedPNG(bufio.NewWriter(out), encoding)
+	} else {
+		err = elevationMap.WritePNG(bufio.NewWriter(out), scalingOperation, int(scale), ramp)
+	}
 	if err != nil {
 		fmt.Println("Error rendering map to png:", err)
 		os.Exit(1)
 	}
 
+	if output != "" {
+		georef := elevationMap.PNGGeoreference(scalingOperation, int(scale), ramp, encoding)
+		if err := asctools.WritePNGSidecars(output, georef, auxXML); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing georeference: %v\n", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -12,14 +12,7 @@ import (
 func Asc2Stl(args []string) {
 	fs := flag.NewFlagSet("asc2stl", flag.ExitOnError)
 
-	var resultScale float64
-	fs.Float64Var((*float64)(&resultScale), "scale", 1.0, "Scale factor for the result (must be greater than 0)")
-
-	var floorElevation float64
-	fs.Float64Var(&floorElevation, "floor", 0.0, "Floor elevation level (default is 0.0)")
-
-	var floorMargin float64
-	fs.Float64Var(&floorMargin, "floor_margin", 0.0, "Margin to add around the base of the model (only works when floor is not set)")
+	meshFlags := addMeshFlags(fs, false)
 
 	fs.Parse(args)
 
@@ -31,11 +24,13 @@ func Asc2Stl(args []string) {
 		return
 	}
 
-	if floorElevation == 0 && floorMargin > 0 {
-		floorElevation = elevationMap.MinElevation - floorMargin
+	options, err := meshFlags.options(elevationMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	err = elevationMap.WriteSTL(bufio.NewWriter(os.Stdout), floorElevation)
+	err = elevationMap.WriteSTL(bufio.NewWriter(os.Stdout), options)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error writing STL to stdout:", err)
 		os.Exit(1)
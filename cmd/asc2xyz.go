@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Asc2Xyz(args []string) {
+	fs := flag.NewFlagSet("asc2xyz", flag.ExitOnError)
+
+	options := asctools.DefaultXYZOptions()
+
+	fs.BoolVar(&options.SkipNodata, "skip_nodata", options.SkipNodata, "Leave out nodata cells")
+
+	var delimiterVal string
+	fs.StringVar(&delimiterVal, "delimiter", "space", "Delimiter between values: 'space', 'tab', 'comma' or 'semicolon'")
+
+	fs.IntVar(&options.Precision, "precision", options.Precision, "Number of decimals (-1 writes values as read)")
+	fs.BoolVar(&options.Header, "header", options.Header, "Write an x, y, z header row")
+	fs.IntVar(&options.Every, "every", options.Every, "Write only every Nth row and column")
+
+	fs.Parse(args)
+
+	delimiter, err := asctools.ParseXYZDelimiter(delimiterVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Delimiter = delimiter
+
+	reader := bufio.NewReader(os.Stdin)
+	elevationMap, err := asctools.ParseASCFile(reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = elevationMap.WriteXYZ(bufio.NewWriter(os.Stdout), options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing XYZ to stdout:", err)
+		os.Exit(1)
+	}
+}
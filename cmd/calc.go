@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+// namedInputs keeps the -input flags in the order they were given, since
+// -extent first uses the grid of the first one.
+type namedInputs struct {
+	names []string
+	paths map[string]string
+}
+
+func (inputs *namedInputs) String() string {
+	parts := []string{}
+	for _, name := range inputs.names {
+		parts = append(parts, name+"="+inputs.paths[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+func (inputs *namedInputs) Set(value string) error {
+	name, path, ok := strings.Cut(value, "=")
+	if !ok || name == "" || path == "" {
+		return fmt.Errorf("expected NAME=path, got %s", value)
+	}
+	if _, exists := inputs.paths[name]; exists {
+		return fmt.Errorf("input %s given more than once", name)
+	}
+	inputs.names = append(inputs.names, name)
+	inputs.paths[name] = path
+	return nil
+}
+
+func Calc(args []string) {
+	fs := flag.NewFlagSet("calc", flag.ExitOnError)
+
+	inputs := &namedInputs{paths: map[string]string{}}
+	fs.Var(inputs, "input", "Named input as NAME=path.asc (can be repeated)")
+
+	var expr string
+	fs.StringVar(&expr, "expr", "", "Expression to evaluate, e.g. 'A - B' or 'where(A > 200, A, nodata)'")
+
+	var extentVal string
+	fs.StringVar(&extentVal, "extent", "intersection", "Output grid extent: 'intersection', 'union' or 'first' (the grid of the first -input)")
+
+	var cellSize float64
+	fs.Float64Var(&cellSize, "cellsize", 0, "Output cell size (default: coarsest input cell size)")
+
+	var resamplingVal string
+	fs.StringVar(&resamplingVal, "resampling", "nearest", "Resampling of inputs not on the output grid: 'nearest', 'bilinear' or 'cubic'")
+
+	var workers int
+	fs.IntVar(&workers, "workers", 0, "Number of parallel workers (default: number of CPUs)")
+
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
+
+	fs.Parse(args)
+
+	if expr == "" || len(inputs.names) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	expression, err := asctools.ParseExpression(expr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing expression: %v\n", err)
+		os.Exit(1)
+	}
+
+	extent, err := asctools.ParseGridExtent(extentVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resampling, err := asctools.ParseResampling(resamplingVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	maps := map[string]*asctools.ElevationMap{}
+	for name, path := range inputs.paths {
+		elevationMap, err := asctools.ReadASCFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		crs.apply(elevationMap)
+		maps[name] = elevationMap
+	}
+
+	result, err := asctools.Calculate(expression, maps, asctools.CalcOptions{
+		Extent:     extent,
+		First:      inputs.names[0],
+		CellSize:   cellSize,
+		Resampling: resampling,
+		Workers:    workers,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error evaluating expression: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = writeASCOutput(result, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing result:", err)
+		os.Exit(1)
+	}
+}
@@ -30,27 +30,24 @@ func Crop(args []string) {
 	var endY float64
 	fs.Float64Var(&endY, "end_y", 1.0, "End Y coordinate (relative: 0-1; absolute: 0..height)")
 
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
+
 	fs.Parse(args)
 
-	// Open and parse the input ASC file (from file or stdin)
-	var reader *bufio.Reader
+	// Parse the input ASC file (from file, with its .prj, or stdin)
+	var elevationMap *asctools.ElevationMap
+	var err error
 	if inputFile == "" {
-		reader = bufio.NewReader(os.Stdin)
+		elevationMap, err = asctools.ParseASCFile(bufio.NewReader(os.Stdin))
 	} else {
-		file, err := os.Open(inputFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error opening input file: %v\n", err)
-			os.Exit(1)
-		}
-		defer file.Close()
-		reader = bufio.NewReader(file)
+		elevationMap, err = asctools.ReadASCFile(inputFile)
 	}
-
-	elevationMap, err := asctools.ParseASCFile(reader)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
 		os.Exit(1)
 	}
+	crs.apply(elevationMap)
 
 	var relStartX, relStartY, relEndX, relEndY float64
 	if relative {
@@ -92,10 +89,7 @@ func Crop(args []string) {
 		os.Exit(1)
 	}
 
-	writer := bufio.NewWriter(os.Stdout)
-	defer writer.Flush()
-
-	err = croppedMap.WriteASC(writer)
+	err = writeASCOutput(croppedMap, *output)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing cropped map: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+// crsFlag is the -crs flag of commands that read elevation maps. It
+// overrides the CRS read from .prj files, which maps read from stdin lack.
+type crsFlag struct {
+	crs *asctools.CRS
+}
+
+const crsUsage = "CRS of the input maps as an EPSG code, e.g. 'EPSG:2180', or WKT, overriding their .prj files"
+
+func addCRSFlag(fs *flag.FlagSet, usage string) *crsFlag {
+	crs := &crsFlag{}
+	fs.Var(crs, "crs", usage)
+	return crs
+}
+
+func (crs *crsFlag) String() string {
+	if crs.crs == nil {
+		return ""
+	}
+	return crs.crs.String()
+}
+
+func (crs *crsFlag) Set(value string) error {
+	parsed, err := asctools.ParseCRS(value)
+	if err != nil {
+		return err
+	}
+	crs.crs = parsed
+	return nil
+}
+
+// apply sets the CRS of the maps when the flag is given.
+func (crs *crsFlag) apply(maps ...*asctools.ElevationMap) {
+	if crs.crs == nil {
+		return
+	}
+	for _, elevationMap := range maps {
+		elevationMap.CRS = crs.crs
+	}
+}
+
+// localProjection returns the projection of the map's CRS, or nil with a
+// warning when the map has no CRS with a supported projection, for commands
+// that fall back to map units.
+func localProjection(elevationMap *asctools.ElevationMap) asctools.Projection {
+	if elevationMap.CRS == nil {
+		return nil
+	}
+	projection, err := elevationMap.CRS.Projection()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, using map units\n", err)
+		return nil
+	}
+	return projection
+}
+
+func addOutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", "", "Output ASC file, written with a .prj file when the CRS is known (default: stdout)")
+}
+
+// writeASCOutput writes a map to path, or to stdout when path is empty.
+func writeASCOutput(elevationMap *asctools.ElevationMap, path string) error {
+	if path == "" {
+		return elevationMap.WriteASC(bufio.NewWriter(os.Stdout))
+	}
+	return elevationMap.WriteASCFile(path)
+}
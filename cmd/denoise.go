@@ -12,11 +12,42 @@ import (
 func Denoise(args []string) {
 	fs := flag.NewFlagSet("denoise", flag.ExitOnError)
 
-	var window int
-	fs.IntVar(&window, "window", 3, "Window size for median filtering (must be odd)")
+	options := asctools.DefaultDenoiseOptions()
+
+	fs.IntVar(&options.WindowSize, "window", options.WindowSize, "Window size for filtering (must be odd)")
+
+	var methodVal string
+	fs.StringVar(&methodVal, "method", "median", "Filtering method: 'median', 'gaussian', 'bilateral' or 'spike'")
+
+	var nodataModeVal string
+	fs.StringVar(&nodataModeVal, "nodata", "preserve", "Nodata handling: 'preserve', 'fill_small' or 'fill'")
+
+	fs.IntVar(&options.FillMaxNodata, "fill_max_nodata", options.FillMaxNodata, "For 'fill_small', fill only cells whose window has fewer nodata cells than this")
+	fs.IntVar(&options.MinValidNeighbours, "min_valid", options.MinValidNeighbours, "Minimum number of valid cells in the window before a filtered value is accepted")
+
+	fs.Float64Var(&options.Sigma, "sigma", 0, "Spatial standard deviation in cells for 'gaussian' and 'bilateral' (default: window/4)")
+	fs.Float64Var(&options.RangeSigma, "range_sigma", options.RangeSigma, "Elevation standard deviation for 'bilateral'")
+	fs.Float64Var(&options.SpikeThreshold, "spike_threshold", options.SpikeThreshold, "For 'spike', replace cells deviating from the local median by more than this many MADs")
+
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
 
 	fs.Parse(args)
 
+	method, err := asctools.ParseDenoiseMethod(methodVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Method = method
+
+	nodataMode, err := asctools.ParseNodataMode(nodataModeVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.NodataMode = nodataMode
+
 	reader := bufio.NewReader(os.Stdin)
 	elevationMap, err := asctools.ParseASCFile(reader)
 
@@ -24,16 +55,17 @@ func Denoise(args []string) {
 		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
 		return
 	}
+	crs.apply(elevationMap)
 
-	denoised, err := elevationMap.Denoise(window)
+	denoised, err := elevationMap.Denoise(options)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error denoising elevation map:", err)
 		os.Exit(1)
 	}
 
-	err = denoised.WriteASC(bufio.NewWriter(os.Stdout))
+	err = writeASCOutput(denoised, *output)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error writing denoised map to stdout:", err)
+		fmt.Fprintln(os.Stderr, "Error writing denoised map:", err)
 		os.Exit(1)
 	}
 }
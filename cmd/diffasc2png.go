@@ -18,11 +18,32 @@ func DiffAsc2Png(args []string) {
 	var input2 string
 	fs.StringVar(&input2, "input2", "", "Path to the input 2 .asc file")
 
-	var diffOnly bool
-	fs.BoolVar(&diffOnly, "diff_only", false, "If true, skips elevation-based coloring and only uses difference-based coloring")
+	options := asctools.DefaultDiffOptions()
 
-	var diffPow float64
-	fs.Float64Var(&diffPow, "diff_pow", 1, "Power to which the elevation difference is raised for emphasis")
+	fs.BoolVar(&options.DiffOnly, "diff_only", options.DiffOnly, "If true, skips elevation-based coloring and only uses difference-based coloring")
+	fs.Float64Var(&options.DiffPow, "diff_pow", options.DiffPow, "Power to which the normalized elevations of the background are raised")
+	fs.Float64Var(&options.Clamp, "clamp", options.Clamp, "Difference shown with the strongest colours (0 to use -clamp_percentile)")
+	fs.Float64Var(&options.ClampPercentile, "clamp_percentile", options.ClampPercentile, "Percentile of the absolute differences used as the clamp when -clamp is 0")
+	fs.Float64Var(&options.DeadBand, "dead_band", options.DeadBand, "Absolute differences below this are not coloured")
+	fs.BoolVar(&options.Hillshade, "hillshade", options.Hillshade, "Use the hillshade of the first map as the background")
+	fs.BoolVar(&options.Legend, "legend", options.Legend, "Add a colour bar legend below the map")
+	fs.StringVar(&options.Units, "units", options.Units, "Units of the legend labels")
+
+	var extentVal string
+	fs.StringVar(&extentVal, "extent", "intersection", "Grid both maps are aligned to: 'intersection', 'union' or 'first'")
+
+	fs.Float64Var(&options.CellSize, "cellsize", options.CellSize, "Cell size of the aligned grid (default: coarsest input cell size)")
+
+	var resamplingVal string
+	fs.StringVar(&resamplingVal, "resampling", "nearest", "Resampling of maps not on the aligned grid: 'nearest', 'bilinear' or 'cubic'")
+
+	var output string
+	fs.StringVar(&output, "output", "", "Output PNG file, written with a .pgw world file next to it (default: stdout)")
+
+	var auxXML bool
+	fs.BoolVar(&auxXML, "aux_xml", false, "Also write a GDAL .aux.xml file with the georeference and colour scale next to -output")
+
+	crs := addCRSFlag(fs, crsUsage)
 
 	fs.Parse(args)
 
@@ -31,35 +52,61 @@ func DiffAsc2Png(args []string) {
 		os.Exit(1)
 	}
 
-	file1, err := os.Open(input1)
+	var err error
+	options.Extent, err = asctools.ParseGridExtent(extentVal)
 	if err != nil {
-		fmt.Println("Error opening input file 1:", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer file1.Close()
 
-	file2, err := os.Open(input2)
+	options.Resampling, err = asctools.ParseResampling(resamplingVal)
 	if err != nil {
-		fmt.Println("Error opening input file 2:", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" && auxXML {
+		fmt.Fprintln(os.Stderr, "Error: -aux_xml requires -output")
 		os.Exit(1)
 	}
-	defer file2.Close()
 
-	elevationMap1, err := asctools.ParseASCFile(bufio.NewReader(file1))
+	elevationMap1, err := asctools.ReadASCFile(input1)
 	if err != nil {
 		fmt.Println("Error reading elevation map:", err)
 		os.Exit(1)
 	}
 
-	elevationMap2, err := asctools.ParseASCFile(bufio.NewReader(file2))
+	elevationMap2, err := asctools.ReadASCFile(input2)
 	if err != nil {
 		fmt.Println("Error reading elevation map:", err)
 		os.Exit(1)
 	}
+	crs.apply(elevationMap1, elevationMap2)
 
-	err = asctools.WriteDiffPNG(bufio.NewWriter(os.Stdout), elevationMap1, elevationMap2, diffPow, diffOnly)
+	img, georef, err := asctools.RenderDiffImage(elevationMap1, elevationMap2, options)
 	if err != nil {
 		fmt.Println("Error rendering map diff to png:", err)
 		os.Exit(1)
 	}
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	if err := asctools.WriteGeoreferencedPNG(bufio.NewWriter(out), img, georef); err != nil {
+		fmt.Println("Error rendering map diff to png:", err)
+		os.Exit(1)
+	}
+
+	if output != "" {
+		if err := asctools.WritePNGSidecars(output, georef, auxXML); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing georeference: %v\n", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -12,11 +12,40 @@ import (
 func Downscale(args []string) {
 	fs := flag.NewFlagSet("downscale", flag.ExitOnError)
 
-	var downscaleFactor int
-	fs.IntVar(&downscaleFactor, "factor", 1, "Downscale factor (must be greater than 1)")
+	options := asctools.DefaultDownscaleOptions()
+
+	fs.Float64Var(&options.Factor, "factor", options.Factor, "Downscale factor, may be fractional (must be at least 1)")
+	fs.Float64Var(&options.CellSize, "cellsize", 0, "Target cell size, overrides -factor")
+
+	var aggregationVal string
+	fs.StringVar(&aggregationVal, "aggregation", "mean", "Aggregation: 'mean', 'min', 'max', 'median', 'mode', 'first' or 'percentile'")
+
+	fs.Float64Var(&options.Percentile, "percentile", options.Percentile, "Percentile (0-100) used by the 'percentile' aggregation")
+
+	var edgeVal string
+	fs.StringVar(&edgeVal, "edges", "drop", "Partial cells at the right and top edges: 'drop' or 'pad'")
+
+	fs.Float64Var(&options.MinCoverage, "min_coverage", 0, "Fraction (0-1) of an output cell that must be covered by valid cells, otherwise it is nodata")
+
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
 
 	fs.Parse(args)
 
+	aggregation, err := asctools.ParseAggregation(aggregationVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Aggregation = aggregation
+
+	edge, err := asctools.ParseEdgePolicy(edgeVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Edge = edge
+
 	reader := bufio.NewReader(os.Stdin)
 	elevationMap, err := asctools.ParseASCFile(reader)
 
@@ -24,16 +53,17 @@ func Downscale(args []string) {
 		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
 		return
 	}
+	crs.apply(elevationMap)
 
-	downscaled, err := elevationMap.Downscale(downscaleFactor)
+	downscaled, err := elevationMap.Downscale(options)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error downscaling elevation map:", err)
 		os.Exit(1)
 	}
 
-	err = downscaled.WriteASC(bufio.NewWriter(os.Stdout))
+	err = writeASCOutput(downscaled, *output)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error writing ASC to stdout:", err)
+		fmt.Fprintln(os.Stderr, "Error writing ASC:", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Grid(args []string) {
+	fs := flag.NewFlagSet("grid", flag.ExitOnError)
+
+	var input string
+	fs.StringVar(&input, "input", "", "LAS or XYZ file, or a glob matching several, to grid (reads stdin if empty)")
+
+	var cellSize float64
+	fs.Float64Var(&cellSize, "cellsize", 1.0, "Cell size of the output grid")
+
+	var originX float64
+	fs.Float64Var(&originX, "origin_x", 0.0, "X coordinate the grid cells are aligned to")
+
+	var originY float64
+	fs.Float64Var(&originY, "origin_y", 0.0, "Y coordinate the grid cells are aligned to")
+
+	var like string
+	fs.StringVar(&like, "like", "", "Path to an ASC file whose grid (origin, cell size and extent) the output uses, overrides -cellsize and the origin")
+
+	var methodVal string
+	fs.StringVar(&methodVal, "method", "mean", "Gridding method: 'min', 'max', 'mean', 'idw', 'nearest' or 'tin'")
+
+	var radius float64
+	fs.Float64Var(&radius, "radius", 0.0, "Search radius of the idw and nearest methods (0 means twice the cell size)")
+
+	var power float64
+	fs.Float64Var(&power, "power", 2.0, "Distance exponent of the idw method")
+
+	var maxEdge float64
+	fs.Float64Var(&maxEdge, "max_edge", 0.0, "Longest triangle side the tin method interpolates across (0 means no limit)")
+
+	var classesVal string
+	fs.StringVar(&classesVal, "classes", "", "Comma separated LAS classes to keep, e.g. '2' for ground only (keeps all if empty)")
+
+	var countPath string
+	fs.StringVar(&countPath, "count", "", "Path to write the number of points in each cell to, as an ASC file")
+
+	var densityPath string
+	fs.StringVar(&densityPath, "density", "", "Path to write the number of points per unit of area to, as an ASC file")
+
+	crs := addCRSFlag(fs, "CRS of the points, e.g. 'EPSG:2180' (default: the CRS of -like)")
+	output := addOutputFlag(fs)
+
+	fs.Parse(args)
+
+	method, err := asctools.ParseGridMethod(methodVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := asctools.DefaultGridOptions()
+	options.OriginX = originX
+	options.OriginY = originY
+	options.CellSize = cellSize
+	options.Method = method
+	options.Radius = radius
+	options.Power = power
+	options.MaxEdge = maxEdge
+	var reference *asctools.ElevationMap
+	if like != "" {
+		reference, err = asctools.ReadASCFile(like)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", like, err)
+			os.Exit(1)
+		}
+		options.OriginX = reference.MinX
+		options.OriginY = reference.MinY
+		options.CellSize = reference.CellSize
+		options.NumRows = reference.NumRows
+		options.NumCols = reference.NumCols
+	}
+
+	var points []asctools.Point
+	if input == "" {
+		points, err = asctools.ReadPoints(bufio.NewReader(os.Stdin))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading points: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		paths, err := filepath.Glob(input)
+		if err != nil || len(paths) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no files match %s\n", input)
+			os.Exit(1)
+		}
+		for _, path := range paths {
+			filePoints, err := asctools.ReadPointFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			points = append(points, filePoints...)
+		}
+	}
+
+	if classesVal != "" {
+		classes, err := asctools.ParsePointClasses(classesVal)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		points = asctools.FilterPointClasses(points, classes)
+	}
+
+	result, err := asctools.GridPoints(points, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error gridding points: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, elevationMap := range []*asctools.ElevationMap{result.Elevation, result.Count, result.Density} {
+		if reference != nil {
+			elevationMap.CRS = reference.CRS
+		}
+		crs.apply(elevationMap)
+	}
+
+	for _, output := range []struct {
+		path         string
+		elevationMap *asctools.ElevationMap
+	}{{countPath, result.Count}, {densityPath, result.Density}} {
+		if output.path == "" {
+			continue
+		}
+		if err := output.elevationMap.WriteASCFile(output.path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", output.path, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writeASCOutput(result.Elevation, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+}
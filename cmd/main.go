@@ -13,6 +13,8 @@ func main() {
 	switch os.Args[1] {
 	case "asc2png":
 		Asc2Png(os.Args[2:])
+	case "png2asc":
+		Png2Asc(os.Args[2:])
 	case "crop":
 		Crop(os.Args[2:])
 	case "diffasc2png":
@@ -21,14 +23,40 @@ func main() {
 		Merge(os.Args[2:])
 	case "split":
 		Split(os.Args[2:])
+	case "asc2html":
+		Asc2Html(os.Args[2:])
+	case "asc2xyz":
+		Asc2Xyz(os.Args[2:])
 	case "asc2stl":
 		Asc2Stl(os.Args[2:])
+	case "asc2mesh":
+		Asc2Mesh(os.Args[2:])
 	case "denoise":
 		Denoise(os.Args[2:])
 	case "downscale":
 		Downscale(os.Args[2:])
 	case "subtract":
 		Subtract(os.Args[2:])
+	case "pipeline":
+		Pipeline(os.Args[2:])
+	case "calc":
+		Calc(os.Args[2:])
+	case "warp":
+		Warp(os.Args[2:])
+	case "stltiles":
+		StlTiles(os.Args[2:])
+	case "stl2asc":
+		Stl2Asc(os.Args[2:])
+	case "tiles":
+		Tiles(os.Args[2:])
+	case "terrain":
+		Terrain(os.Args[2:])
+	case "grid":
+		Grid(os.Args[2:])
+	case "serve":
+		Serve(os.Args[2:])
+	case "meshcheck":
+		MeshCheck(os.Args[2:])
 	default:
 		fmt.Println("Unknown command")
 	}
@@ -1,12 +1,9 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	asctools "github.com/kgabis/asctools/pkg"
 )
@@ -16,6 +13,9 @@ func Merge(args []string) {
 
 	var inputDir string
 	fs.StringVar(&inputDir, "input_dir", "", "Directory containing ASC files to merge")
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
+
 	fs.Parse(args)
 
 	if inputDir == "" {
@@ -25,31 +25,21 @@ func Merge(args []string) {
 
 	var maps []*asctools.ElevationMap
 
-	files, err := os.ReadDir(inputDir)
+	paths, err := asctools.ListASCFiles(inputDir)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error reading input directory:", err)
 		os.Exit(1)
 	}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".asc") {
-			path := filepath.Join(inputDir, file.Name())
-			reader, err := os.Open(path)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "Error opening file:", path, err)
-				continue
-			}
-			bufioreader := bufio.NewReader(reader)
-			defer reader.Close()
-			slice, err := asctools.ParseASCFile(bufioreader)
-			reader.Close()
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "Error reading ASC file:", path, err)
-				continue
-			}
-			maps = append(maps, slice)
+	for _, path := range paths {
+		slice, err := asctools.ReadASCFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading ASC file:", path, err)
+			continue
 		}
+		maps = append(maps, slice)
 	}
+	crs.apply(maps...)
 
 	if len(maps) == 0 {
 		fmt.Fprintln(os.Stderr, "No ASC files found in the input directory")
@@ -62,9 +52,9 @@ func Merge(args []string) {
 		os.Exit(1)
 	}
 
-	err = mergedMap.WriteASC(bufio.NewWriter(os.Stdout))
+	err = writeASCOutput(mergedMap, *output)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error writing merged map to stdout:", err)
+		fmt.Fprintln(os.Stderr, "Error writing merged map:", err)
 		os.Exit(1)
 	}
 }
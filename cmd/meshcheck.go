@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func MeshCheck(args []string) {
+	fs := flag.NewFlagSet("meshcheck", flag.ExitOnError)
+	fs.Parse(args)
+
+	triangles, err := asctools.ReadSTL(bufio.NewReader(os.Stdin))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading STL file: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := asctools.CheckTriangles(triangles)
+
+	fmt.Printf("triangles: %d\n", report.Triangles)
+	fmt.Printf("vertices: %d\n", report.Vertices)
+	fmt.Printf("degenerate triangles: %d\n", report.DegenerateTriangles)
+	fmt.Printf("open edges: %d\n", report.OpenEdges)
+	fmt.Printf("non-manifold edges: %d\n", report.NonManifoldEdges)
+	fmt.Printf("inconsistently oriented edges: %d\n", report.InconsistentEdges)
+	fmt.Printf("inverted normals: %d\n", report.InvertedNormals)
+	if report.Watertight() {
+		fmt.Printf("volume: %.6g\n", report.Volume)
+		if report.Volume < 0 {
+			fmt.Println("result: watertight, but inside out")
+			os.Exit(1)
+		}
+		if report.InvertedNormals > 0 {
+			fmt.Println("result: watertight, but with inverted normals")
+			os.Exit(1)
+		}
+		fmt.Println("result: watertight")
+	} else {
+		fmt.Println("result: not watertight")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+// meshFlags are the model options shared by asc2stl, asc2mesh and
+// stltiles.
+type meshFlags struct {
+	scale          float64
+	printWidth     float64
+	exaggeration   float64
+	baseThickness  float64
+	origin         string
+	floorElevation float64
+	floorMargin    float64
+	maxError       float64
+	maxTriangles   int
+	mode           string
+	minThickness   float64
+	maxThickness   float64
+	plinth         asctools.PlinthOptions
+	reference      string
+}
+
+// stringList collects the values of a flag given several times.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ", ")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// addMeshFlags registers the model flags. Tiled models leave out the origin
+// and simplification, which tiles do not support.
+func addMeshFlags(fs *flag.FlagSet, tiled bool) *meshFlags {
+	flags := &meshFlags{origin: "corner"}
+
+	fs.Float64Var(&flags.scale, "scale", 1.0, "Model units per map unit, e.g. millimetres per metre (must be greater than 0)")
+	fs.Float64Var(&flags.printWidth, "print_width", 0.0, "Width of the model along X in model units, overrides -scale (0 to use -scale)")
+	fs.Float64Var(&flags.exaggeration, "exaggeration", 1.0, "Vertical exaggeration applied on top of the scale")
+	fs.Float64Var(&flags.baseThickness, "base_thickness", 0.0, "Minimum thickness of the model below its lowest point, in model units")
+	fs.Float64Var(&flags.floorElevation, "floor", 0.0, "Floor elevation level (default is 0.0)")
+	fs.Float64Var(&flags.floorMargin, "floor_margin", 0.0, "Margin to add around the base of the model (only works when floor is not set)")
+	if tiled {
+		return flags
+	}
+	fs.StringVar(&flags.origin, "origin", "corner", "Model origin: 'corner', 'center' or map coordinates X,Y shared by tiles exported separately")
+	fs.StringVar(&flags.reference, "reference", "", "ASC file of the whole map that -print_width, -base_thickness and -floor_margin refer to, shared by tiles exported separately")
+	fs.Float64Var(&flags.maxError, "max_error", 0.0, "Simplify the surface to a TIN with at most this vertical error (0 keeps the full grid)")
+	fs.IntVar(&flags.maxTriangles, "max_triangles", 0, "Simplify the surface to a TIN with at most this many surface triangles (0 means no limit)")
+	fs.StringVar(&flags.mode, "mode", "relief", "Model type: 'relief', 'lithophane' (thickness follows a hillshade) or 'mould' (inverted block for casting)")
+	fs.Float64Var(&flags.minThickness, "min_thickness", 0.0, "Minimum thickness of a lithophane or mould in model units (0 for 0.8 and 2)")
+	fs.Float64Var(&flags.maxThickness, "max_thickness", 0.0, "Maximum thickness of a lithophane or mould in model units (0 for 3, and room for the relief in a mould)")
+
+	flags.plinth = asctools.DefaultPlinthOptions()
+	fs.Float64Var(&flags.plinth.Thickness, "plinth", 0.0, "Stand the model on a plinth of this thickness in model units (0 for no plinth)")
+	fs.Float64Var(&flags.plinth.Margin, "plinth_margin", flags.plinth.Margin, "Width of the plinth around the model, in model units")
+	fs.Var((*stringList)(&flags.plinth.Text), "plinth_text", "Line of text in front of the model, e.g. a title or date (can be repeated)")
+	fs.BoolVar(&flags.plinth.Coordinates, "plinth_coords", false, "Add a line with the map coordinates of the model centre")
+	fs.BoolVar(&flags.plinth.ScaleBar, "scale_bar", false, "Add a scale bar, labelled assuming map units are metres")
+	fs.BoolVar(&flags.plinth.NorthArrow, "north_arrow", false, "Add a north arrow pointing along the map Y axis")
+	fs.Float64Var(&flags.plinth.TextHeight, "text_height", flags.plinth.TextHeight, "Height of plinth text in model units")
+	fs.Float64Var(&flags.plinth.Relief, "text_relief", flags.plinth.Relief, "Height of embossed plinth text and symbols in model units, negative to engrave")
+
+	return flags
+}
+
+func (flags *meshFlags) options(elevationMap *asctools.ElevationMap) (asctools.MeshOptions, error) {
+	origin, err := asctools.ParseMeshOrigin(flags.origin)
+	if err != nil {
+		return asctools.MeshOptions{}, err
+	}
+	mode, err := asctools.ParseSurfaceMode(flags.mode)
+	if err != nil {
+		return asctools.MeshOptions{}, err
+	}
+	var reference *asctools.ElevationMap
+	floorMap := elevationMap
+	if flags.reference != "" {
+		reference, err = asctools.ReadASCFile(flags.reference)
+		if err != nil {
+			return asctools.MeshOptions{}, err
+		}
+		floorMap = reference
+	}
+
+	return asctools.MeshOptions{
+		FloorElevation: floorMap.FloorElevation(flags.floorElevation, flags.floorMargin),
+		Simplify: asctools.TINOptions{
+			MaxError:     flags.maxError,
+			MaxTriangles: flags.maxTriangles,
+		},
+		Scale:                flags.scale,
+		PrintWidth:           flags.printWidth,
+		VerticalExaggeration: flags.exaggeration,
+		BaseThickness:        flags.baseThickness,
+		Origin:               origin,
+		Mode:                 mode,
+		MinThickness:         flags.minThickness,
+		MaxThickness:         flags.maxThickness,
+		Plinth:               flags.plinth,
+		Reference:            reference,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Pipeline(args []string) {
+	fs := flag.NewFlagSet("pipeline", flag.ExitOnError)
+
+	var recipePath string
+	fs.StringVar(&recipePath, "recipe", "", "Path to the JSON or YAML recipe file (use - for stdin)")
+
+	var format string
+	fs.StringVar(&format, "format", "", "Recipe format: 'json' or 'yaml' (default: based on file extension)")
+
+	var validateOnly bool
+	fs.BoolVar(&validateOnly, "validate", false, "Only validate the recipe without running it")
+
+	var listOperations bool
+	fs.BoolVar(&listOperations, "list", false, "List available operations and exit")
+
+	fs.Parse(args)
+
+	if listOperations {
+		fmt.Println(strings.Join(asctools.OperationNames(), "\n"))
+		return
+	}
+
+	if recipePath == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var reader io.Reader
+	if recipePath == "-" {
+		reader = os.Stdin
+	} else {
+		file, err := os.Open(recipePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening recipe: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	if format == "" {
+		format = asctools.RecipeFormatFromPath(recipePath)
+	}
+
+	recipe, err := asctools.ParseRecipe(reader, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if validateOnly {
+		err = recipe.Validate()
+	} else {
+		_, err = recipe.Run()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running pipeline: %v\n", err)
+		os.Exit(1)
+	}
+}
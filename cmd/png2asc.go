@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Png2Asc(args []string) {
+	fs := flag.NewFlagSet("png2asc", flag.ExitOnError)
+
+	var encodingVal string
+	fs.StringVar(&encodingVal, "encoding", "terrain-rgb", "Elevation encoding of the PNG: 'terrain-rgb' or 'terrarium'")
+
+	var cellSize float64
+	fs.Float64Var(&cellSize, "cellsize", 1.0, "Cell size of a pixel")
+
+	var originX float64
+	fs.Float64Var(&originX, "origin_x", 0.0, "X coordinate of the lower left corner of the image")
+
+	var originY float64
+	fs.Float64Var(&originY, "origin_y", 0.0, "Y coordinate of the lower left corner of the image")
+
+	var tile string
+	fs.StringVar(&tile, "tile", "", "Web Mercator tile the PNG is, as 'z/x/y' in the XYZ scheme, overrides -cellsize and the origin")
+
+	crs := addCRSFlag(fs, "CRS of the origin and cell size, e.g. 'EPSG:2180' (default: EPSG:3857 for -tile)")
+	output := addOutputFlag(fs)
+
+	fs.Parse(args)
+
+	encoding, err := asctools.ParseElevationEncoding(encodingVal)
+	if err == nil && encoding == asctools.EncodingNone {
+		err = fmt.Errorf("an elevation encoding is required")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var z, x, y int
+	if tile != "" {
+		if _, err := fmt.Sscanf(tile, "%d/%d/%d", &z, &x, &y); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid tile %s, expected z/x/y\n", tile)
+			os.Exit(1)
+		}
+	}
+
+	elevationMap, err := asctools.ReadEncodedPNG(bufio.NewReader(os.Stdin), encoding, originX, originY, cellSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading PNG: %v\n", err)
+		os.Exit(1)
+	}
+	if tile != "" {
+		if elevationMap.NumRows != elevationMap.NumCols {
+			fmt.Fprintln(os.Stderr, "Error: tiles must be square")
+			os.Exit(1)
+		}
+		originX, originY, cellSize = asctools.WebMercatorTileGrid(z, x, y, elevationMap.NumCols)
+		elevationMap.MinX, elevationMap.MinY, elevationMap.CellSize = originX, originY, cellSize
+		elevationMap.MaxX = originX + float64(elevationMap.NumCols)*cellSize
+		elevationMap.MaxY = originY + float64(elevationMap.NumRows)*cellSize
+		elevationMap.CRS, _ = asctools.CRSFromEPSG(3857)
+	}
+	crs.apply(elevationMap)
+
+	err = writeASCOutput(elevationMap, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing ASC:", err)
+		os.Exit(1)
+	}
+}
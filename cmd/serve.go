@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	options := asctools.DefaultMapServerOptions()
+
+	var input string
+	fs.StringVar(&input, "input", "", "ASC file, or directory of ASC tiles to merge (reads stdin if empty)")
+
+	var addr string
+	fs.StringVar(&addr, "addr", "127.0.0.1:8080", "Address to listen on")
+
+	crs := addCRSFlag(fs, "CRS of the map as an EPSG code, e.g. 'EPSG:32633', for Web Mercator tiles and longitude and latitude queries (default: from .prj files)")
+
+	fs.IntVar(&options.TileSize, "tile_size", options.TileSize, "Tile width and height in pixels")
+	fs.IntVar(&options.CacheSize, "cache", options.CacheSize, "Number of rendered tiles to keep in memory")
+
+	fs.Parse(args)
+
+	elevationMap, err := readTilesInput(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+	crs.apply(elevationMap)
+	options.Projection = localProjection(elevationMap)
+
+	server, err := asctools.NewMapServer(elevationMap, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving %d x %d cells on http://%s/\n", elevationMap.NumCols, elevationMap.NumRows, addr)
+	if err := http.ListenAndServe(addr, server); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -28,6 +28,8 @@ func Split(args []string) {
 	var prefix string
 	fs.StringVar(&prefix, "prefix", "tile", "Prefix for output filenames")
 
+	crs := addCRSFlag(fs, crsUsage)
+
 	fs.Parse(args)
 
 	inputReader := bufio.NewReader(os.Stdin)
@@ -37,6 +39,7 @@ func Split(args []string) {
 		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
 		os.Exit(1)
 	}
+	crs.apply(elevationMap)
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
@@ -61,7 +64,7 @@ func Split(args []string) {
 			filename := fmt.Sprintf("%s_%d_%d.asc", prefix, row, col)
 			outputPath := filepath.Join(outputDir, filename)
 
-			if err := writeTileToFile(tile, outputPath); err != nil {
+			if err := tile.WriteASCFile(outputPath); err != nil {
 				fmt.Fprintf(os.Stderr, "Error writing tile %s: %v\n", filename, err)
 				continue
 			}
@@ -70,16 +73,3 @@ func Split(args []string) {
 		}
 	}
 }
-
-func writeTileToFile(tile *asctools.ElevationMap, outputPath string) error {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	return tile.WriteASC(writer)
-}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Stl2Asc(args []string) {
+	fs := flag.NewFlagSet("stl2asc", flag.ExitOnError)
+
+	var cellSize float64
+	fs.Float64Var(&cellSize, "cellsize", 1.0, "Cell size of the output grid, in the STL units")
+
+	var originX float64
+	fs.Float64Var(&originX, "origin_x", 0.0, "X coordinate the grid cells are aligned to")
+
+	var originY float64
+	fs.Float64Var(&originY, "origin_y", 0.0, "Y coordinate the grid cells are aligned to")
+
+	var like string
+	fs.StringVar(&like, "like", "", "Path to an ASC file whose grid (origin, cell size and extent) the output uses, overrides -cellsize and the origin")
+
+	var surfaceVal string
+	fs.StringVar(&surfaceVal, "surface", "top", "Surface to write: 'top', 'bottom' or 'thickness'")
+
+	crs := addCRSFlag(fs, "CRS of the STL coordinates, e.g. 'EPSG:2180' (default: the CRS of -like)")
+	output := addOutputFlag(fs)
+
+	fs.Parse(args)
+
+	surface, err := asctools.ParseRasterSurface(surfaceVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := asctools.RasterizeOptions{
+		OriginX:  originX,
+		OriginY:  originY,
+		CellSize: cellSize,
+		Surface:  surface,
+	}
+	var reference *asctools.ElevationMap
+	if like != "" {
+		reference, err = asctools.ReadASCFile(like)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", like, err)
+			os.Exit(1)
+		}
+		options = asctools.GridRasterizeOptions(reference, surface)
+	}
+
+	triangles, err := asctools.ReadSTL(bufio.NewReader(os.Stdin))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading STL file: %v\n", err)
+		os.Exit(1)
+	}
+
+	elevationMap, err := asctools.RasterizeTriangles(triangles, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rasterizing STL: %v\n", err)
+		os.Exit(1)
+	}
+
+	if reference != nil {
+		elevationMap.CRS = reference.CRS
+	}
+	crs.apply(elevationMap)
+
+	if err := writeASCOutput(elevationMap, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+}
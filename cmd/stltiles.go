@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func StlTiles(args []string) {
+	fs := flag.NewFlagSet("stltiles", flag.ExitOnError)
+
+	meshFlags := addMeshFlags(fs, true)
+	defaults := asctools.DefaultTileOptions()
+
+	var outputDir string
+	fs.StringVar(&outputDir, "output_dir", ".", "Directory to save the STL tiles")
+
+	var nrows int
+	fs.IntVar(&nrows, "nrows", defaults.Rows, "Number of rows in the output grid")
+
+	var ncols int
+	fs.IntVar(&ncols, "ncols", defaults.Cols, "Number of columns in the output grid")
+
+	var prefix string
+	fs.StringVar(&prefix, "prefix", "tile", "Prefix for output filenames")
+
+	var jointVal string
+	fs.StringVar(&jointVal, "joints", "none", "Joints on the cut faces: 'none' or 'dowel' (also writes a peg to <prefix>_dowel.stl)")
+
+	var dowelSize float64
+	fs.Float64Var(&dowelSize, "dowel_size", defaults.DowelSize, "Width of the square dowel holes in model units, rounded to whole cells")
+
+	var dowelDepth float64
+	fs.Float64Var(&dowelDepth, "dowel_depth", defaults.DowelDepth, "Depth of the dowel holes in model units")
+
+	var dowelClearance float64
+	fs.Float64Var(&dowelClearance, "dowel_clearance", defaults.DowelClearance, "Gap between a peg and its hole on each side, in model units")
+
+	var dowelsPerSide int
+	fs.IntVar(&dowelsPerSide, "dowels_per_side", defaults.DowelsPerSide, "Number of dowels along each cut of a tile")
+
+	var engraveDepth float64
+	fs.Float64Var(&engraveDepth, "engrave_depth", defaults.EngraveDepth, "Depth of the tile index engraved on the underside in model units (0 to disable)")
+
+	var labelHeight float64
+	fs.Float64Var(&labelHeight, "label_height", 0.0, "Height of the engraved tile index in model units (0 for a quarter of the tile)")
+
+	fs.Parse(args)
+
+	joint, err := asctools.ParseJointType(jointVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	elevationMap, err := asctools.ParseASCFile(reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+
+	meshOptions, err := meshFlags.options(elevationMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	tiles, err := elevationMap.BuildMeshTiles(asctools.TileOptions{
+		Rows:           nrows,
+		Cols:           ncols,
+		Mesh:           meshOptions,
+		Joint:          joint,
+		DowelSize:      dowelSize,
+		DowelDepth:     dowelDepth,
+		DowelClearance: dowelClearance,
+		DowelsPerSide:  dowelsPerSide,
+		EngraveDepth:   engraveDepth,
+		LabelHeight:    labelHeight,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building tiles: %v\n", err)
+		os.Exit(1)
+	}
+
+	if tiles.SkippedJoints > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d dowel holes skipped where the model is too thin or has no data\n", tiles.SkippedJoints)
+	}
+	if tiles.UnlabelledTiles > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d tiles are too small or too coarse to engrave their index\n", tiles.UnlabelledTiles)
+	}
+
+	for _, tile := range tiles.Tiles {
+		filename := fmt.Sprintf("%s_%d_%d.stl", prefix, tile.Row, tile.Col)
+		if err := asctools.WriteMeshFile(filepath.Join(outputDir, filename), asctools.FormatSTL, tile.Mesh, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing tile %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+	}
+
+	if tiles.Dowel != nil {
+		filename := fmt.Sprintf("%s_dowel.stl", prefix)
+		if err := asctools.WriteMeshFile(filepath.Join(outputDir, filename), asctools.FormatSTL, tiles.Dowel, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing dowel %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+	}
+}
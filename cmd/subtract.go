@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"os"
@@ -18,6 +17,9 @@ func Subtract(args []string) {
 	var input2 string
 	fs.StringVar(&input2, "input2", "", "Path to the input 2 .asc file")
 
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
+
 	fs.Parse(args)
 
 	if input1 == "" || input2 == "" {
@@ -25,31 +27,18 @@ func Subtract(args []string) {
 		os.Exit(1)
 	}
 
-	file1, err := os.Open(input1)
-	if err != nil {
-		fmt.Println("Error opening input file 1:", err)
-		os.Exit(1)
-	}
-	defer file1.Close()
-
-	file2, err := os.Open(input2)
-	if err != nil {
-		fmt.Println("Error opening input file 2:", err)
-		os.Exit(1)
-	}
-	defer file2.Close()
-
-	elevationMap1, err := asctools.ParseASCFile(bufio.NewReader(file1))
+	elevationMap1, err := asctools.ReadASCFile(input1)
 	if err != nil {
 		fmt.Println("Error reading elevation map:", err)
 		os.Exit(1)
 	}
 
-	elevationMap2, err := asctools.ParseASCFile(bufio.NewReader(file2))
+	elevationMap2, err := asctools.ReadASCFile(input2)
 	if err != nil {
 		fmt.Println("Error reading elevation map:", err)
 		os.Exit(1)
 	}
+	crs.apply(elevationMap1, elevationMap2)
 
 	result, err := elevationMap1.Subtract(elevationMap2)
 	if err != nil {
@@ -57,9 +46,9 @@ func Subtract(args []string) {
 		os.Exit(1)
 	}
 
-	err = result.WriteASC(bufio.NewWriter(os.Stdout))
+	err = writeASCOutput(result, *output)
 	if err != nil {
-		fmt.Println("Error writing result to stdout:", err)
+		fmt.Println("Error writing result:", err)
 		os.Exit(1)
 	}
 }
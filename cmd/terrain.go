@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Terrain(args []string) {
+	fs := flag.NewFlagSet("terrain", flag.ExitOnError)
+
+	options := asctools.DefaultQuantizedMeshOptions()
+
+	var input string
+	fs.StringVar(&input, "input", "", "ASC file, or directory of ASC tiles to merge (reads stdin if empty)")
+
+	var outputDir string
+	fs.StringVar(&outputDir, "output", "terrain", "Directory to write the terrain tiles to")
+
+	fs.IntVar(&options.MaxLevel, "max_level", options.MaxLevel, "Highest level (-1 for the level matching the cell size)")
+	fs.BoolVar(&options.Normals, "normals", options.Normals, "Add oct-encoded vertex normals for lighting")
+
+	crs := addCRSFlag(fs, "CRS of the map as an EPSG code, e.g. 'EPSG:32633' (required unless the input has .prj files)")
+
+	var name string
+	fs.StringVar(&name, "name", "", "Name of the terrain in layer.json")
+
+	fs.Parse(args)
+
+	elevationMap, err := readTilesInput(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+	crs.apply(elevationMap)
+	if elevationMap.CRS == nil {
+		fmt.Fprintln(os.Stderr, "Error: crs is required")
+		os.Exit(1)
+	}
+	options.Projection, err = elevationMap.CRS.Projection()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	pyramid, err := asctools.NewQuantizedMeshPyramid(elevationMap, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if name == "" && input != "" {
+		name = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	}
+	written, err := pyramid.WritePyramid(outputDir, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing terrain tiles: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d terrain tiles for levels 0 to %d\n", written, pyramid.MaxLevel())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Tiles(args []string) {
+	fs := flag.NewFlagSet("tiles", flag.ExitOnError)
+
+	options := asctools.DefaultTilePyramidOptions()
+
+	var input string
+	fs.StringVar(&input, "input", "", "ASC file, or directory of ASC tiles to merge (reads stdin if empty)")
+
+	var outputDir string
+	fs.StringVar(&outputDir, "output", "tiles", "Directory to write the tile pyramid to")
+
+	fs.IntVar(&options.MinZoom, "min_zoom", options.MinZoom, "Lowest zoom level")
+	fs.IntVar(&options.MaxZoom, "max_zoom", options.MaxZoom, "Highest zoom level (-1 for the zoom matching the cell size)")
+
+	var schemeVal string
+	fs.StringVar(&schemeVal, "scheme", "xyz", "Tile row numbering: 'xyz' (from the north) or 'tms' (from the south)")
+
+	fs.IntVar(&options.TileSize, "tile_size", options.TileSize, "Tile width and height in pixels")
+
+	var rampVal string
+	fs.StringVar(&rampVal, "ramp", "terrain", "Color ramp: 'gray', 'terrain' or 'viridis'")
+
+	fs.BoolVar(&options.Hillshade, "hillshade", false, "Darken the colour ramp by a hillshade lit from the north west")
+
+	var encodingVal string
+	fs.StringVar(&encodingVal, "encoding", "none", "Elevation encoding for raster DEM tiles: 'none' (colour ramp), 'terrain-rgb' or 'terrarium'")
+
+	crs := addCRSFlag(fs, "CRS of the map as an EPSG code, e.g. 'EPSG:32633', for Web Mercator tiles (default: from .prj files, local tiles in map units if unknown)")
+
+	var name string
+	fs.StringVar(&name, "name", "", "Name of the tile set in tiles.json")
+
+	var url string
+	fs.StringVar(&url, "url", "", "Tile URL template in tiles.json (default: '{z}/{x}/{y}.png', relative to tiles.json)")
+
+	fs.Parse(args)
+
+	scheme, err := asctools.ParseTileScheme(schemeVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Scheme = scheme
+
+	ramp, err := asctools.ParseColorRamp(rampVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.Ramp = ramp
+
+	options.Encoding, err = asctools.ParseElevationEncoding(encodingVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	elevationMap, err := readTilesInput(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+	crs.apply(elevationMap)
+	options.Projection = localProjection(elevationMap)
+
+	renderer, err := asctools.NewTileRenderer(elevationMap, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if name == "" && input != "" {
+		name = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	}
+	written, err := renderer.WriteTilePyramid(outputDir, name, url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing tiles: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d tiles for zoom levels %d to %d\n", written, renderer.MinZoom(), renderer.MaxZoom())
+}
+
+// readTilesInput reads an ASC file, merges the ASC files of a directory, or
+// reads stdin when path is empty.
+func readTilesInput(path string) (*asctools.ElevationMap, error) {
+	if path == "" {
+		return asctools.ParseASCFile(bufio.NewReader(os.Stdin))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return asctools.ReadASCFile(path)
+	}
+
+	paths, err := asctools.ListASCFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no ASC files found in %s", path)
+	}
+	maps := []*asctools.ElevationMap{}
+	for _, mapPath := range paths {
+		elevationMap, err := asctools.ReadASCFile(mapPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", mapPath, err)
+		}
+		maps = append(maps, elevationMap)
+	}
+	return asctools.MergeMaps(maps)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	asctools "github.com/kgabis/asctools/pkg"
+)
+
+func Warp(args []string) {
+	fs := flag.NewFlagSet("warp", flag.ExitOnError)
+
+	var inputFile string
+	fs.StringVar(&inputFile, "input", "", "Path to the input ASC file to warp (default: stdin)")
+
+	var targetCRS crsFlag
+	fs.Var(&targetCRS, "target_crs", "CRS to warp the map to as an EPSG code, e.g. 'EPSG:32633', or WKT (required)")
+
+	var options asctools.WarpOptions
+	fs.Float64Var(&options.CellSize, "cellsize", 0, "Cell size of the output grid in target CRS units (default: area of an input cell)")
+
+	var resamplingVal string
+	fs.StringVar(&resamplingVal, "resampling", "bilinear", "Resampling: 'nearest', 'bilinear' or 'cubic'")
+
+	fs.IntVar(&options.Workers, "workers", 0, "Number of parallel workers (default: number of CPUs)")
+
+	crs := addCRSFlag(fs, crsUsage)
+	output := addOutputFlag(fs)
+
+	fs.Parse(args)
+
+	if targetCRS.crs == nil {
+		fmt.Fprintln(os.Stderr, "Error: -target_crs is required")
+		os.Exit(1)
+	}
+
+	var err error
+	options.Resampling, err = asctools.ParseResampling(resamplingVal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var elevationMap *asctools.ElevationMap
+	if inputFile == "" {
+		elevationMap, err = asctools.ParseASCFile(bufio.NewReader(os.Stdin))
+	} else {
+		elevationMap, err = asctools.ReadASCFile(inputFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ASC file: %v\n", err)
+		os.Exit(1)
+	}
+	crs.apply(elevationMap)
+
+	warped, err := elevationMap.Warp(targetCRS.crs, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeASCOutput(warped, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing ASC:", err)
+		os.Exit(1)
+	}
+}
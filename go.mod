@@ -4,4 +4,7 @@ go 1.24.0
 
 toolchain go1.24.3
 
-require golang.org/x/image v0.31.0
+require (
+	golang.org/x/image v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,516 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a compiled raster calculator expression. Nodata is carried
+// as NaN while evaluating, so arithmetic propagates it on its own; only
+// isnodata, coalesce and the branch not taken by where can hide it.
+type Expression struct {
+	source    string
+	root      exprNode
+	variables []string
+}
+
+type CalcOptions struct {
+	Extent GridExtent
+	// First is the name of the input whose grid ExtentFirst uses. When
+	// empty, it is the first variable of the expression.
+	First      string
+	CellSize   float64
+	Resampling Resampling
+	Workers    int
+}
+
+type exprNode func(values []float64) float64
+
+type exprFunction struct {
+	minArgs int
+	maxArgs int
+	build   func(args []exprNode) exprNode
+}
+
+var exprConstants = map[string]float64{
+	"nodata": math.NaN(),
+	"pi":     math.Pi,
+	"e":      math.E,
+}
+
+var exprFunctions = map[string]exprFunction{
+	"abs":      unaryFunction(math.Abs),
+	"sqrt":     unaryFunction(math.Sqrt),
+	"exp":      unaryFunction(math.Exp),
+	"log":      unaryFunction(math.Log),
+	"log10":    unaryFunction(math.Log10),
+	"sin":      unaryFunction(math.Sin),
+	"cos":      unaryFunction(math.Cos),
+	"tan":      unaryFunction(math.Tan),
+	"asin":     unaryFunction(math.Asin),
+	"acos":     unaryFunction(math.Acos),
+	"atan":     unaryFunction(math.Atan),
+	"floor":    unaryFunction(math.Floor),
+	"ceil":     unaryFunction(math.Ceil),
+	"round":    unaryFunction(math.Round),
+	"atan2":    binaryFunction(math.Atan2),
+	"pow":      binaryFunction(math.Pow),
+	"min":      {minArgs: 1, maxArgs: -1, build: foldFunction(math.Min)},
+	"max":      {minArgs: 1, maxArgs: -1, build: foldFunction(math.Max)},
+	"clamp":    {minArgs: 3, maxArgs: 3, build: buildClamp},
+	"where":    {minArgs: 3, maxArgs: 3, build: buildWhere},
+	"isnodata": {minArgs: 1, maxArgs: 1, build: buildIsNodata},
+	"coalesce": {minArgs: 1, maxArgs: -1, build: buildCoalesce},
+}
+
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenizeExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &exprParser{tokens: tokens, variables: map[string]int{}}
+	root, err := parser.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek().kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %q at position %d", parser.peek().text, parser.peek().pos)
+	}
+
+	variables := make([]string, len(parser.variables))
+	for name, index := range parser.variables {
+		variables[index] = name
+	}
+
+	return &Expression{source: source, root: root, variables: variables}, nil
+}
+
+func (expression *Expression) Variables() []string {
+	variables := append([]string(nil), expression.variables...)
+	sort.Strings(variables)
+	return variables
+}
+
+func (expression *Expression) String() string {
+	return expression.source
+}
+
+func Calculate(expression *Expression, inputs map[string]*ElevationMap, options CalcOptions) (*ElevationMap, error) {
+	maps := make([]*ElevationMap, len(expression.variables))
+	for i, name := range expression.variables {
+		elevationMap, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("no input named %s", name)
+		}
+		maps[i] = elevationMap
+	}
+
+	var grid *ElevationMap
+	var err error
+	if len(maps) == 0 {
+		var all []*ElevationMap
+		for _, name := range sortedMapNames(inputs) {
+			all = append(all, inputs[name])
+		}
+		grid, err = CommonGrid(all, options.Extent, options.CellSize)
+	} else if options.Extent == ExtentFirst && options.First != "" {
+		first, ok := inputs[options.First]
+		if !ok {
+			return nil, fmt.Errorf("no input named %s", options.First)
+		}
+		grid, err = CommonGrid(append([]*ElevationMap{first}, maps...), options.Extent, options.CellSize)
+	} else {
+		grid, err = CommonGrid(maps, options.Extent, options.CellSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aligned := alignToGrid(maps, grid, options.Resampling)
+
+	result := makeElevationMapWithSize(grid.MinX, grid.MinY, grid.NumRows, grid.NumCols, grid.CellSize)
+	result.CRS = grid.CRS
+
+	parallelRows(result.NumRows, options.Workers, func(worker, startRow, endRow int) {
+		values := make([]float64, len(aligned))
+		for row := startRow; row < endRow; row++ {
+			for col := 0; col < result.NumCols; col++ {
+				for i, m := range aligned {
+					value := m.GetRowCol(row, col, false)
+					if value == NodataValue {
+						value = math.NaN()
+					}
+					values[i] = value
+				}
+				value := expression.root(values)
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					value = NodataValue
+				}
+				result.SetRowCol(row, col, value)
+			}
+		}
+	})
+
+	result.UpdateElevationRange()
+
+	return result, nil
+}
+
+func sortedMapNames(maps map[string]*ElevationMap) []string {
+	names := make([]string, 0, len(maps))
+	for name := range maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type exprToken struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+var exprOperators = []string{"<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "%", "^", "<", ">", "!"}
+
+func tokenizeExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	pos := 0
+	for pos < len(source) {
+		c := rune(source[pos])
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case unicode.IsDigit(c) || c == '.':
+			start := pos
+			for pos < len(source) && (unicode.IsDigit(rune(source[pos])) || source[pos] == '.') {
+				pos++
+			}
+			if pos < len(source) && (source[pos] == 'e' || source[pos] == 'E') {
+				next := pos + 1
+				if next < len(source) && (source[next] == '+' || source[next] == '-') {
+					next++
+				}
+				if next < len(source) && unicode.IsDigit(rune(source[next])) {
+					pos = next
+					for pos < len(source) && unicode.IsDigit(rune(source[pos])) {
+						pos++
+					}
+				}
+			}
+			value, err := strconv.ParseFloat(source[start:pos], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", source[start:pos], start)
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: source[start:pos], value: value, pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := pos
+			for pos < len(source) && (unicode.IsLetter(rune(source[pos])) || unicode.IsDigit(rune(source[pos])) || source[pos] == '_') {
+				pos++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: source[start:pos], pos: start})
+		case c == '(':
+			tokens = append(tokens, exprToken{kind: tokenLeftParen, text: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, exprToken{kind: tokenRightParen, text: ")", pos: pos})
+			pos++
+		case c == ',':
+			tokens = append(tokens, exprToken{kind: tokenComma, text: ",", pos: pos})
+			pos++
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(source[pos:], op) {
+					tokens = append(tokens, exprToken{kind: tokenOperator, text: op, pos: pos})
+					pos += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, pos)
+			}
+		}
+	}
+	tokens = append(tokens, exprToken{kind: tokenEnd, text: "end of expression", pos: len(source)})
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens    []exprToken
+	pos       int
+	variables map[string]int
+}
+
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+	"^": 8,
+}
+
+const unaryPrecedence = 7
+
+func (parser *exprParser) peek() exprToken {
+	return parser.tokens[parser.pos]
+}
+
+func (parser *exprParser) next() exprToken {
+	token := parser.tokens[parser.pos]
+	if token.kind != tokenEnd {
+		parser.pos++
+	}
+	return token
+}
+
+func (parser *exprParser) parseBinary(minPrecedence int) (exprNode, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token := parser.peek()
+		precedence, ok := binaryPrecedence[token.text]
+		if token.kind != tokenOperator || !ok || precedence < minPrecedence {
+			return left, nil
+		}
+		parser.next()
+
+		nextPrecedence := precedence + 1
+		if token.text == "^" {
+			nextPrecedence = precedence
+		}
+		right, err := parser.parseBinary(nextPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		left = buildBinary(token.text, left, right)
+	}
+}
+
+func (parser *exprParser) parseUnary() (exprNode, error) {
+	token := parser.peek()
+	if token.kind == tokenOperator && (token.text == "-" || token.text == "+" || token.text == "!") {
+		parser.next()
+		operand, err := parser.parseBinary(unaryPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		switch token.text {
+		case "-":
+			return func(values []float64) float64 { return -operand(values) }, nil
+		case "!":
+			return func(values []float64) float64 {
+				v := operand(values)
+				if math.IsNaN(v) {
+					return v
+				}
+				return boolValue(v == 0)
+			}, nil
+		}
+		return operand, nil
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *exprParser) parsePrimary() (exprNode, error) {
+	token := parser.next()
+	switch token.kind {
+	case tokenNumber:
+		value := token.value
+		return func([]float64) float64 { return value }, nil
+	case tokenLeftParen:
+		node, err := parser.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if closing := parser.next(); closing.kind != tokenRightParen {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+		return node, nil
+	case tokenIdent:
+		if parser.peek().kind == tokenLeftParen {
+			return parser.parseCall(token)
+		}
+		if value, ok := exprConstants[token.text]; ok {
+			return func([]float64) float64 { return value }, nil
+		}
+		index, ok := parser.variables[token.text]
+		if !ok {
+			index = len(parser.variables)
+			parser.variables[token.text] = index
+		}
+		return func(values []float64) float64 { return values[index] }, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", token.text, token.pos)
+}
+
+func (parser *exprParser) parseCall(name exprToken) (exprNode, error) {
+	function, ok := exprFunctions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name.text, name.pos)
+	}
+	parser.next()
+
+	var args []exprNode
+	if parser.peek().kind != tokenRightParen {
+		for {
+			arg, err := parser.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if parser.peek().kind != tokenComma {
+				break
+			}
+			parser.next()
+		}
+	}
+	if closing := parser.next(); closing.kind != tokenRightParen {
+		return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+	}
+
+	if len(args) < function.minArgs || (function.maxArgs >= 0 && len(args) > function.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments to %s at position %d", name.text, name.pos)
+	}
+
+	return function.build(args), nil
+}
+
+func buildBinary(op string, left, right exprNode) exprNode {
+	switch op {
+	case "+":
+		return func(values []float64) float64 { return left(values) + right(values) }
+	case "-":
+		return func(values []float64) float64 { return left(values) - right(values) }
+	case "*":
+		return func(values []float64) float64 { return left(values) * right(values) }
+	case "/":
+		return func(values []float64) float64 { return left(values) / right(values) }
+	case "%":
+		return func(values []float64) float64 { return math.Mod(left(values), right(values)) }
+	case "^":
+		return func(values []float64) float64 { return math.Pow(left(values), right(values)) }
+	}
+
+	var compare func(a, b float64) bool
+	switch op {
+	case "<":
+		compare = func(a, b float64) bool { return a < b }
+	case "<=":
+		compare = func(a, b float64) bool { return a <= b }
+	case ">":
+		compare = func(a, b float64) bool { return a > b }
+	case ">=":
+		compare = func(a, b float64) bool { return a >= b }
+	case "==":
+		compare = func(a, b float64) bool { return a == b }
+	case "!=":
+		compare = func(a, b float64) bool { return a != b }
+	case "&&":
+		compare = func(a, b float64) bool { return a != 0 && b != 0 }
+	case "||":
+		compare = func(a, b float64) bool { return a != 0 || b != 0 }
+	}
+
+	return func(values []float64) float64 {
+		a := left(values)
+		b := right(values)
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN()
+		}
+		return boolValue(compare(a, b))
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func unaryFunction(fn func(float64) float64) exprFunction {
+	return exprFunction{minArgs: 1, maxArgs: 1, build: func(args []exprNode) exprNode {
+		arg := args[0]
+		return func(values []float64) float64 { return fn(arg(values)) }
+	}}
+}
+
+func binaryFunction(fn func(float64, float64) float64) exprFunction {
+	return exprFunction{minArgs: 2, maxArgs: 2, build: func(args []exprNode) exprNode {
+		a, b := args[0], args[1]
+		return func(values []float64) float64 { return fn(a(values), b(values)) }
+	}}
+}
+
+func foldFunction(fn func(float64, float64) float64) func(args []exprNode) exprNode {
+	return func(args []exprNode) exprNode {
+		return func(values []float64) float64 {
+			result := args[0](values)
+			for _, arg := range args[1:] {
+				result = fn(result, arg(values))
+			}
+			return result
+		}
+	}
+}
+
+func buildClamp(args []exprNode) exprNode {
+	return func(values []float64) float64 {
+		return math.Max(args[1](values), math.Min(args[0](values), args[2](values)))
+	}
+}
+
+func buildWhere(args []exprNode) exprNode {
+	return func(values []float64) float64 {
+		condition := args[0](values)
+		if math.IsNaN(condition) {
+			return math.NaN()
+		}
+		if condition != 0 {
+			return args[1](values)
+		}
+		return args[2](values)
+	}
+}
+
+func buildIsNodata(args []exprNode) exprNode {
+	return func(values []float64) float64 {
+		return boolValue(math.IsNaN(args[0](values)))
+	}
+}
+
+func buildCoalesce(args []exprNode) exprNode {
+	return func(values []float64) float64 {
+		for _, arg := range args {
+			if value := arg(values); !math.IsNaN(value) {
+				return value
+			}
+		}
+		return math.NaN()
+	}
+}
@@ -0,0 +1,95 @@
+package asctools
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+type ColorRamp int
+
+const (
+	RampGray ColorRamp = iota
+	// RampTerrain is a hypsometric tint from lowland green to snow white.
+	RampTerrain
+	RampViridis
+)
+
+type colorStop struct {
+	position float64
+	color    color.RGBA
+}
+
+var colorRampStops = map[ColorRamp][]colorStop{
+	RampGray: {
+		{0, color.RGBA{0, 0, 0, 255}},
+		{1, color.RGBA{255, 255, 255, 255}},
+	},
+	RampTerrain: {
+		{0.00, color.RGBA{51, 102, 51, 255}},
+		{0.15, color.RGBA{102, 153, 68, 255}},
+		{0.35, color.RGBA{204, 204, 119, 255}},
+		{0.55, color.RGBA{170, 119, 68, 255}},
+		{0.75, color.RGBA{136, 102, 85, 255}},
+		{0.90, color.RGBA{204, 204, 204, 255}},
+		{1.00, color.RGBA{255, 255, 255, 255}},
+	},
+	RampViridis: {
+		{0.00, color.RGBA{68, 1, 84, 255}},
+		{0.25, color.RGBA{59, 82, 139, 255}},
+		{0.50, color.RGBA{33, 145, 140, 255}},
+		{0.75, color.RGBA{94, 201, 98, 255}},
+		{1.00, color.RGBA{253, 231, 37, 255}},
+	},
+}
+
+func ParseColorRamp(value string) (ColorRamp, error) {
+	switch value {
+	case "gray", "grey", "":
+		return RampGray, nil
+	case "terrain":
+		return RampTerrain, nil
+	case "viridis":
+		return RampViridis, nil
+	default:
+		return RampGray, fmt.Errorf("unknown color ramp: %s", value)
+	}
+}
+
+func (ramp ColorRamp) String() string {
+	switch ramp {
+	case RampTerrain:
+		return "terrain"
+	case RampViridis:
+		return "viridis"
+	default:
+		return "gray"
+	}
+}
+
+// Color returns the ramp colour at position t in the [0, 1] range.
+func (ramp ColorRamp) Color(t float64) color.RGBA {
+	return interpolateStops(colorRampStops[ramp], t)
+}
+
+func interpolateStops(stops []colorStop, t float64) color.RGBA {
+	if math.IsNaN(t) || t <= stops[0].position {
+		return stops[0].color
+	}
+	for i := 1; i < len(stops); i++ {
+		if t <= stops[i].position {
+			a, b := stops[i-1], stops[i]
+			f := (t - a.position) / (b.position - a.position)
+			mix := func(x, y uint8) uint8 {
+				return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f))
+			}
+			return color.RGBA{
+				R: mix(a.color.R, b.color.R),
+				G: mix(a.color.G, b.color.G),
+				B: mix(a.color.B, b.color.B),
+				A: 255,
+			}
+		}
+	}
+	return stops[len(stops)-1].color
+}
@@ -0,0 +1,633 @@
+package asctools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CRS is a coordinate reference system. EPSG is 0 for a CRS read from WKT
+// that matches no supported EPSG code; it is kept and written back as read.
+type CRS struct {
+	EPSG int
+	Name string
+	// WKT is the definition written to .prj files, OGC WKT 1 for CRSs
+	// parsed from an EPSG code.
+	WKT string
+}
+
+type ellipsoid struct {
+	name              string
+	epsg              int
+	semiMajorAxis     float64
+	inverseFlattening float64
+}
+
+type geographicCRS struct {
+	name      string
+	epsg      int
+	datum     string
+	datumEPSG int
+	ellipsoid ellipsoid
+	// toWGS84 is zero for datums within a metre or so of WGS84.
+	toWGS84 helmert
+}
+
+var (
+	wgs84Ellipsoid             = ellipsoid{"WGS 84", 7030, wgs84SemiMajorAxis, 298.257223563}
+	grs80Ellipsoid             = ellipsoid{"GRS 1980", 7019, wgs84SemiMajorAxis, 298.257222101}
+	airy1830Ellipsoid          = ellipsoid{"Airy 1830", 7001, 6377563.396, 299.3249646}
+	bessel1841Ellipsoid        = ellipsoid{"Bessel 1841", 7004, 6377397.155, 299.1528128}
+	international1924Ellipsoid = ellipsoid{"International 1924", 7022, 6378388, 297}
+
+	wgs84Geographic  = geographicCRS{name: "WGS 84", epsg: 4326, datum: "WGS_1984", datumEPSG: 6326, ellipsoid: wgs84Ellipsoid}
+	etrs89Geographic = geographicCRS{name: "ETRS89", epsg: 4258, datum: "European_Terrestrial_Reference_System_1989", datumEPSG: 6258, ellipsoid: grs80Ellipsoid}
+	nad83Geographic  = geographicCRS{name: "NAD83", epsg: 4269, datum: "North_American_Datum_1983", datumEPSG: 6269, ellipsoid: grs80Ellipsoid}
+	rgf93Geographic  = geographicCRS{name: "RGF93 v1", epsg: 4171, datum: "Reseau_Geodesique_Francais_1993", datumEPSG: 6171, ellipsoid: grs80Ellipsoid}
+	osgb36Geographic = geographicCRS{name: "OSGB36", epsg: 4277, datum: "OSGB_1936", datumEPSG: 6277, ellipsoid: airy1830Ellipsoid,
+		toWGS84: helmert{446.448, -125.157, 542.06, 0.15, 0.247, 0.842, -20.489}}
+	dhdnGeographic = geographicCRS{name: "DHDN", epsg: 4314, datum: "Deutsches_Hauptdreiecksnetz", datumEPSG: 6314, ellipsoid: bessel1841Ellipsoid,
+		toWGS84: helmert{598.1, 73.7, 418.2, 0.202, 0.045, -2.455, 6.7}}
+	bd72Geographic = geographicCRS{name: "BD72", epsg: 4313, datum: "Reseau_National_Belge_1972", datumEPSG: 6313, ellipsoid: international1924Ellipsoid,
+		toWGS84: helmert{-106.8686, 52.2978, -103.7239, 0.3366, -0.457, 1.8422, -1.2747}}
+
+	geographicCRSs = []geographicCRS{wgs84Geographic, etrs89Geographic, nad83Geographic, rgf93Geographic, osgb36Geographic, dhdnGeographic, bd72Geographic}
+)
+
+type projectionMethod int
+
+const (
+	methodGeographic projectionMethod = iota
+	methodWebMercator
+	methodTransverseMercator
+	methodLambertConformalConic
+)
+
+type crsDefinition struct {
+	name              string
+	base              geographicCRS
+	method            projectionMethod
+	centralMeridian   float64
+	latitudeOfOrigin  float64
+	standardParallel1 float64
+	standardParallel2 float64
+	scale             float64
+	falseEasting      float64
+	falseNorthing     float64
+}
+
+// lookupEPSG returns the definition of a supported EPSG code. ETRS89, NAD83
+// and RGF93 are treated as WGS84 by the projections, which is exact to
+// within a metre or two; older datums are shifted with their Helmert
+// parameters, which are good to a few metres.
+func lookupEPSG(epsg int) (crsDefinition, bool) {
+	for _, geographic := range geographicCRSs {
+		if geographic.epsg == epsg {
+			return crsDefinition{name: geographic.name, base: geographic, method: methodGeographic}, true
+		}
+	}
+	switch {
+	case epsg == 3857 || epsg == 900913:
+		return crsDefinition{name: "WGS 84 / Pseudo-Mercator", base: wgs84Geographic, method: methodWebMercator, scale: 1}, true
+	case epsg > 32600 && epsg <= 32660, epsg > 32700 && epsg <= 32760:
+		zone, hemisphere, falseNorthing := epsg%100, "N", 0.0
+		if epsg > 32700 {
+			hemisphere, falseNorthing = "S", 10000000
+		}
+		return crsDefinition{
+			name: fmt.Sprintf("WGS 84 / UTM zone %d%s", zone, hemisphere), base: wgs84Geographic, method: methodTransverseMercator,
+			centralMeridian: float64(zone)*6 - 183, scale: 0.9996, falseEasting: 500000, falseNorthing: falseNorthing,
+		}, true
+	case epsg >= 25828 && epsg <= 25838, epsg >= 26903 && epsg <= 26923:
+		zone, base := epsg-25800, etrs89Geographic
+		if epsg > 26900 {
+			zone, base = epsg-26900, nad83Geographic
+		}
+		return crsDefinition{
+			name: fmt.Sprintf("%s / UTM zone %dN", base.name, zone), base: base, method: methodTransverseMercator,
+			centralMeridian: float64(zone)*6 - 183, scale: 0.9996, falseEasting: 500000,
+		}, true
+	case epsg == 2180:
+		return crsDefinition{
+			name: "ETRS89 / Poland CS92", base: etrs89Geographic, method: methodTransverseMercator,
+			centralMeridian: 19, scale: 0.9993, falseEasting: 500000, falseNorthing: -5300000,
+		}, true
+	case epsg >= 2176 && epsg <= 2179:
+		zone := epsg - 2176 + 5
+		return crsDefinition{
+			name: fmt.Sprintf("ETRS89 / Poland CS2000 zone %d", zone), base: etrs89Geographic, method: methodTransverseMercator,
+			centralMeridian: float64(zone) * 3, scale: 0.999923, falseEasting: float64(zone)*1000000 + 500000,
+		}, true
+	case epsg >= 31466 && epsg <= 31469:
+		zone := epsg - 31464
+		return crsDefinition{
+			name: fmt.Sprintf("DHDN / 3-degree Gauss-Kruger zone %d", zone), base: dhdnGeographic, method: methodTransverseMercator,
+			centralMeridian: float64(zone) * 3, scale: 1, falseEasting: float64(zone)*1000000 + 500000,
+		}, true
+	case epsg == 27700:
+		return crsDefinition{
+			name: "OSGB36 / British National Grid", base: osgb36Geographic, method: methodTransverseMercator,
+			centralMeridian: -2, latitudeOfOrigin: 49, scale: 0.9996012717, falseEasting: 400000, falseNorthing: -100000,
+		}, true
+	case epsg == 2154:
+		return crsDefinition{
+			name: "RGF93 v1 / Lambert-93", base: rgf93Geographic, method: methodLambertConformalConic,
+			centralMeridian: 3, latitudeOfOrigin: 46.5, standardParallel1: 49, standardParallel2: 44, falseEasting: 700000, falseNorthing: 6600000,
+		}, true
+	case epsg == 3034:
+		return crsDefinition{
+			name: "ETRS89-extended / LCC Europe", base: etrs89Geographic, method: methodLambertConformalConic,
+			centralMeridian: 10, latitudeOfOrigin: 52, standardParallel1: 35, standardParallel2: 65, falseEasting: 4000000, falseNorthing: 2800000,
+		}, true
+	case epsg == 31370:
+		return crsDefinition{
+			name: "BD72 / Belgian Lambert 72", base: bd72Geographic, method: methodLambertConformalConic,
+			centralMeridian: 4.36748666666667, latitudeOfOrigin: 90, standardParallel1: 51.1666672333333, standardParallel2: 49.8333339,
+			falseEasting: 150000.013, falseNorthing: 5400088.438,
+		}, true
+	default:
+		return crsDefinition{}, false
+	}
+}
+
+// supportedEPSGCodes lists the codes lookupEPSG knows, in the order WKT
+// without an EPSG code is matched against them.
+func supportedEPSGCodes() []int {
+	codes := []int{}
+	for _, geographic := range geographicCRSs {
+		codes = append(codes, geographic.epsg)
+	}
+	codes = append(codes, 3857, 2176, 2177, 2178, 2179, 2180, 27700, 31466, 31467, 31468, 31469, 2154, 3034, 31370)
+	for zone := 1; zone <= 60; zone++ {
+		codes = append(codes, 32600+zone, 32700+zone)
+	}
+	for zone := 3; zone <= 23; zone++ {
+		codes = append(codes, 26900+zone)
+	}
+	for zone := 28; zone <= 38; zone++ {
+		codes = append(codes, 25800+zone)
+	}
+	return codes
+}
+
+// ParseCRS parses a CRS given as an EPSG code, e.g. "EPSG:2180", or as WKT.
+func ParseCRS(definition string) (*CRS, error) {
+	if strings.Contains(definition, "[") {
+		return ParseWKT(definition)
+	}
+	code, ok := strings.CutPrefix(strings.ToUpper(strings.TrimSpace(definition)), "EPSG:")
+	if !ok {
+		return nil, fmt.Errorf("unsupported CRS definition: %s", definition)
+	}
+	epsg, err := strconv.Atoi(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPSG code: %s", code)
+	}
+	return CRSFromEPSG(epsg)
+}
+
+func CRSFromEPSG(epsg int) (*CRS, error) {
+	definition, ok := lookupEPSG(epsg)
+	if !ok {
+		return nil, fmt.Errorf("unsupported EPSG code: %d", epsg)
+	}
+	return &CRS{EPSG: epsg, Name: definition.name, WKT: definition.wkt(epsg)}, nil
+}
+
+func (crs *CRS) String() string {
+	if crs.EPSG == 0 {
+		return crs.Name
+	}
+	return fmt.Sprintf("EPSG:%d", crs.EPSG)
+}
+
+// Equal reports whether two CRSs are the same, by EPSG code when both have
+// one and by WKT otherwise.
+func (crs *CRS) Equal(other *CRS) bool {
+	if crs.EPSG != 0 && other.EPSG != 0 {
+		return crs.EPSG == other.EPSG
+	}
+	return strings.Join(strings.Fields(crs.WKT), "") == strings.Join(strings.Fields(other.WKT), "")
+}
+
+// Projection returns the conversion between coordinates of the CRS and WGS84
+// longitude and latitude, including the datum shift to WGS84.
+func (crs *CRS) Projection() (Projection, error) {
+	definition, ok := lookupEPSG(crs.EPSG)
+	if !ok {
+		return nil, fmt.Errorf("no projection for CRS %s", crs)
+	}
+	ellipsoid := definition.base.ellipsoid
+	var projection Projection
+	switch definition.method {
+	case methodWebMercator:
+		projection = webMercatorProjection{}
+	case methodTransverseMercator:
+		projection = newTransverseMercator(ellipsoid.semiMajorAxis, 1/ellipsoid.inverseFlattening,
+			definition.centralMeridian, definition.latitudeOfOrigin, definition.scale, definition.falseEasting, definition.falseNorthing)
+	case methodLambertConformalConic:
+		projection = newLambertConformalConic(ellipsoid.semiMajorAxis, 1/ellipsoid.inverseFlattening,
+			definition.centralMeridian, definition.latitudeOfOrigin, definition.standardParallel1, definition.standardParallel2,
+			definition.falseEasting, definition.falseNorthing)
+	default:
+		projection = geographicProjection{}
+	}
+	if !definition.base.toWGS84.isZero() {
+		projection = datumShiftProjection{projection, ellipsoid, definition.base.toWGS84}
+	}
+	return projection, nil
+}
+
+// commonCRS returns the CRS of the maps that have one. Maps without a CRS
+// are assumed to share it, maps with different CRSs are an error.
+func commonCRS(maps ...*ElevationMap) (*CRS, error) {
+	var crs *CRS
+	for _, elevationMap := range maps {
+		if elevationMap.CRS == nil {
+			continue
+		}
+		if crs == nil {
+			crs = elevationMap.CRS
+		} else if !crs.Equal(elevationMap.CRS) {
+			return nil, fmt.Errorf("maps have different CRSs: %s and %s", crs, elevationMap.CRS)
+		}
+	}
+	return crs, nil
+}
+
+// prjPath returns the path of the .prj sidecar of a data file.
+func prjPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".prj"
+}
+
+// readPRJ reads the .prj sidecar of a data file, nil when there is none.
+func readPRJ(path string) (*CRS, error) {
+	data, err := os.ReadFile(prjPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	crs, err := ParseWKT(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", prjPath(path), err)
+	}
+	return crs, nil
+}
+
+// writePRJ writes the .prj sidecar of a data file when crs is known.
+func writePRJ(path string, crs *CRS) error {
+	if crs == nil {
+		return nil
+	}
+	return os.WriteFile(prjPath(path), []byte(crs.WKT), 0644)
+}
+
+// ParseWKT parses a WKT 1 or WKT 2 definition, as found in .prj files. Its
+// EPSG code is taken from the AUTHORITY or ID of the CRS or, for ESRI style
+// WKT without one, from a supported CRS with the same projection parameters.
+func ParseWKT(wkt string) (*CRS, error) {
+	root, err := parseWKTNode(wkt)
+	if err != nil {
+		return nil, err
+	}
+	name := ""
+	if len(root.values) > 0 {
+		name = root.values[0]
+	}
+	crs := &CRS{Name: name, WKT: strings.TrimSpace(wkt)}
+
+	if epsg := root.epsgCode(); epsg != 0 {
+		crs.EPSG = epsg
+		if definition, ok := lookupEPSG(epsg); ok {
+			crs.Name = definition.name
+		}
+		return crs, nil
+	}
+	for _, epsg := range supportedEPSGCodes() {
+		definition, _ := lookupEPSG(epsg)
+		if definition.matches(root) {
+			crs.EPSG, crs.Name = epsg, definition.name
+			break
+		}
+	}
+	return crs, nil
+}
+
+// matches reports whether a WKT CRS has the ellipsoid, projection and
+// parameters of the definition.
+func (definition crsDefinition) matches(root *wktNode) bool {
+	spheroid := root.find("SPHEROID", "ELLIPSOID")
+	if spheroid == nil || len(spheroid.values) < 3 {
+		return false
+	}
+	inverseFlattening, err := strconv.ParseFloat(spheroid.values[2], 64)
+	if err != nil || math.Abs(inverseFlattening-definition.base.ellipsoid.inverseFlattening) > 1e-6 {
+		return false
+	}
+
+	switch root.keyword {
+	case "GEOGCS", "GEOGCRS", "GEODCRS":
+		return definition.method == methodGeographic
+	case "PROJCS", "PROJCRS":
+	default:
+		return false
+	}
+	projection := root.find("PROJECTION", "METHOD")
+	if projection == nil || len(projection.values) == 0 {
+		return false
+	}
+	method := strings.ToLower(strings.ReplaceAll(projection.values[0], " ", "_"))
+	switch {
+	case definition.method == methodTransverseMercator && method == "transverse_mercator":
+	case definition.method == methodWebMercator && strings.Contains(method, "mercator"):
+	case definition.method == methodLambertConformalConic &&
+		(strings.Contains(method, "lambert_conformal_conic") || strings.Contains(method, "lambert_conic_conformal")):
+	default:
+		return false
+	}
+
+	parameters := root.parameters()
+	for name, value := range map[string]float64{
+		"central_meridian":   definition.centralMeridian,
+		"latitude_of_origin": definition.latitudeOfOrigin,
+		"false_easting":      definition.falseEasting,
+		"false_northing":     definition.falseNorthing,
+	} {
+		if math.Abs(parameters[name]-value) > 1e-3 {
+			return false
+		}
+	}
+	if definition.method == methodLambertConformalConic {
+		// The standard parallels are interchangeable and listed in either
+		// order.
+		parallel1, parallel2 := parameters["standard_parallel_1"], parameters["standard_parallel_2"]
+		if math.Abs(parallel1-definition.standardParallel2) < 1e-6 && math.Abs(parallel2-definition.standardParallel1) < 1e-6 {
+			parallel1, parallel2 = parallel2, parallel1
+		}
+		return math.Abs(parallel1-definition.standardParallel1) < 1e-6 && math.Abs(parallel2-definition.standardParallel2) < 1e-6
+	}
+	if scale, ok := parameters["scale_factor"]; ok && math.Abs(scale-definition.scale) > 1e-9 {
+		return false
+	}
+	return true
+}
+
+func (definition crsDefinition) wkt(epsg int) string {
+	geographic := definition.base.wkt()
+	switch definition.method {
+	case methodGeographic:
+		return geographic
+	case methodWebMercator:
+		return fmt.Sprintf(`PROJCS["%s",%s,PROJECTION["Mercator_1SP"],%s,%s,`+
+			`EXTENSION["PROJ4","+proj=merc +a=6378137 +b=6378137 +lat_ts=0 +lon_0=0 +x_0=0 +y_0=0 +k=1 +units=m +nadgrids=@null +wktext +no_defs"],%s]`,
+			definition.name, geographic, definition.wktParameters("central_meridian", "scale_factor", "false_easting", "false_northing"),
+			wktMetre, wktAuthority(epsg))
+	case methodLambertConformalConic:
+		return fmt.Sprintf(`PROJCS["%s",%s,PROJECTION["Lambert_Conformal_Conic_2SP"],%s,%s,%s]`,
+			definition.name, geographic, definition.wktParameters("standard_parallel_1", "standard_parallel_2", "latitude_of_origin", "central_meridian", "false_easting", "false_northing"),
+			wktMetre, wktAuthority(epsg))
+	default:
+		return fmt.Sprintf(`PROJCS["%s",%s,PROJECTION["Transverse_Mercator"],%s,%s,%s]`,
+			definition.name, geographic, definition.wktParameters("latitude_of_origin", "central_meridian", "scale_factor", "false_easting", "false_northing"),
+			wktMetre, wktAuthority(epsg))
+	}
+}
+
+func (definition crsDefinition) wktParameters(names ...string) string {
+	values := map[string]float64{
+		"latitude_of_origin":  definition.latitudeOfOrigin,
+		"central_meridian":    definition.centralMeridian,
+		"standard_parallel_1": definition.standardParallel1,
+		"standard_parallel_2": definition.standardParallel2,
+		"scale_factor":        definition.scale,
+		"false_easting":       definition.falseEasting,
+		"false_northing":      definition.falseNorthing,
+	}
+	parameters := make([]string, len(names))
+	for i, name := range names {
+		parameters[i] = fmt.Sprintf(`PARAMETER["%s",%s]`, name, strconv.FormatFloat(values[name], 'f', -1, 64))
+	}
+	return strings.Join(parameters, ",")
+}
+
+func (geographic geographicCRS) wkt() string {
+	ellipsoid := geographic.ellipsoid
+	toWGS84 := ""
+	if !geographic.toWGS84.isZero() {
+		values := make([]string, len(geographic.toWGS84))
+		for i, value := range geographic.toWGS84 {
+			values[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		toWGS84 = fmt.Sprintf(`TOWGS84[%s],`, strings.Join(values, ","))
+	}
+	return fmt.Sprintf(`GEOGCS["%s",DATUM["%s",SPHEROID["%s",%s,%s,%s],%s%s],PRIMEM["Greenwich",0,%s],UNIT["degree",0.0174532925199433,%s],%s]`,
+		geographic.name, geographic.datum, ellipsoid.name,
+		strconv.FormatFloat(ellipsoid.semiMajorAxis, 'f', -1, 64), strconv.FormatFloat(ellipsoid.inverseFlattening, 'f', -1, 64),
+		wktAuthority(ellipsoid.epsg), toWGS84, wktAuthority(geographic.datumEPSG), wktAuthority(8901), wktAuthority(9122), wktAuthority(geographic.epsg))
+}
+
+const wktMetre = `UNIT["metre",1,AUTHORITY["EPSG","9001"]]`
+
+func wktAuthority(epsg int) string {
+	return fmt.Sprintf(`AUTHORITY["EPSG","%d"]`, epsg)
+}
+
+// wktNode is a keyword with its bracketed values: quoted strings, numbers
+// and bare keywords in values, nested nodes in children.
+type wktNode struct {
+	keyword  string
+	values   []string
+	children []*wktNode
+}
+
+func parseWKTNode(text string) (*wktNode, error) {
+	parser := &wktParser{text: strings.TrimSpace(text)}
+	node, err := parser.node()
+	if err != nil {
+		return nil, fmt.Errorf("invalid WKT: %v", err)
+	}
+	parser.skipSpace()
+	if parser.pos < len(parser.text) {
+		return nil, fmt.Errorf("invalid WKT: unexpected text at %d", parser.pos)
+	}
+	return node, nil
+}
+
+type wktParser struct {
+	text string
+	pos  int
+}
+
+func (parser *wktParser) skipSpace() {
+	for parser.pos < len(parser.text) && strings.ContainsRune(" \t\r\n", rune(parser.text[parser.pos])) {
+		parser.pos++
+	}
+}
+
+func (parser *wktParser) keyword() string {
+	start := parser.pos
+	for parser.pos < len(parser.text) {
+		c := parser.text[parser.pos]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			break
+		}
+		parser.pos++
+	}
+	return parser.text[start:parser.pos]
+}
+
+func (parser *wktParser) node() (*wktNode, error) {
+	parser.skipSpace()
+	keyword := parser.keyword()
+	if keyword == "" {
+		return nil, fmt.Errorf("expected a keyword at %d", parser.pos)
+	}
+	node := &wktNode{keyword: strings.ToUpper(keyword)}
+	parser.skipSpace()
+	if parser.pos >= len(parser.text) || parser.text[parser.pos] != '[' && parser.text[parser.pos] != '(' {
+		return nil, fmt.Errorf("expected [ after %s", keyword)
+	}
+	parser.pos++
+
+	for {
+		parser.skipSpace()
+		if parser.pos >= len(parser.text) {
+			return nil, fmt.Errorf("unterminated %s", keyword)
+		}
+		switch c := parser.text[parser.pos]; {
+		case c == '"':
+			value, err := parser.quoted()
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+		case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+			start := parser.pos
+			for parser.pos < len(parser.text) && strings.ContainsRune("+-.eE0123456789", rune(parser.text[parser.pos])) {
+				parser.pos++
+			}
+			node.values = append(node.values, parser.text[start:parser.pos])
+		default:
+			start := parser.pos
+			bare := parser.keyword()
+			parser.skipSpace()
+			if parser.pos < len(parser.text) && (parser.text[parser.pos] == '[' || parser.text[parser.pos] == '(') {
+				parser.pos = start
+				child, err := parser.node()
+				if err != nil {
+					return nil, err
+				}
+				node.children = append(node.children, child)
+			} else if bare != "" {
+				node.values = append(node.values, bare)
+			} else {
+				return nil, fmt.Errorf("unexpected %q at %d", c, parser.pos)
+			}
+		}
+
+		parser.skipSpace()
+		if parser.pos >= len(parser.text) {
+			return nil, fmt.Errorf("unterminated %s", keyword)
+		}
+		switch parser.text[parser.pos] {
+		case ',':
+			parser.pos++
+		case ']', ')':
+			parser.pos++
+			return node, nil
+		default:
+			return nil, fmt.Errorf("expected , or ] at %d", parser.pos)
+		}
+	}
+}
+
+// quoted reads a quoted string, in which "" stands for a quote.
+func (parser *wktParser) quoted() (string, error) {
+	var value strings.Builder
+	for parser.pos++; parser.pos < len(parser.text); parser.pos++ {
+		c := parser.text[parser.pos]
+		if c != '"' {
+			value.WriteByte(c)
+			continue
+		}
+		if parser.pos+1 < len(parser.text) && parser.text[parser.pos+1] == '"' {
+			value.WriteByte('"')
+			parser.pos++
+			continue
+		}
+		parser.pos++
+		return value.String(), nil
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// find returns the first node with one of the keywords, depth first.
+func (node *wktNode) find(keywords ...string) *wktNode {
+	for _, child := range node.children {
+		for _, keyword := range keywords {
+			if child.keyword == keyword {
+				return child
+			}
+		}
+		if found := child.find(keywords...); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// epsgCode returns the EPSG code of the node itself, 0 when it has none.
+func (node *wktNode) epsgCode() int {
+	for _, child := range node.children {
+		if (child.keyword == "AUTHORITY" || child.keyword == "ID") && len(child.values) >= 2 && strings.EqualFold(child.values[0], "EPSG") {
+			if epsg, err := strconv.Atoi(child.values[1]); err == nil {
+				return epsg
+			}
+		}
+	}
+	return 0
+}
+
+// parameters returns the projection parameters by their WKT 1 names in
+// lower case, with the WKT 2 names of the Transverse Mercator and Lambert
+// Conformal Conic parameters mapped to them.
+func (node *wktNode) parameters() map[string]float64 {
+	aliases := map[string]string{
+		"longitude_of_natural_origin":       "central_meridian",
+		"latitude_of_natural_origin":        "latitude_of_origin",
+		"scale_factor_at_natural_origin":    "scale_factor",
+		"longitude_of_false_origin":         "central_meridian",
+		"latitude_of_false_origin":          "latitude_of_origin",
+		"latitude_of_1st_standard_parallel": "standard_parallel_1",
+		"latitude_of_2nd_standard_parallel": "standard_parallel_2",
+		"easting_at_false_origin":           "false_easting",
+		"northing_at_false_origin":          "false_northing",
+	}
+	parameters := map[string]float64{}
+	for _, child := range node.children {
+		if child.keyword != "PARAMETER" || len(child.values) < 2 {
+			continue
+		}
+		name := strings.ToLower(strings.ReplaceAll(child.values[0], " ", "_"))
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		if value, err := strconv.ParseFloat(child.values[1], 64); err == nil {
+			parameters[name] = value
+		}
+	}
+	// WKT 2 nests the parameters in CONVERSION.
+	if conversion := node.find("CONVERSION"); conversion != nil {
+		for name, value := range conversion.parameters() {
+			parameters[name] = value
+		}
+	}
+	return parameters
+}
@@ -0,0 +1,112 @@
+package asctools
+
+import (
+	"math"
+)
+
+// helmert is a seven parameter datum shift to WGS84 in the position vector
+// convention of EPSG and the TOWGS84 WKT node: translations in metres,
+// rotations in arc seconds and the scale difference in parts per million.
+type helmert [7]float64
+
+func (shift helmert) isZero() bool {
+	return shift == helmert{}
+}
+
+// matrix returns the linearized rotation and scale of the shift.
+func (shift helmert) matrix() [3][3]float64 {
+	arcSecond := math.Pi / (180 * 3600)
+	rx, ry, rz := shift[3]*arcSecond, shift[4]*arcSecond, shift[5]*arcSecond
+	scale := 1 + shift[6]*1e-6
+	return [3][3]float64{
+		{scale, -rz * scale, ry * scale},
+		{rz * scale, scale, -rx * scale},
+		{-ry * scale, rx * scale, scale},
+	}
+}
+
+func (shift helmert) apply(p [3]float64) [3]float64 {
+	m := shift.matrix()
+	var result [3]float64
+	for i := range result {
+		result[i] = shift[i] + m[i][0]*p[0] + m[i][1]*p[1] + m[i][2]*p[2]
+	}
+	return result
+}
+
+// invert undoes apply exactly by Cramer's rule, rather than by negating the
+// parameters, which is off by millimetres.
+func (shift helmert) invert(p [3]float64) [3]float64 {
+	m := shift.matrix()
+	d := [3]float64{p[0] - shift[0], p[1] - shift[1], p[2] - shift[2]}
+	det := determinant(m)
+	var result [3]float64
+	for i := range result {
+		c := m
+		for row := range c {
+			c[row][i] = d[row]
+		}
+		result[i] = determinant(c) / det
+	}
+	return result
+}
+
+func determinant(m [3][3]float64) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+func (e ellipsoid) eccentricitySquared() float64 {
+	f := 1 / e.inverseFlattening
+	return f * (2 - f)
+}
+
+func (e ellipsoid) toECEF(lon, lat, height float64) [3]float64 {
+	e2 := e.eccentricitySquared()
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	n := e.semiMajorAxis / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return [3]float64{
+		(n + height) * math.Cos(phi) * math.Cos(lambda),
+		(n + height) * math.Cos(phi) * math.Sin(lambda),
+		(n*(1-e2) + height) * math.Sin(phi),
+	}
+}
+
+// fromECEF iterates Bowring's latitude, which converges to well under a
+// millimetre in a few steps near the surface.
+func (e ellipsoid) fromECEF(p [3]float64) (lon, lat, height float64) {
+	e2 := e.eccentricitySquared()
+	distance := math.Hypot(p[0], p[1])
+	phi := math.Atan2(p[2], distance*(1-e2))
+	for i := 0; i < 5; i++ {
+		sinPhi := math.Sin(phi)
+		n := e.semiMajorAxis / math.Sqrt(1-e2*sinPhi*sinPhi)
+		height = distance/math.Cos(phi) - n
+		phi = math.Atan2(p[2], distance*(1-e2*n/(n+height)))
+	}
+	return math.Atan2(p[1], p[0]) * 180 / math.Pi, phi * 180 / math.Pi, height
+}
+
+// datumShiftProjection converts the longitude and latitude of a projection
+// on another datum to and from WGS84. Heights are taken to be on the
+// ellipsoid, and the height change of the shift is dropped, since elevations
+// are not changed by reprojection.
+type datumShiftProjection struct {
+	projection Projection
+	ellipsoid  ellipsoid
+	shift      helmert
+}
+
+func (datum datumShiftProjection) ToGeographic(x, y float64) (float64, float64) {
+	lon, lat := datum.projection.ToGeographic(x, y)
+	p := datum.shift.apply(datum.ellipsoid.toECEF(lon, lat, 0))
+	lon, lat, _ = wgs84Ellipsoid.fromECEF(p)
+	return lon, lat
+}
+
+func (datum datumShiftProjection) FromGeographic(lon, lat float64) (float64, float64) {
+	p := datum.shift.invert(wgs84Ellipsoid.toECEF(lon, lat, 0))
+	lon, lat, _ = datum.ellipsoid.fromECEF(p)
+	return datum.projection.FromGeographic(lon, lat)
+}
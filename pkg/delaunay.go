@@ -0,0 +1,226 @@
+package asctools
+
+type delaunayPoint struct {
+	X, Y float64
+}
+
+// delaunayTriangle stores its vertices counter-clockwise. neighbours[i] is
+// the triangle across the edge from vertices[i] to vertices[(i+1)%3], or -1
+// on the hull.
+type delaunayTriangle struct {
+	vertices   [3]int
+	neighbours [3]int
+	dead       bool
+}
+
+// delaunay is an incremental Bowyer-Watson triangulation. Points are
+// inserted one by one into an initial triangulation covering all of them.
+// Slots of removed triangles are reused, so callers that keep triangle
+// indices around have to check version.
+type delaunay struct {
+	points    []delaunayPoint
+	triangles []delaunayTriangle
+	version   []int
+	free      []int
+	live      int
+
+	cavity   []int
+	inCavity map[int]bool
+	created  []int
+}
+
+// newDelaunayRectangle starts a triangulation of the rectangle spanned by
+// the given corners. The corners are points 0 to 3, counter-clockwise from
+// the lower left one.
+func newDelaunayRectangle(minX, minY, maxX, maxY float64) *delaunay {
+	d := &delaunay{inCavity: map[int]bool{}}
+	d.points = append(d.points,
+		delaunayPoint{minX, minY},
+		delaunayPoint{maxX, minY},
+		delaunayPoint{maxX, maxY},
+		delaunayPoint{minX, maxY},
+	)
+	t0 := d.addTriangle(0, 1, 2)
+	t1 := d.addTriangle(0, 2, 3)
+	d.triangles[t0].neighbours = [3]int{-1, -1, t1}
+	d.triangles[t1].neighbours = [3]int{t0, -1, -1}
+	return d
+}
+
+func (d *delaunay) addPoint(x, y float64) int {
+	d.points = append(d.points, delaunayPoint{x, y})
+	return len(d.points) - 1
+}
+
+func (d *delaunay) addTriangle(a, b, c int) int {
+	t := delaunayTriangle{vertices: [3]int{a, b, c}, neighbours: [3]int{-1, -1, -1}}
+	d.live++
+	if n := len(d.free); n > 0 {
+		index := d.free[n-1]
+		d.free = d.free[:n-1]
+		d.triangles[index] = t
+		d.version[index]++
+		return index
+	}
+	d.triangles = append(d.triangles, t)
+	d.version = append(d.version, 0)
+	return len(d.triangles) - 1
+}
+
+func (d *delaunay) removeTriangle(index int) {
+	d.triangles[index].dead = true
+	d.free = append(d.free, index)
+	d.live--
+}
+
+func (d *delaunay) orient(a, b int, p delaunayPoint) float64 {
+	pa, pb := d.points[a], d.points[b]
+	return (pb.X-pa.X)*(p.Y-pa.Y) - (pb.Y-pa.Y)*(p.X-pa.X)
+}
+
+func (d *delaunay) inCircumcircle(triangle int, p delaunayPoint) bool {
+	v := d.triangles[triangle].vertices
+	a, b, c := d.points[v[0]], d.points[v[1]], d.points[v[2]]
+	adx, ady := a.X-p.X, a.Y-p.Y
+	bdx, bdy := b.X-p.X, b.Y-p.Y
+	cdx, cdy := c.X-p.X, c.Y-p.Y
+	det := (adx*adx+ady*ady)*(bdx*cdy-cdx*bdy) +
+		(bdx*bdx+bdy*bdy)*(cdx*ady-adx*cdy) +
+		(cdx*cdx+cdy*cdy)*(adx*bdy-bdx*ady)
+	return det > 0
+}
+
+// contains reports whether p is inside the triangle or on its boundary.
+func (d *delaunay) contains(triangle int, p delaunayPoint) bool {
+	v := d.triangles[triangle].vertices
+	for i := 0; i < 3; i++ {
+		if d.orient(v[i], v[(i+1)%3], p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// locate walks from start towards the triangle containing p. It returns
+// -1 when p is outside the triangulation.
+func (d *delaunay) locate(p delaunayPoint, start int) int {
+	if start < 0 || start >= len(d.triangles) || d.triangles[start].dead {
+		start = -1
+		for i := range d.triangles {
+			if !d.triangles[i].dead {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return -1
+		}
+	}
+
+	triangle := start
+	for steps := 0; steps <= 2*len(d.triangles); steps++ {
+		t := &d.triangles[triangle]
+		next := -1
+		for i := 0; i < 3; i++ {
+			// Rotating the starting edge keeps the walk from cycling.
+			edge := (i + steps) % 3
+			if d.orient(t.vertices[edge], t.vertices[(edge+1)%3], p) < 0 {
+				next = t.neighbours[edge]
+				if next < 0 {
+					return -1
+				}
+				break
+			}
+		}
+		if next < 0 {
+			return triangle
+		}
+		triangle = next
+	}
+
+	for i := range d.triangles {
+		if !d.triangles[i].dead && d.contains(i, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert adds point to the triangulation. triangle must contain the point.
+// It returns the created triangles, valid until the next call.
+func (d *delaunay) insert(point int, triangle int) []int {
+	p := d.points[point]
+
+	d.cavity = append(d.cavity[:0], triangle)
+	clear(d.inCavity)
+	d.inCavity[triangle] = true
+	for i := 0; i < len(d.cavity); i++ {
+		t := d.triangles[d.cavity[i]]
+		for edge := 0; edge < 3; edge++ {
+			n := t.neighbours[edge]
+			if n < 0 || d.inCavity[n] {
+				continue
+			}
+			// Neighbours across an edge the point lies on have to go as well,
+			// otherwise the new triangle on that edge would be degenerate.
+			if d.inCircumcircle(n, p) || d.orient(t.vertices[edge], t.vertices[(edge+1)%3], p) <= 0 {
+				d.inCavity[n] = true
+				d.cavity = append(d.cavity, n)
+			}
+		}
+	}
+
+	type boundaryEdge struct {
+		a, b, outside int
+	}
+	edges := []boundaryEdge{}
+	for _, index := range d.cavity {
+		t := d.triangles[index]
+		for edge := 0; edge < 3; edge++ {
+			n := t.neighbours[edge]
+			if n >= 0 && d.inCavity[n] {
+				continue
+			}
+			a, b := t.vertices[edge], t.vertices[(edge+1)%3]
+			if n < 0 && d.orient(a, b, p) <= 0 {
+				// The point is on this hull edge, which gets split.
+				continue
+			}
+			edges = append(edges, boundaryEdge{a, b, n})
+		}
+	}
+
+	for _, index := range d.cavity {
+		d.removeTriangle(index)
+	}
+
+	d.created = d.created[:0]
+	startsAt := make(map[int]int, len(edges))
+	endsAt := make(map[int]int, len(edges))
+	for _, e := range edges {
+		index := d.addTriangle(e.a, e.b, point)
+		d.triangles[index].neighbours[0] = e.outside
+		if e.outside >= 0 {
+			outside := &d.triangles[e.outside]
+			for i := 0; i < 3; i++ {
+				if outside.vertices[i] == e.b && outside.vertices[(i+1)%3] == e.a {
+					outside.neighbours[i] = index
+				}
+			}
+		}
+		startsAt[e.a] = index
+		endsAt[e.b] = index
+		d.created = append(d.created, index)
+	}
+	for _, index := range d.created {
+		t := &d.triangles[index]
+		if n, ok := startsAt[t.vertices[1]]; ok {
+			t.neighbours[1] = n
+		}
+		if n, ok := endsAt[t.vertices[0]]; ok {
+			t.neighbours[2] = n
+		}
+	}
+
+	return d.created
+}
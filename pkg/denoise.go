@@ -0,0 +1,591 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+)
+
+type DenoiseMethod int
+
+const (
+	DenoiseMedian DenoiseMethod = iota
+	DenoiseGaussian
+	DenoiseBilateral
+	DenoiseSpike
+)
+
+type NodataMode int
+
+const (
+	// NodataPreserve keeps nodata cells as nodata.
+	NodataPreserve NodataMode = iota
+	// NodataFillSmall fills a nodata cell only when fewer than
+	// FillMaxNodata cells of its window are nodata, which closes small
+	// gaps but leaves coastlines and large voids alone.
+	NodataFillSmall
+	// NodataFill fills every nodata cell that has enough valid neighbours.
+	NodataFill
+)
+
+type DenoiseOptions struct {
+	Method     DenoiseMethod
+	WindowSize int
+	NodataMode NodataMode
+	// FillMaxNodata is the gap size limit used by NodataFillSmall.
+	FillMaxNodata int
+	// MinValidNeighbours is the number of valid cells, the centre included,
+	// a window needs before its filtered value is accepted. Valid cells
+	// below it keep their value and nodata cells stay nodata.
+	MinValidNeighbours int
+	// Sigma is the spatial standard deviation in cells used by the Gaussian
+	// and bilateral filters. 0 picks a quarter of the window size.
+	Sigma float64
+	// RangeSigma is the elevation standard deviation of the bilateral filter.
+	RangeSigma float64
+	// SpikeThreshold is k in the spike filter, which replaces a cell by the
+	// local median only when it deviates from it by more than k·MAD.
+	SpikeThreshold float64
+	Workers        int
+}
+
+func DefaultDenoiseOptions() DenoiseOptions {
+	return DenoiseOptions{
+		Method:             DenoiseMedian,
+		WindowSize:         3,
+		NodataMode:         NodataPreserve,
+		FillMaxNodata:      3,
+		MinValidNeighbours: 1,
+		RangeSigma:         1.0,
+		SpikeThreshold:     3.0,
+	}
+}
+
+func ParseNodataMode(value string) (NodataMode, error) {
+	switch value {
+	case "preserve", "":
+		return NodataPreserve, nil
+	case "fill_small":
+		return NodataFillSmall, nil
+	case "fill":
+		return NodataFill, nil
+	default:
+		return NodataPreserve, fmt.Errorf("unknown nodata mode: %s", value)
+	}
+}
+
+func ParseDenoiseMethod(value string) (DenoiseMethod, error) {
+	switch value {
+	case "median", "":
+		return DenoiseMedian, nil
+	case "gaussian":
+		return DenoiseGaussian, nil
+	case "bilateral":
+		return DenoiseBilateral, nil
+	case "spike":
+		return DenoiseSpike, nil
+	default:
+		return DenoiseMedian, fmt.Errorf("unknown denoise method: %s", value)
+	}
+}
+
+func (elevationMap *ElevationMap) Denoise(options DenoiseOptions) (*ElevationMap, error) {
+	if options.WindowSize%2 == 0 || options.WindowSize < 3 {
+		return nil, fmt.Errorf("window size must be an odd number greater than or equal to 3")
+	}
+
+	sigma := options.Sigma
+	if sigma <= 0 {
+		sigma = float64(options.WindowSize) / 4
+	}
+
+	var result *ElevationMap
+	switch options.Method {
+	case DenoiseMedian:
+		result = elevationMap.medianFilter(options.WindowSize/2, options.Workers)
+	case DenoiseGaussian:
+		result = elevationMap.gaussianFilter(options.WindowSize/2, sigma)
+	case DenoiseBilateral:
+		if options.RangeSigma <= 0 {
+			return nil, fmt.Errorf("range sigma must be greater than 0")
+		}
+		result = elevationMap.bilateralFilter(options.WindowSize/2, sigma, options.RangeSigma, options.Workers)
+	case DenoiseSpike:
+		if options.SpikeThreshold <= 0 {
+			return nil, fmt.Errorf("spike threshold must be greater than 0")
+		}
+		result = elevationMap.spikeFilter(options.WindowSize/2, options.SpikeThreshold, options.Workers)
+	default:
+		return nil, fmt.Errorf("unknown denoise method")
+	}
+
+	elevationMap.applyNodataMode(result, options)
+	result.CRS = elevationMap.CRS
+
+	result.UpdateElevationRange()
+	return result, nil
+}
+
+// applyNodataMode decides per cell whether the filtered value in result is
+// kept, using the number of valid and nodata cells in the cell's window.
+func (elevationMap *ElevationMap) applyNodataMode(result *ElevationMap, options DenoiseOptions) {
+	numRows := elevationMap.NumRows
+	numCols := elevationMap.NumCols
+	halfWindow := options.WindowSize / 2
+	minValid := max(options.MinValidNeighbours, 1)
+
+	// Summed-area table of valid cells, with an extra leading row and column.
+	valid := make([]int32, (numRows+1)*(numCols+1))
+	for row := 0; row < numRows; row++ {
+		for col := 0; col < numCols; col++ {
+			count := valid[row*(numCols+1)+col+1] + valid[(row+1)*(numCols+1)+col] - valid[row*(numCols+1)+col]
+			if elevationMap.Data[row*numCols+col] != NodataValue {
+				count++
+			}
+			valid[(row+1)*(numCols+1)+col+1] = count
+		}
+	}
+
+	for row := 0; row < numRows; row++ {
+		top := max(row-halfWindow, 0)
+		bottom := min(row+halfWindow, numRows-1) + 1
+		for col := 0; col < numCols; col++ {
+			left := max(col-halfWindow, 0)
+			right := min(col+halfWindow, numCols-1) + 1
+			numValid := int(valid[bottom*(numCols+1)+right] - valid[top*(numCols+1)+right] - valid[bottom*(numCols+1)+left] + valid[top*(numCols+1)+left])
+			numNodata := (bottom-top)*(right-left) - numValid
+
+			original := elevationMap.Data[row*numCols+col]
+			if original != NodataValue {
+				if numValid < minValid {
+					result.Data[row*numCols+col] = original
+				}
+				continue
+			}
+
+			fill := numValid >= minValid
+			switch options.NodataMode {
+			case NodataPreserve:
+				fill = false
+			case NodataFillSmall:
+				fill = fill && numNodata < options.FillMaxNodata
+			}
+			if !fill {
+				result.Data[row*numCols+col] = NodataValue
+			}
+		}
+	}
+}
+
+// rankHistogram is the sliding window histogram of Huang's median filter.
+// Elevations are replaced by their rank among all distinct values of the
+// map, so the median it finds is exact. A second, coarse level lets the
+// median move across empty ranges in big steps.
+type rankHistogram struct {
+	counts []int32
+	coarse []int32
+	total  int
+	median int
+	below  int
+}
+
+const rankHistogramShift = 8
+
+func newRankHistogram(numLevels int) *rankHistogram {
+	return &rankHistogram{
+		counts: make([]int32, numLevels),
+		coarse: make([]int32, (numLevels>>rankHistogramShift)+1),
+	}
+}
+
+func (histogram *rankHistogram) add(rank int32) {
+	histogram.counts[rank]++
+	histogram.coarse[rank>>rankHistogramShift]++
+	histogram.total++
+	if int(rank) < histogram.median {
+		histogram.below++
+	}
+}
+
+func (histogram *rankHistogram) remove(rank int32) {
+	histogram.counts[rank]--
+	histogram.coarse[rank>>rankHistogramShift]--
+	histogram.total--
+	if int(rank) < histogram.median {
+		histogram.below--
+	}
+}
+
+// find moves the tracked position to the rank of the k-th smallest value.
+func (histogram *rankHistogram) find(k int) int {
+	const blockSize = 1 << rankHistogramShift
+	m := histogram.median
+	below := histogram.below
+
+	for below > k {
+		if m%blockSize == 0 && m > 0 && below-int(histogram.coarse[(m-1)>>rankHistogramShift]) > k {
+			m -= blockSize
+			below -= int(histogram.coarse[m>>rankHistogramShift])
+			continue
+		}
+		m--
+		below -= int(histogram.counts[m])
+	}
+	for below+int(histogram.counts[m]) <= k {
+		if m%blockSize == 0 && below+int(histogram.coarse[m>>rankHistogramShift]) <= k {
+			below += int(histogram.coarse[m>>rankHistogramShift])
+			m += blockSize
+			continue
+		}
+		below += int(histogram.counts[m])
+		m++
+	}
+
+	histogram.median = m
+	histogram.below = below
+	return m
+}
+
+func (histogram *rankHistogram) medianRank() (int, int) {
+	n := histogram.total
+	if n%2 == 1 {
+		m := histogram.find(n / 2)
+		return m, m
+	}
+
+	m1 := histogram.find(n/2 - 1)
+	if histogram.below+int(histogram.counts[m1]) > n/2 {
+		return m1, m1
+	}
+
+	const blockSize = 1 << rankHistogramShift
+	m2 := m1 + 1
+	for histogram.counts[m2] == 0 {
+		if m2%blockSize == 0 && histogram.coarse[m2>>rankHistogramShift] == 0 {
+			m2 += blockSize
+			continue
+		}
+		m2++
+	}
+	return m1, m2
+}
+
+// rankMap returns the rank of every cell among the distinct values of the
+// map, -1 for nodata, together with the sorted distinct values.
+func (elevationMap *ElevationMap) rankMap() ([]int32, []float32) {
+	levels := make([]float32, 0, len(elevationMap.Data))
+	for _, value := range elevationMap.Data {
+		if value != NodataValue {
+			levels = append(levels, value)
+		}
+	}
+	slices.Sort(levels)
+	levels = slices.Compact(levels)
+
+	ranks := make([]int32, len(elevationMap.Data))
+	for i, value := range elevationMap.Data {
+		if value == NodataValue {
+			ranks[i] = -1
+			continue
+		}
+		rank, _ := slices.BinarySearch(levels, value)
+		ranks[i] = int32(rank)
+	}
+
+	return ranks, slices.Clip(levels)
+}
+
+func (elevationMap *ElevationMap) medianFilter(halfWindow, workers int) *ElevationMap {
+	result := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, elevationMap.NumRows, elevationMap.NumCols, elevationMap.CellSize)
+	ranks, levels := elevationMap.rankMap()
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	// Every worker owns a histogram with an entry per distinct value, so
+	// maps with very many distinct values get fewer workers.
+	const maxHistogramEntries = 1 << 26
+	workers = max(1, min(workers, maxHistogramEntries/max(1, len(levels))))
+
+	histograms := make([]*rankHistogram, workers)
+	parallelRows(elevationMap.NumRows, workers, func(worker, startRow, endRow int) {
+		if histograms[worker] == nil {
+			histograms[worker] = newRankHistogram(len(levels) + 1)
+		}
+		elevationMap.medianFilterBand(ranks, levels, histograms[worker], halfWindow, startRow, endRow, result)
+	})
+
+	return result
+}
+
+// medianFilterBand walks the rows of a band in a zigzag so that the window
+// only ever moves by one cell and the histogram is updated incrementally.
+func (elevationMap *ElevationMap) medianFilterBand(ranks []int32, levels []float32, histogram *rankHistogram, halfWindow, startRow, endRow int, result *ElevationMap) {
+	numRows := elevationMap.NumRows
+	numCols := elevationMap.NumCols
+
+	addRange := func(rowFrom, rowTo, colFrom, colTo int, add bool) {
+		rowFrom = max(rowFrom, 0)
+		rowTo = min(rowTo, numRows-1)
+		colFrom = max(colFrom, 0)
+		colTo = min(colTo, numCols-1)
+		for row := rowFrom; row <= rowTo; row++ {
+			for col := colFrom; col <= colTo; col++ {
+				rank := ranks[row*numCols+col]
+				if rank < 0 {
+					continue
+				}
+				if add {
+					histogram.add(rank)
+				} else {
+					histogram.remove(rank)
+				}
+			}
+		}
+	}
+
+	writeMedian := func(row, col int) {
+		if histogram.total == 0 {
+			result.SetRowCol(row, col, NodataValue)
+			return
+		}
+		m1, m2 := histogram.medianRank()
+		if m1 == m2 {
+			result.SetRowCol(row, col, float64(levels[m1]))
+		} else {
+			result.SetRowCol(row, col, (float64(levels[m1])+float64(levels[m2]))/2.0)
+		}
+	}
+
+	histogram.total = 0
+	histogram.median = 0
+	histogram.below = 0
+	clear(histogram.counts)
+	clear(histogram.coarse)
+
+	col := 0
+	addRange(startRow-halfWindow, startRow+halfWindow, 0, halfWindow, true)
+	for row := startRow; row < endRow; row++ {
+		if row > startRow {
+			addRange(row-halfWindow-1, row-halfWindow-1, col-halfWindow, col+halfWindow, false)
+			addRange(row+halfWindow, row+halfWindow, col-halfWindow, col+halfWindow, true)
+		}
+
+		leftToRight := (row-startRow)%2 == 0
+		for step := 0; step < numCols; step++ {
+			writeMedian(row, col)
+			if step == numCols-1 {
+				break
+			}
+			if leftToRight {
+				addRange(row-halfWindow, row+halfWindow, col-halfWindow, col-halfWindow, false)
+				addRange(row-halfWindow, row+halfWindow, col+halfWindow+1, col+halfWindow+1, true)
+				col++
+			} else {
+				addRange(row-halfWindow, row+halfWindow, col+halfWindow, col+halfWindow, false)
+				addRange(row-halfWindow, row+halfWindow, col-halfWindow-1, col-halfWindow-1, true)
+				col--
+			}
+		}
+	}
+}
+
+func gaussianKernel(halfWindow int, sigma float64) []float64 {
+	kernel := make([]float64, 2*halfWindow+1)
+	for i := range kernel {
+		d := float64(i - halfWindow)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+	}
+	return kernel
+}
+
+// gaussianFilter is a separable normalized convolution: values and weights
+// are blurred separately so that nodata cells do not pull the result down.
+func (elevationMap *ElevationMap) gaussianFilter(halfWindow int, sigma float64) *ElevationMap {
+	numRows := elevationMap.NumRows
+	numCols := elevationMap.NumCols
+	kernel := gaussianKernel(halfWindow, sigma)
+
+	sums := make([]float64, len(elevationMap.Data))
+	weights := make([]float64, len(elevationMap.Data))
+
+	for row := 0; row < numRows; row++ {
+		for col := 0; col < numCols; col++ {
+			var sum, weight float64
+			for k := -halfWindow; k <= halfWindow; k++ {
+				c := col + k
+				if c < 0 || c >= numCols {
+					continue
+				}
+				value := elevationMap.Data[row*numCols+c]
+				if value == NodataValue {
+					continue
+				}
+				sum += kernel[k+halfWindow] * float64(value)
+				weight += kernel[k+halfWindow]
+			}
+			sums[row*numCols+col] = sum
+			weights[row*numCols+col] = weight
+		}
+	}
+
+	result := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, elevationMap.NumRows, elevationMap.NumCols, elevationMap.CellSize)
+	for row := 0; row < numRows; row++ {
+		for col := 0; col < numCols; col++ {
+			var sum, weight float64
+			for k := -halfWindow; k <= halfWindow; k++ {
+				r := row + k
+				if r < 0 || r >= numRows {
+					continue
+				}
+				sum += kernel[k+halfWindow] * sums[r*numCols+col]
+				weight += kernel[k+halfWindow] * weights[r*numCols+col]
+			}
+			if weight > 0 {
+				result.SetRowCol(row, col, sum/weight)
+			} else {
+				result.SetRowCol(row, col, NodataValue)
+			}
+		}
+	}
+
+	return result
+}
+
+func (elevationMap *ElevationMap) bilateralFilter(halfWindow int, sigma, rangeSigma float64, workers int) *ElevationMap {
+	numRows := elevationMap.NumRows
+	numCols := elevationMap.NumCols
+	kernel := gaussianKernel(halfWindow, sigma)
+	result := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, elevationMap.NumRows, elevationMap.NumCols, elevationMap.CellSize)
+
+	parallelRows(numRows, workers, func(worker, startRow, endRow int) {
+		for row := startRow; row < endRow; row++ {
+			for col := 0; col < numCols; col++ {
+				center := elevationMap.Data[row*numCols+col]
+				var sum, weight float64
+				for i := -halfWindow; i <= halfWindow; i++ {
+					r := row + i
+					if r < 0 || r >= numRows {
+						continue
+					}
+					for j := -halfWindow; j <= halfWindow; j++ {
+						c := col + j
+						if c < 0 || c >= numCols {
+							continue
+						}
+						value := elevationMap.Data[r*numCols+c]
+						if value == NodataValue {
+							continue
+						}
+						w := kernel[i+halfWindow] * kernel[j+halfWindow]
+						if center != NodataValue {
+							d := float64(value - center)
+							w *= math.Exp(-d * d / (2 * rangeSigma * rangeSigma))
+						}
+						sum += w * float64(value)
+						weight += w
+					}
+				}
+				if weight > 0 {
+					result.SetRowCol(row, col, sum/weight)
+				} else {
+					result.SetRowCol(row, col, NodataValue)
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+func (elevationMap *ElevationMap) spikeFilter(halfWindow int, threshold float64, workers int) *ElevationMap {
+	numRows := elevationMap.NumRows
+	numCols := elevationMap.NumCols
+	medians := elevationMap.medianFilter(halfWindow, workers)
+	result := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, elevationMap.NumRows, elevationMap.NumCols, elevationMap.CellSize)
+
+	parallelRows(numRows, workers, func(worker, startRow, endRow int) {
+		deviations := make([]float64, 0, (2*halfWindow+1)*(2*halfWindow+1))
+		for row := startRow; row < endRow; row++ {
+			for col := 0; col < numCols; col++ {
+				value := elevationMap.Data[row*numCols+col]
+				median := float64(medians.Data[row*numCols+col])
+				if value == NodataValue {
+					result.SetRowCol(row, col, median)
+					continue
+				}
+
+				deviations = deviations[:0]
+				for r := max(row-halfWindow, 0); r <= min(row+halfWindow, numRows-1); r++ {
+					for c := max(col-halfWindow, 0); c <= min(col+halfWindow, numCols-1); c++ {
+						neighbour := elevationMap.Data[r*numCols+c]
+						if neighbour != NodataValue {
+							deviations = append(deviations, math.Abs(float64(neighbour)-median))
+						}
+					}
+				}
+				mad := selectMedian(deviations)
+
+				if math.Abs(float64(value)-median) > threshold*mad {
+					result.SetRowCol(row, col, median)
+				} else {
+					result.SetRowCol(row, col, float64(value))
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+// selectMedian returns the median of values in linear time, reordering
+// them in the process.
+func selectMedian(values []float64) float64 {
+	if len(values) == 0 {
+		return 0.0
+	}
+
+	mid := len(values) / 2
+	upper := selectKth(values, mid)
+	if len(values)%2 == 1 {
+		return upper
+	}
+
+	lower := values[0]
+	for _, value := range values[1:mid] {
+		lower = math.Max(lower, value)
+	}
+	return (lower + upper) / 2.0
+}
+
+// selectKth partially sorts values so that values[k] is the k-th smallest
+// and everything before it is not greater.
+func selectKth(values []float64, k int) float64 {
+	left, right := 0, len(values)-1
+	for left < right {
+		pivot := values[(left+right)/2]
+		i, j := left, right
+		for i <= j {
+			for values[i] < pivot {
+				i++
+			}
+			for values[j] > pivot {
+				j--
+			}
+			if i <= j {
+				values[i], values[j] = values[j], values[i]
+				i++
+				j--
+			}
+		}
+		if k <= j {
+			right = j
+		} else if k >= i {
+			left = i
+		} else {
+			break
+		}
+	}
+	return values[k]
+}
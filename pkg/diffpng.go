@@ -0,0 +1,281 @@
+package asctools
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type DiffOptions struct {
+	// Clamp is the absolute difference shown with the strongest colours.
+	// When 0 it is the ClampPercentile percentile of the absolute
+	// differences outside the dead band, so that a few outliers do not wash
+	// out real changes.
+	Clamp           float64
+	ClampPercentile float64
+	// DeadBand leaves absolute differences below it uncoloured. Cells that
+	// did not change are never coloured.
+	DeadBand float64
+	// DiffPow is the power the normalized elevations of the grayscale
+	// background are raised to.
+	DiffPow float64
+	// DiffOnly replaces the background with black.
+	DiffOnly bool
+	// Hillshade uses the hillshade of the first map as the background and
+	// shades the difference colours with it.
+	Hillshade bool
+	// Legend adds a colour bar labelled in Units below the map.
+	Legend bool
+	Units  string
+	// Extent, CellSize and Resampling choose the grid both maps are
+	// aligned to before they are compared, as for Calculate. The image has
+	// one pixel per cell of that grid.
+	Extent     GridExtent
+	CellSize   float64
+	Resampling Resampling
+}
+
+func DefaultDiffOptions() DiffOptions {
+	return DiffOptions{
+		ClampPercentile: 98,
+		DiffPow:         1,
+		Units:           "m",
+	}
+}
+
+// diffRampStops are a diverging ramp from dark blue for the largest loss
+// through near white to dark red for the largest gain.
+var diffRampStops = []colorStop{
+	{0.0, color.RGBA{5, 48, 97, 255}},
+	{0.1, color.RGBA{33, 102, 172, 255}},
+	{0.2, color.RGBA{67, 147, 195, 255}},
+	{0.3, color.RGBA{146, 197, 222, 255}},
+	{0.4, color.RGBA{209, 229, 240, 255}},
+	{0.5, color.RGBA{247, 247, 247, 255}},
+	{0.6, color.RGBA{253, 219, 199, 255}},
+	{0.7, color.RGBA{244, 165, 130, 255}},
+	{0.8, color.RGBA{214, 96, 77, 255}},
+	{0.9, color.RGBA{178, 24, 43, 255}},
+	{1.0, color.RGBA{103, 0, 31, 255}},
+}
+
+// diffColor returns the colour of a difference scaled to the [-1, 1] range.
+func diffColor(t float64) color.RGBA {
+	return interpolateStops(diffRampStops, (math.Max(-1, math.Min(1, t))+1)/2)
+}
+
+const (
+	diffLegendHeight   = 44
+	diffLegendMinWidth = 240
+	diffLegendMargin   = 10
+)
+
+// WriteDiffPNG writes the image of RenderDiffImage.
+func WriteDiffPNG(writer *bufio.Writer, elevationMap1 *ElevationMap, elevationMap2 *ElevationMap, options DiffOptions) error {
+	img, georef, err := RenderDiffImage(elevationMap1, elevationMap2, options)
+	if err != nil {
+		return err
+	}
+	return WriteGeoreferencedPNG(writer, img, georef)
+}
+
+// RenderDiffImage renders the difference of the second map minus the first,
+// with losses in blue and gains in red over a background of the first map.
+func RenderDiffImage(elevationMap1 *ElevationMap, elevationMap2 *ElevationMap, options DiffOptions) (*image.RGBA, ImageGeoreference, error) {
+	if options.Clamp < 0 {
+		return nil, ImageGeoreference{}, fmt.Errorf("clamp must not be negative")
+	}
+	if options.Clamp == 0 && (options.ClampPercentile <= 0 || options.ClampPercentile > 100) {
+		return nil, ImageGeoreference{}, fmt.Errorf("clamp percentile must be in range (0, 100]")
+	}
+	if options.DeadBand < 0 {
+		return nil, ImageGeoreference{}, fmt.Errorf("dead band must not be negative")
+	}
+
+	grid, aligned1, aligned2, err := alignDiffMaps(elevationMap1, elevationMap2, options)
+	if err != nil {
+		return nil, ImageGeoreference{}, err
+	}
+	imgWidth, imgHeight := grid.NumCols, grid.NumRows
+
+	// Differences of the pixels, in image order, NaN where either map has no
+	// data.
+	diffs := make([]float64, imgWidth*imgHeight)
+	absDiffs := []float64{}
+	for row := 0; row < imgHeight; row++ {
+		for col := 0; col < imgWidth; col++ {
+			elevation1 := aligned1.GetRowCol(row, col, false)
+			elevation2 := aligned2.GetRowCol(row, col, false)
+			diff := math.NaN()
+			if elevation1 != NodataValue && elevation2 != NodataValue {
+				diff = elevation2 - elevation1
+				if diff != 0 && math.Abs(diff) >= options.DeadBand {
+					absDiffs = append(absDiffs, math.Abs(diff))
+				}
+			}
+			diffs[row*imgWidth+col] = diff
+		}
+	}
+
+	clamp := options.Clamp
+	if clamp == 0 && len(absDiffs) > 0 {
+		sort.Float64s(absDiffs)
+		rank := int(math.Ceil(options.ClampPercentile/100*float64(len(absDiffs)))) - 1
+		clamp = absDiffs[max(0, rank)]
+	}
+
+	var shade *ElevationMap
+	if options.Hillshade {
+		shade = aligned1.Hillshade(defaultHillshadeAzimuth, defaultHillshadeAltitude, 1)
+	}
+	elevationRange1 := aligned1.MaxElevation - aligned1.MinElevation
+	elevationRange2 := aligned2.MaxElevation - aligned2.MinElevation
+	elevationRange := math.Max(elevationRange1, elevationRange2)
+
+	width, height := imgWidth, imgHeight
+	if options.Legend {
+		width = max(width, diffLegendMinWidth)
+		height += diffLegendHeight
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for row := 0; row < imgHeight; row++ {
+		for col := 0; col < imgWidth; col++ {
+			diff := diffs[row*imgWidth+col]
+			if math.IsNaN(diff) {
+				continue
+			}
+
+			brightness := 1.0
+			switch {
+			case options.DiffOnly:
+				brightness = 0
+			case shade != nil:
+				brightness = shade.GetRowCol(row, col, false)
+			case elevationRange > 0:
+				normalized := (aligned1.GetRowCol(row, col, false) - aligned1.MinElevation) / elevationRange
+				brightness = math.Min(1, math.Pow(normalized, options.DiffPow))
+			}
+			gray := uint8(math.Round(brightness * 255))
+			c := color.RGBA{R: gray, G: gray, B: gray, A: 255}
+
+			// Unchanged pixels and those in the dead band show the background.
+			if diff != 0 && math.Abs(diff) >= options.DeadBand {
+				t := 0.0
+				if clamp > 0 {
+					t = diff / clamp
+				}
+				c = diffColor(t)
+				if shade != nil && !options.DiffOnly {
+					c = shadeColor(c, brightness)
+				}
+			}
+			img.SetRGBA(col, row, c)
+		}
+	}
+
+	if options.Legend {
+		drawDiffLegend(img, imgHeight, clamp, options.DeadBand, options.Units)
+	}
+
+	georef := ImageGeoreference{
+		MinX:      grid.MinX,
+		MaxY:      grid.MaxY,
+		PixelSize: grid.CellSize,
+		Width:     imgWidth,
+		Height:    imgHeight,
+		CRS:       grid.CRS,
+		Metadata: []MetadataItem{
+			{"encoding", "diff"},
+			{"clamp", formatMetadataValue(clamp)},
+			{"dead_band", formatMetadataValue(options.DeadBand)},
+			{"units", options.Units},
+		},
+	}
+	return img, georef.withExtent(), nil
+}
+
+// alignDiffMaps returns the grid of the diff image and both maps aligned to
+// it.
+func alignDiffMaps(elevationMap1, elevationMap2 *ElevationMap, options DiffOptions) (*ElevationMap, *ElevationMap, *ElevationMap, error) {
+	if _, err := commonCRS(elevationMap1, elevationMap2); err != nil {
+		return nil, nil, nil, err
+	}
+	if elevationMap1.MinX >= elevationMap2.MaxX || elevationMap2.MinX >= elevationMap1.MaxX ||
+		elevationMap1.MinY >= elevationMap2.MaxY || elevationMap2.MinY >= elevationMap1.MaxY {
+		return nil, nil, nil, fmt.Errorf("elevation maps do not overlap")
+	}
+	grid, err := CommonGrid([]*ElevationMap{elevationMap1, elevationMap2}, options.Extent, options.CellSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aligned := alignToGrid([]*ElevationMap{elevationMap1, elevationMap2}, grid, options.Resampling)
+	return grid, aligned[0], aligned[1], nil
+}
+
+// drawDiffLegend draws a colour bar from -clamp to clamp with labelled
+// ticks into the strip of img below row top. The dead band is gray.
+func drawDiffLegend(img *image.RGBA, top int, clamp, deadBand float64, units string) {
+	width := img.Bounds().Dx()
+	draw.Draw(img, image.Rect(0, top, width, top+diffLegendHeight), image.White, image.Point{}, draw.Src)
+
+	barLeft, barRight := diffLegendMargin, width-diffLegendMargin
+	barTop, barBottom := top+6, top+18
+	for x := barLeft; x < barRight; x++ {
+		t := 2*(float64(x-barLeft)+0.5)/float64(barRight-barLeft) - 1
+		c := diffColor(t)
+		if math.Abs(t*clamp) < deadBand {
+			c = color.RGBA{160, 160, 160, 255}
+		}
+		for y := barTop; y < barBottom; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	ticks := []float64{-1, 0, 1}
+	if barRight-barLeft >= 300 {
+		ticks = []float64{-1, -0.5, 0, 0.5, 1}
+	}
+	drawer := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for _, tick := range ticks {
+		x := barLeft + int(math.Round((tick+1)/2*float64(barRight-barLeft-1)))
+		for y := barBottom; y < barBottom+4; y++ {
+			img.SetRGBA(x, y, color.RGBA{A: 255})
+		}
+		label := formatLegendValue(tick*clamp, clamp)
+		if units != "" {
+			label += " " + units
+		}
+		labelWidth := drawer.MeasureString(label).Ceil()
+		labelX := max(0, min(width-labelWidth, x-labelWidth/2))
+		drawer.Dot = fixed.P(labelX, barBottom+16)
+		drawer.DrawString(label)
+	}
+}
+
+// formatLegendValue rounds a value to three significant digits of clamp,
+// with a sign for gains.
+func formatLegendValue(value, clamp float64) string {
+	decimals := 0
+	if clamp > 0 {
+		decimals = max(0, 2-int(math.Floor(math.Log10(clamp))))
+	}
+	text := strconv.FormatFloat(value, 'f', decimals, 64)
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	if value > 0 {
+		text = "+" + text
+	}
+	return text
+}
@@ -0,0 +1,239 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type Aggregation int
+
+const (
+	AggregateMean Aggregation = iota
+	AggregateMin
+	AggregateMax
+	AggregateMedian
+	AggregateMode
+	AggregateFirst
+	AggregatePercentile
+)
+
+type EdgePolicy int
+
+const (
+	// EdgeDrop leaves out source rows and columns that do not fill a whole
+	// output cell.
+	EdgeDrop EdgePolicy = iota
+	// EdgePad extends the output by a partial cell, which then counts the
+	// missing area as not covered.
+	EdgePad
+)
+
+type DownscaleOptions struct {
+	// CellSize is the output cell size. When 0 it is Factor times the
+	// source cell size.
+	CellSize    float64
+	Factor      float64
+	Aggregation Aggregation
+	// Percentile is used by AggregatePercentile, in the 0-100 range.
+	Percentile float64
+	Edge       EdgePolicy
+	// MinCoverage is the fraction of an output cell that has to be covered
+	// by valid source cells, otherwise the output cell is nodata.
+	MinCoverage float64
+}
+
+func DefaultDownscaleOptions() DownscaleOptions {
+	return DownscaleOptions{
+		Factor:      1,
+		Aggregation: AggregateMean,
+		Percentile:  50,
+		Edge:        EdgeDrop,
+	}
+}
+
+func ParseAggregation(value string) (Aggregation, error) {
+	switch value {
+	case "mean", "":
+		return AggregateMean, nil
+	case "min":
+		return AggregateMin, nil
+	case "max":
+		return AggregateMax, nil
+	case "median":
+		return AggregateMedian, nil
+	case "mode":
+		return AggregateMode, nil
+	case "first":
+		return AggregateFirst, nil
+	case "percentile":
+		return AggregatePercentile, nil
+	default:
+		return AggregateMean, fmt.Errorf("unknown aggregation: %s", value)
+	}
+}
+
+func ParseEdgePolicy(value string) (EdgePolicy, error) {
+	switch value {
+	case "drop", "":
+		return EdgeDrop, nil
+	case "pad":
+		return EdgePad, nil
+	default:
+		return EdgeDrop, fmt.Errorf("unknown edge policy: %s", value)
+	}
+}
+
+// overlapEpsilon ignores slivers produced by rounding of cell boundaries.
+const overlapEpsilon = 1e-9
+
+type weightedValue struct {
+	value  float64
+	weight float64
+}
+
+// Downscale aggregates the map onto a coarser grid anchored at the same
+// lower left corner. Source cells that only partly overlap an output cell
+// contribute with the overlapping fraction of their area.
+func (elevationMap *ElevationMap) Downscale(options DownscaleOptions) (*ElevationMap, error) {
+	cellSize := options.CellSize
+	if cellSize <= 0 {
+		if options.Factor < 1 {
+			return nil, fmt.Errorf("downscale factor must be at least 1")
+		}
+		cellSize = elevationMap.CellSize * options.Factor
+	}
+	if cellSize < elevationMap.CellSize {
+		return nil, fmt.Errorf("target cell size must not be smaller than the source cell size")
+	}
+	if options.Percentile < 0 || options.Percentile > 100 {
+		return nil, fmt.Errorf("percentile must be in range [0, 100]")
+	}
+	if options.MinCoverage < 0 || options.MinCoverage > 1 {
+		return nil, fmt.Errorf("minimum coverage must be in range [0, 1]")
+	}
+
+	if cellSize == elevationMap.CellSize && options.MinCoverage == 0 {
+		return elevationMap, nil
+	}
+
+	factor := cellSize / elevationMap.CellSize
+	numCols := int(float64(elevationMap.NumCols) / factor)
+	numRows := int(float64(elevationMap.NumRows) / factor)
+	if options.Edge == EdgePad {
+		numCols = int(math.Ceil(float64(elevationMap.NumCols)/factor - 1e-9))
+		numRows = int(math.Ceil(float64(elevationMap.NumRows)/factor - 1e-9))
+	}
+	if numCols == 0 || numRows == 0 {
+		return nil, fmt.Errorf("target cell size is larger than the map")
+	}
+
+	newMap := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, numRows, numCols, cellSize)
+	newMap.CRS = elevationMap.CRS
+
+	values := []weightedValue{}
+	for row := 0; row < numRows; row++ {
+		y0 := float64(row) * factor
+		y1 := y0 + factor
+		for col := 0; col < numCols; col++ {
+			x0 := float64(col) * factor
+			x1 := x0 + factor
+
+			values = values[:0]
+			coverage := 0.0
+			// Rows are visited top to bottom so that values end up in file order.
+			for subRow := min(int(math.Ceil(y1)), elevationMap.NumRows) - 1; subRow >= int(y0); subRow-- {
+				overlapY := math.Min(y1, float64(subRow+1)) - math.Max(y0, float64(subRow))
+				if overlapY <= overlapEpsilon {
+					continue
+				}
+				for subCol := int(x0); subCol < min(int(math.Ceil(x1)), elevationMap.NumCols); subCol++ {
+					overlapX := math.Min(x1, float64(subCol+1)) - math.Max(x0, float64(subCol))
+					if overlapX <= overlapEpsilon {
+						continue
+					}
+					value := elevationMap.GetRowCol(subRow, subCol, true)
+					if value == NodataValue {
+						continue
+					}
+					weight := overlapX * overlapY
+					values = append(values, weightedValue{value: value, weight: weight})
+					coverage += weight
+				}
+			}
+
+			if len(values) == 0 || coverage/(factor*factor) < options.MinCoverage {
+				continue
+			}
+
+			newMap.SetRowCol(numRows-1-row, col, aggregate(values, options))
+		}
+	}
+
+	newMap.UpdateElevationRange()
+
+	return newMap, nil
+}
+
+// aggregate expects values in file order, as collected by Downscale.
+func aggregate(values []weightedValue, options DownscaleOptions) float64 {
+	switch options.Aggregation {
+	case AggregateMin:
+		result := values[0].value
+		for _, v := range values[1:] {
+			result = math.Min(result, v.value)
+		}
+		return result
+	case AggregateMax:
+		result := values[0].value
+		for _, v := range values[1:] {
+			result = math.Max(result, v.value)
+		}
+		return result
+	case AggregateMedian:
+		return weightedPercentile(values, 50)
+	case AggregatePercentile:
+		return weightedPercentile(values, options.Percentile)
+	case AggregateMode:
+		weights := map[float64]float64{}
+		best := values[0].value
+		for _, v := range values {
+			weights[v.value] += v.weight
+			if weights[v.value] > weights[best] || (weights[v.value] == weights[best] && v.value < best) {
+				best = v.value
+			}
+		}
+		return best
+	case AggregateFirst:
+		return values[0].value
+	}
+
+	var sum, weight float64
+	for _, v := range values {
+		sum += v.value * v.weight
+		weight += v.weight
+	}
+	return sum / weight
+}
+
+func weightedPercentile(values []weightedValue, percentile float64) float64 {
+	sort.SliceStable(values, func(i, j int) bool { return values[i].value < values[j].value })
+
+	total := 0.0
+	for _, v := range values {
+		total += v.weight
+	}
+
+	target := total * percentile / 100
+	cumulative := 0.0
+	for i, v := range values {
+		cumulative += v.weight
+		if cumulative >= target-1e-9*total {
+			if math.Abs(cumulative-target) <= 1e-9*total && i+1 < len(values) && percentile > 0 {
+				return (v.value + values[i+1].value) / 2
+			}
+			return v.value
+		}
+	}
+	return values[len(values)-1].value
+}
@@ -4,7 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"math"
-	"sort"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -22,11 +23,17 @@ type ElevationMap struct {
 	Data         []float32
 	MinElevation float64
 	MaxElevation float64
+	// CRS is the coordinate reference system of the map, nil when unknown.
+	CRS *CRS
 }
 
+// makeElevationMap fits as many whole cells into the extent as it holds.
+// Cell sizes such as degrees that floats cannot represent leave the quotient
+// a hair below a whole number, which would lose a row or column. Maps of the
+// same size as another one should use makeElevationMapWithSize.
 func makeElevationMap(minX, minY, maxX, maxY, cellSize float64) *ElevationMap {
-	numRows := int((maxY - minY) / cellSize)
-	numCols := int((maxX - minX) / cellSize)
+	numRows := int((maxY-minY)/cellSize + 1e-9)
+	numCols := int((maxX-minX)/cellSize + 1e-9)
 
 	data := make([]float32, numRows*numCols)
 
@@ -48,6 +55,21 @@ func makeElevationMap(minX, minY, maxX, maxY, cellSize float64) *ElevationMap {
 	}
 }
 
+func makeElevationMapWithSize(minX, minY float64, numRows, numCols int, cellSize float64) *ElevationMap {
+	elevationMap := makeElevationMap(minX, minY, minX, minY, cellSize)
+	elevationMap.NumRows = numRows
+	elevationMap.NumCols = numCols
+	elevationMap.MaxX = minX + float64(numCols)*cellSize
+	elevationMap.MaxY = minY + float64(numRows)*cellSize
+	elevationMap.Data = make([]float32, numRows*numCols)
+
+	for i := range elevationMap.Data {
+		elevationMap.Data[i] = NodataValue
+	}
+
+	return elevationMap
+}
+
 func MergeMaps(maps []*ElevationMap) (*ElevationMap, error) {
 	if len(maps) == 0 {
 		return nil, fmt.Errorf("no maps to merge")
@@ -59,6 +81,10 @@ func MergeMaps(maps []*ElevationMap) (*ElevationMap, error) {
 			return nil, fmt.Errorf("incompatible cell sizes")
 		}
 	}
+	crs, err := commonCRS(maps...)
+	if err != nil {
+		return nil, err
+	}
 
 	minX, minY := math.MaxFloat64, math.MaxFloat64
 	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
@@ -77,7 +103,12 @@ func MergeMaps(maps []*ElevationMap) (*ElevationMap, error) {
 		}
 	}
 
-	merged := makeElevationMap(minX, minY, maxX, maxY, cellSize)
+	// The maps share the cell size, so the extent holds a whole number of
+	// cells up to rounding errors.
+	numRows := int(math.Round((maxY - minY) / cellSize))
+	numCols := int(math.Round((maxX - minX) / cellSize))
+	merged := makeElevationMapWithSize(minX, minY, numRows, numCols, cellSize)
+	merged.CRS = crs
 
 	for _, m := range maps {
 		for y := m.MinY; y < m.MaxY; y += m.CellSize {
@@ -96,7 +127,7 @@ func MergeMaps(maps []*ElevationMap) (*ElevationMap, error) {
 }
 
 func (elevationMap *ElevationMap) fixHoles() {
-	fixedHolesMap := makeElevationMap(elevationMap.MinX, elevationMap.MinY, elevationMap.MaxX, elevationMap.MaxY, elevationMap.CellSize)
+	fixedHolesMap := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, elevationMap.NumRows, elevationMap.NumCols, elevationMap.CellSize)
 
 	for row := 0; row < elevationMap.NumRows; row++ {
 		for col := 0; col < elevationMap.NumCols; col++ {
@@ -172,15 +203,11 @@ func ParseASCFile(reader *bufio.Reader) (*ElevationMap, error) {
 	height := float64(numRows) * cellSize
 
 	minX := centerX - width/2
-	maxX := centerX + width/2
 	minY := centerY - height/2
-	maxY := centerY + height/2
-
-	elevationMap := makeElevationMap(minX, minY, maxX, maxY, cellSize)
 
-	if numRows != elevationMap.NumRows || numCols != elevationMap.NumCols {
-		return nil, fmt.Errorf("parsed map dimensions do not match expected dimensions, this should not happen")
-	}
+	// The header gives the dimensions; deriving them from the extent can be
+	// off by one for cell sizes such as degrees that floats cannot represent.
+	elevationMap := makeElevationMapWithSize(minX, minY, numRows, numCols, cellSize)
 
 	for row := 0; row < elevationMap.NumRows; row++ {
 		if !scanner.Scan() {
@@ -213,14 +240,61 @@ func ParseASCFile(reader *bufio.Reader) (*ElevationMap, error) {
 	return elevationMap, nil
 }
 
+func ReadASCFile(path string) (*ElevationMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	elevationMap, err := ParseASCFile(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	elevationMap.CRS, err = readPRJ(path)
+	if err != nil {
+		return nil, err
+	}
+	return elevationMap, nil
+}
+
+func ListASCFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".asc") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+func (elevationMap *ElevationMap) WriteASCFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := elevationMap.WriteASC(bufio.NewWriter(file)); err != nil {
+		return err
+	}
+	return writePRJ(path, elevationMap.CRS)
+}
+
 func (elevationMap *ElevationMap) WriteASC(writer *bufio.Writer) error {
 	header := fmt.Sprintf(
-		"ncols %d\nnrows %d\nxllcenter %.2f\nyllcenter %.2f\ncellsize %.2f\nnodata_value %.0f\n",
+		"ncols %d\nnrows %d\nxllcenter %s\nyllcenter %s\ncellsize %s\nnodata_value %.0f\n",
 		elevationMap.NumCols,
 		elevationMap.NumRows,
-		elevationMap.MinX+elevationMap.GetWidth()/2,
-		elevationMap.MinY+elevationMap.GetHeight()/2,
-		elevationMap.CellSize,
+		formatHeaderValue(elevationMap.MinX+elevationMap.GetWidth()/2),
+		formatHeaderValue(elevationMap.MinY+elevationMap.GetHeight()/2),
+		formatHeaderValue(elevationMap.CellSize),
 		NodataValue,
 	)
 	if _, err := writer.WriteString(header); err != nil {
@@ -242,6 +316,16 @@ func (elevationMap *ElevationMap) WriteASC(writer *bufio.Writer) error {
 	return writer.Flush()
 }
 
+// formatHeaderValue writes two decimals, or as many as needed for values
+// such as cell sizes in degrees that two decimals would round off.
+func formatHeaderValue(value float64) string {
+	if math.Abs(value*100-math.Round(value*100)) < 1e-6 {
+		return fmt.Sprintf("%.2f", value)
+	}
+	text := strconv.FormatFloat(value, 'f', 10, 64)
+	return strings.TrimRight(strings.TrimRight(text, "0"), ".")
+}
+
 func (elevationMap *ElevationMap) GetElevation(x float64, y float64) float64 {
 	if x >= elevationMap.MinX && x < elevationMap.MaxX && y >= elevationMap.MinY && y < elevationMap.MaxY {
 		mapY := y - elevationMap.MinY
@@ -361,6 +445,11 @@ func (elevationMap *ElevationMap) Split(verTiles, horTiles int, uniformSize bool
 }
 
 func (elevationMap1 *ElevationMap) Subtract(elevationMap2 *ElevationMap) (*ElevationMap, error) {
+	crs, err := commonCRS(elevationMap1, elevationMap2)
+	if err != nil {
+		return nil, err
+	}
+
 	minX := math.Max(elevationMap1.MinX, elevationMap2.MinX)
 	maxX := math.Min(elevationMap1.MaxX, elevationMap2.MaxX)
 	minY := math.Max(elevationMap1.MinY, elevationMap2.MinY)
@@ -373,6 +462,7 @@ func (elevationMap1 *ElevationMap) Subtract(elevationMap2 *ElevationMap) (*Eleva
 	cellSize := math.Max(elevationMap1.CellSize, elevationMap2.CellSize)
 
 	result := makeElevationMap(minX, minY, maxX, maxY, cellSize)
+	result.CRS = crs
 	for y := minY; y < maxY; y += cellSize {
 		for x := minX; x < maxX; x += cellSize {
 			val1 := elevationMap1.GetElevation(x, y)
@@ -412,6 +502,7 @@ func (elevationMap *ElevationMap) Crop(startX, startY, endX, endY float64) (*Ele
 	}
 
 	result := makeElevationMap(startX, startY, endX, endY, elevationMap.CellSize)
+	result.CRS = elevationMap.CRS
 
 	for y := startY; y < endY; y += elevationMap.CellSize {
 		for x := startX; x < endX; x += elevationMap.CellSize {
@@ -470,91 +561,3 @@ func (elevationMap *ElevationMap) GetWidth() float64 {
 func (elevationMap *ElevationMap) GetHeight() float64 {
 	return float64(elevationMap.NumRows) * elevationMap.CellSize
 }
-
-func (elevationMap *ElevationMap) Denoise(windowSize int) (*ElevationMap, error) {
-	if windowSize%2 == 0 || windowSize < 3 {
-		return nil, fmt.Errorf("window size must be an odd number greater than or equal to 3")
-	}
-
-	newMap := makeElevationMap(elevationMap.MinX, elevationMap.MinY, elevationMap.MaxX, elevationMap.MaxY, elevationMap.CellSize)
-
-	halfWindow := windowSize / 2
-
-	for y := elevationMap.MinY; y < elevationMap.MaxY; y += elevationMap.CellSize {
-		for x := elevationMap.MinX; x < elevationMap.MaxX; x += elevationMap.CellSize {
-			neighbours := []float64{}
-
-			for i := -halfWindow; i <= halfWindow; i++ {
-				for j := -halfWindow; j <= halfWindow; j++ {
-					neighbourX := x + float64(j)*elevationMap.CellSize
-					neighbourY := y + float64(i)*elevationMap.CellSize
-
-					if neighbourX < elevationMap.MinX || neighbourX >= elevationMap.MaxX ||
-						neighbourY < elevationMap.MinY || neighbourY >= elevationMap.MaxY {
-						continue
-					}
-					value := elevationMap.GetElevation(neighbourX, neighbourY)
-					if value == NodataValue {
-						continue
-					}
-					neighbours = append(neighbours, value)
-				}
-			}
-			newMap.SetElevation(x, y, calculateMedian(neighbours))
-		}
-	}
-
-	return newMap, nil
-}
-
-func calculateMedian(values []float64) float64 {
-	if len(values) == 0 {
-		return 0.0
-	}
-
-	sort.Float64s(values)
-
-	mid := len(values) / 2
-	if len(values)%2 == 0 {
-		return (values[mid-1] + values[mid]) / 2.0
-	}
-
-	return values[mid]
-}
-
-func (elevationMap *ElevationMap) Downscale(factor int) (*ElevationMap, error) {
-	if factor < 1 {
-		return nil, fmt.Errorf("downscale factor must be greater than or equal 1")
-	}
-
-	if factor == 1 {
-		return elevationMap, nil
-	}
-
-	newMap := makeElevationMap(elevationMap.MinX, elevationMap.MinY, elevationMap.MaxX, elevationMap.MaxY, elevationMap.CellSize*float64(factor))
-	for y := newMap.MinY; y < newMap.MaxY; y += newMap.CellSize {
-		for x := newMap.MinX; x < newMap.MaxX; x += newMap.CellSize {
-			var sum float64
-			var count int
-
-			for subY := y; subY < y+newMap.CellSize; subY += elevationMap.CellSize {
-				for subX := x; subX < x+newMap.CellSize; subX += elevationMap.CellSize {
-					elevation := elevationMap.GetElevation(subX, subY)
-					if elevation != NodataValue {
-						sum += elevation
-						count++
-					}
-				}
-			}
-
-			if count > 0 {
-				average := sum / float64(count)
-				newMap.SetElevation(x+newMap.CellSize/2, y+newMap.CellSize/2, average)
-			} else {
-				newMap.SetElevation(x+newMap.CellSize/2, y+newMap.CellSize/2, NodataValue)
-			}
-		}
-	}
-
-	return newMap, nil
-}
@@ -0,0 +1,153 @@
+package asctools
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// ElevationEncoding packs elevations into the colour channels of a PNG, as
+// web maps read raster DEM tiles. Nodata is written as a transparent pixel.
+type ElevationEncoding int
+
+const (
+	// EncodingNone renders elevations with a colour ramp.
+	EncodingNone ElevationEncoding = iota
+	// EncodingTerrainRGB is the Mapbox Terrain-RGB encoding, in steps of
+	// 0.1 from -10000.
+	EncodingTerrainRGB
+	// EncodingTerrarium is the Terrarium encoding of Mapzen tiles, in steps
+	// of 1/256 from -32768.
+	EncodingTerrarium
+)
+
+func ParseElevationEncoding(value string) (ElevationEncoding, error) {
+	switch value {
+	case "none", "":
+		return EncodingNone, nil
+	case "terrain-rgb", "mapbox":
+		return EncodingTerrainRGB, nil
+	case "terrarium":
+		return EncodingTerrarium, nil
+	default:
+		return EncodingNone, fmt.Errorf("unknown elevation encoding: %s", value)
+	}
+}
+
+func (encoding ElevationEncoding) String() string {
+	switch encoding {
+	case EncodingTerrainRGB:
+		return "terrain-rgb"
+	case EncodingTerrarium:
+		return "terrarium"
+	default:
+		return "none"
+	}
+}
+
+// TileJSONName is the name of the encoding in the encoding field of
+// TileJSON and MapLibre raster-dem sources.
+func (encoding ElevationEncoding) TileJSONName() string {
+	switch encoding {
+	case EncodingTerrainRGB:
+		return "mapbox"
+	case EncodingTerrarium:
+		return "terrarium"
+	default:
+		return ""
+	}
+}
+
+// Encode returns the colour of an elevation, clamped to the range of the
+// encoding.
+func (encoding ElevationEncoding) Encode(elevation float64) color.RGBA {
+	if elevation == NodataValue {
+		return color.RGBA{}
+	}
+	switch encoding {
+	case EncodingTerrainRGB:
+		value := uint32(math.Max(0, math.Min(1<<24-1, math.Round((elevation+10000)*10))))
+		return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}
+	case EncodingTerrarium:
+		value := math.Max(0, math.Min(1<<16-1.0/256, math.Round((elevation+32768)*256)/256))
+		whole := math.Floor(value)
+		return color.RGBA{R: uint8(int(whole) >> 8), G: uint8(int(whole)), B: uint8((value - whole) * 256), A: 255}
+	default:
+		return color.RGBA{}
+	}
+}
+
+// Decode returns the elevation of a colour, or NodataValue for a
+// transparent pixel.
+func (encoding ElevationEncoding) Decode(c color.RGBA) float64 {
+	if c.A == 0 {
+		return NodataValue
+	}
+	switch encoding {
+	case EncodingTerrainRGB:
+		return -10000 + float64(int(c.R)<<16|int(c.G)<<8|int(c.B))*0.1
+	case EncodingTerrarium:
+		return float64(c.R)*256 + float64(c.G) + float64(c.B)/256 - 32768
+	default:
+		return NodataValue
+	}
+}
+
+// WriteEncodedPNG writes one pixel per cell, north up, with elevations
+// packed by encoding.
+func (elevationMap *ElevationMap) WriteEncodedPNG(writer *bufio.Writer, encoding ElevationEncoding) error {
+	if encoding == EncodingNone {
+		return fmt.Errorf("no elevation encoding given")
+	}
+	img := image.NewRGBA(image.Rect(0, 0, elevationMap.NumCols, elevationMap.NumRows))
+	for row := 0; row < elevationMap.NumRows; row++ {
+		for col := 0; col < elevationMap.NumCols; col++ {
+			img.SetRGBA(col, row, encoding.Encode(elevationMap.GetRowCol(row, col, false)))
+		}
+	}
+
+	return WriteGeoreferencedPNG(writer, img, elevationMap.PNGGeoreference(ScaleNone, 1, RampGray, encoding))
+}
+
+// ReadEncodedPNG reads a PNG with elevations packed by encoding into a map
+// with the given lower left corner and cell size.
+func ReadEncodedPNG(reader io.Reader, encoding ElevationEncoding, minX, minY, cellSize float64) (*ElevationMap, error) {
+	if encoding == EncodingNone {
+		return nil, fmt.Errorf("no elevation encoding given")
+	}
+	if cellSize <= 0 {
+		return nil, fmt.Errorf("cell size must be greater than 0")
+	}
+	img, err := png.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding PNG: %v", err)
+	}
+
+	bounds := img.Bounds()
+	elevationMap := makeElevationMapWithSize(minX, minY, bounds.Dy(), bounds.Dx(), cellSize)
+	for row := 0; row < bounds.Dy(); row++ {
+		for col := 0; col < bounds.Dx(); col++ {
+			// Encoded tiles are opaque apart from nodata, so converting to
+			// non-premultiplied colour does not lose precision.
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+col, bounds.Min.Y+row)).(color.NRGBA)
+			elevation := encoding.Decode(color.RGBA{R: c.R, G: c.G, B: c.B, A: c.A})
+			elevationMap.SetRowCol(row, col, elevation)
+		}
+	}
+	elevationMap.UpdateElevationRange()
+
+	return elevationMap, nil
+}
+
+// WebMercatorTileGrid returns the lower left corner and cell size, in Web
+// Mercator metres, of the pixels of tile z/x/y of an XYZ pyramid.
+func WebMercatorTileGrid(z, x, y, tileSize int) (minX, minY, cellSize float64) {
+	tileExtent := 2 * webMercatorHalfWidth / math.Pow(2, float64(z))
+	minX = -webMercatorHalfWidth + float64(x)*tileExtent
+	minY = webMercatorHalfWidth - float64(y+1)*tileExtent
+	return minX, minY, tileExtent / float64(tileSize)
+}
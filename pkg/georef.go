@@ -0,0 +1,231 @@
+package asctools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ImageGeoreference places an image on the map: the top left corner of its
+// first Width x Height pixels and the size of its square pixels, in map
+// units. Pixels past them, e.g. a legend, are not part of the map.
+type ImageGeoreference struct {
+	MinX, MaxY    float64
+	PixelSize     float64
+	Width, Height int
+	CRS           *CRS
+	// ValueScale and ValueOffset turn pixel values into elevations, as
+	// ValueOffset + value * ValueScale, when ValueScale is not 0.
+	ValueScale  float64
+	ValueOffset float64
+	// Metadata describes how the pixels encode values. It is written to the
+	// PNG text chunks and the .aux.xml sidecar.
+	Metadata []MetadataItem
+}
+
+type MetadataItem struct {
+	Key, Value string
+}
+
+// PNGGeoreference returns the georeference of the image written by WritePNG,
+// or by WriteEncodedPNG when encoding is set.
+func (elevationMap *ElevationMap) PNGGeoreference(scalingOperation ScalingOperation, scale int, ramp ColorRamp, encoding ElevationEncoding) ImageGeoreference {
+	georef := ImageGeoreference{
+		MinX:      elevationMap.MinX,
+		MaxY:      elevationMap.MaxY,
+		PixelSize: elevationMap.CellSize,
+		Width:     elevationMap.NumCols,
+		Height:    elevationMap.NumRows,
+		CRS:       elevationMap.CRS,
+	}
+	if encoding != EncodingNone {
+		georef.Metadata = []MetadataItem{{"encoding", encoding.String()}}
+		return georef.withExtent()
+	}
+
+	// As RenderImage, which samples every scale cells from the lower left
+	// corner and drops the cells left over at the top and right.
+	georef.Width = int(elevationMap.GetWidth() / elevationMap.CellSize)
+	georef.Height = int(elevationMap.GetHeight() / elevationMap.CellSize)
+	if scalingOperation == ScaleDown && scale > 1 {
+		georef.Width /= scale
+		georef.Height /= scale
+		georef.PixelSize *= float64(scale)
+	}
+	georef.MaxY = elevationMap.MinY + float64(georef.Height)*georef.PixelSize
+	if scalingOperation == ScaleUp && scale > 1 {
+		georef.Width *= scale
+		georef.Height *= scale
+		georef.PixelSize /= float64(scale)
+	}
+
+	georef.Metadata = []MetadataItem{{"encoding", ramp.String()}}
+	if ramp == RampGray {
+		georef.Metadata[0].Value = "gray16"
+		georef.ValueOffset = elevationMap.MinElevation
+		georef.ValueScale = (elevationMap.MaxElevation - elevationMap.MinElevation) / math.MaxUint16
+		georef.Metadata = append(georef.Metadata,
+			MetadataItem{"value_offset", formatMetadataValue(georef.ValueOffset)},
+			MetadataItem{"value_scale", formatMetadataValue(georef.ValueScale)})
+	} else {
+		georef.Metadata = append(georef.Metadata, MetadataItem{"elevation_range",
+			formatMetadataValue(elevationMap.MinElevation) + " " + formatMetadataValue(elevationMap.MaxElevation)})
+	}
+	return georef.withExtent()
+}
+
+// withExtent puts the extent and the pixel size before the other metadata.
+func (georef ImageGeoreference) withExtent() ImageGeoreference {
+	minY := georef.MaxY - float64(georef.Height)*georef.PixelSize
+	maxX := georef.MinX + float64(georef.Width)*georef.PixelSize
+	extent := []string{}
+	for _, value := range []float64{georef.MinX, minY, maxX, georef.MaxY} {
+		extent = append(extent, formatMetadataValue(value))
+	}
+	georef.Metadata = append([]MetadataItem{
+		{"extent", strings.Join(extent, " ")},
+		{"pixel_size", formatMetadataValue(georef.PixelSize)},
+	}, georef.Metadata...)
+	return georef
+}
+
+func formatMetadataValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// WriteGeoreferencedPNG encodes img with the metadata of georef, and its CRS,
+// in tEXt chunks.
+func WriteGeoreferencedPNG(writer *bufio.Writer, img image.Image, georef ImageGeoreference) error {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return fmt.Errorf("error encoding PNG: %v", err)
+	}
+	data := buffer.Bytes()
+
+	// The text chunks go after the signature and the IHDR chunk, which has
+	// 13 bytes of data and 12 of length, type and CRC.
+	headerEnd := 8 + 12 + 13
+	if _, err := writer.Write(data[:headerEnd]); err != nil {
+		return err
+	}
+	metadata := georef.Metadata
+	if georef.CRS != nil {
+		metadata = append(metadata, MetadataItem{"crs", georef.CRS.String()})
+	}
+	metadata = append(metadata, MetadataItem{"Software", "asctools"})
+	for _, item := range metadata {
+		if err := writePNGChunk(writer, "tEXt", []byte(item.Key+"\x00"+item.Value)); err != nil {
+			return err
+		}
+	}
+	if _, err := writer.Write(data[headerEnd:]); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func writePNGChunk(writer *bufio.Writer, chunkType string, data []byte) error {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	var checksum [4]byte
+	binary.BigEndian.PutUint32(checksum[:], crc.Sum32())
+
+	for _, part := range [][]byte{length[:], []byte(chunkType), data, checksum[:]} {
+		if _, err := writer.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePNGSidecars writes a .pgw world file next to the PNG at path, a .prj
+// file when the CRS is known and, when auxXML is set, a GDAL .aux.xml file
+// with the georeference and metadata.
+func WritePNGSidecars(path string, georef ImageGeoreference, auxXML bool) error {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+
+	// World files give the centre of the top left pixel.
+	worldFile := []float64{georef.PixelSize, 0, 0, -georef.PixelSize, georef.MinX + georef.PixelSize/2, georef.MaxY - georef.PixelSize/2}
+	lines := make([]string, len(worldFile))
+	for i, value := range worldFile {
+		lines[i] = formatMetadataValue(value)
+	}
+	if err := os.WriteFile(base+".pgw", []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+
+	if georef.CRS != nil {
+		if err := os.WriteFile(base+".prj", []byte(georef.CRS.WKT), 0644); err != nil {
+			return err
+		}
+	}
+
+	if auxXML {
+		data, err := georef.auxXML()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+".aux.xml", data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type pamDataset struct {
+	XMLName      xml.Name        `xml:"PAMDataset"`
+	SRS          string          `xml:"SRS,omitempty"`
+	GeoTransform string          `xml:"GeoTransform"`
+	Metadata     []pamMetadata   `xml:"Metadata>MDI"`
+	Bands        []pamRasterBand `xml:"PAMRasterBand"`
+}
+
+type pamMetadata struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type pamRasterBand struct {
+	Band   int     `xml:"band,attr"`
+	Offset float64 `xml:"Offset"`
+	Scale  float64 `xml:"Scale"`
+}
+
+// auxXML returns a GDAL PAM dataset, which GDAL and QGIS read next to the
+// image.
+func (georef ImageGeoreference) auxXML() ([]byte, error) {
+	transform := []float64{georef.MinX, georef.PixelSize, 0, georef.MaxY, 0, -georef.PixelSize}
+	values := make([]string, len(transform))
+	for i, value := range transform {
+		values[i] = fmt.Sprintf("%.16e", value)
+	}
+	dataset := pamDataset{GeoTransform: strings.Join(values, ", ")}
+	if georef.CRS != nil {
+		dataset.SRS = georef.CRS.WKT
+	}
+	for _, item := range georef.Metadata {
+		dataset.Metadata = append(dataset.Metadata, pamMetadata{item.Key, item.Value})
+	}
+	if georef.ValueScale != 0 {
+		dataset.Bands = []pamRasterBand{{Band: 1, Offset: georef.ValueOffset, Scale: georef.ValueScale}}
+	}
+
+	data, err := xml.MarshalIndent(dataset, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
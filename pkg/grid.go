@@ -0,0 +1,223 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+type GridExtent int
+
+const (
+	ExtentIntersection GridExtent = iota
+	ExtentUnion
+	ExtentFirst
+)
+
+type Resampling int
+
+const (
+	ResampleNearest Resampling = iota
+	ResampleBilinear
+	ResampleCubic
+)
+
+func ParseGridExtent(value string) (GridExtent, error) {
+	switch value {
+	case "intersection", "":
+		return ExtentIntersection, nil
+	case "union":
+		return ExtentUnion, nil
+	case "first":
+		return ExtentFirst, nil
+	default:
+		return ExtentIntersection, fmt.Errorf("unknown grid extent: %s", value)
+	}
+}
+
+func ParseResampling(value string) (Resampling, error) {
+	switch value {
+	case "nearest", "":
+		return ResampleNearest, nil
+	case "bilinear":
+		return ResampleBilinear, nil
+	case "cubic":
+		return ResampleCubic, nil
+	default:
+		return ResampleNearest, fmt.Errorf("unknown resampling method: %s", value)
+	}
+}
+
+// CommonGrid returns an empty map covering the chosen extent of all maps,
+// rounded outward to whole cells.
+// A cellSize of 0 picks the coarsest cell size of the inputs, or the first
+// map's cell size for ExtentFirst.
+func CommonGrid(maps []*ElevationMap, extent GridExtent, cellSize float64) (*ElevationMap, error) {
+	if len(maps) == 0 {
+		return nil, fmt.Errorf("no maps to align")
+	}
+	crs, err := commonCRS(maps...)
+	if err != nil {
+		return nil, err
+	}
+
+	first := maps[0]
+	minX, minY, maxX, maxY := first.MinX, first.MinY, first.MaxX, first.MaxY
+
+	if extent != ExtentFirst {
+		for _, m := range maps[1:] {
+			if extent == ExtentIntersection {
+				minX = math.Max(minX, m.MinX)
+				minY = math.Max(minY, m.MinY)
+				maxX = math.Min(maxX, m.MaxX)
+				maxY = math.Min(maxY, m.MaxY)
+			} else {
+				minX = math.Min(minX, m.MinX)
+				minY = math.Min(minY, m.MinY)
+				maxX = math.Max(maxX, m.MaxX)
+				maxY = math.Max(maxY, m.MaxY)
+			}
+		}
+	}
+
+	if minX >= maxX || minY >= maxY {
+		return nil, fmt.Errorf("elevation maps do not overlap")
+	}
+
+	if cellSize <= 0 {
+		cellSize = first.CellSize
+		if extent != ExtentFirst {
+			for _, m := range maps[1:] {
+				cellSize = math.Max(cellSize, m.CellSize)
+			}
+		}
+	}
+
+	// The grid starts at the lower left corner of the extent and covers it
+	// with whole cells, the last ones reaching past it when the extent is
+	// not a multiple of the cell size.
+	numCols := int(math.Ceil((maxX-minX)/cellSize - 1e-9))
+	numRows := int(math.Ceil((maxY-minY)/cellSize - 1e-9))
+	grid := makeElevationMapWithSize(minX, minY, numRows, numCols, cellSize)
+	grid.CRS = crs
+
+	return grid, nil
+}
+
+// alignToGrid resamples the maps that are not already on grid.
+func alignToGrid(maps []*ElevationMap, grid *ElevationMap, method Resampling) []*ElevationMap {
+	aligned := make([]*ElevationMap, len(maps))
+	for i, elevationMap := range maps {
+		if sameGrid(elevationMap, grid) {
+			aligned[i] = elevationMap
+		} else {
+			aligned[i] = elevationMap.ResampleTo(grid, method)
+		}
+	}
+	return aligned
+}
+
+func sameGrid(elevationMap, grid *ElevationMap) bool {
+	return elevationMap.MinX == grid.MinX && elevationMap.MinY == grid.MinY &&
+		elevationMap.NumRows == grid.NumRows && elevationMap.NumCols == grid.NumCols &&
+		elevationMap.CellSize == grid.CellSize
+}
+
+// ResampleTo samples the map at the cell centres of grid.
+func (elevationMap *ElevationMap) ResampleTo(grid *ElevationMap, method Resampling) *ElevationMap {
+	result := makeElevationMapWithSize(grid.MinX, grid.MinY, grid.NumRows, grid.NumCols, grid.CellSize)
+	result.CRS = elevationMap.CRS
+
+	for row := 0; row < result.NumRows; row++ {
+		for col := 0; col < result.NumCols; col++ {
+			x, y := result.CellCenter(row, col)
+			result.SetRowCol(row, col, elevationMap.Sample(x, y, method))
+		}
+	}
+	result.UpdateElevationRange()
+
+	return result
+}
+
+func (elevationMap *ElevationMap) Sample(x, y float64, method Resampling) float64 {
+	if method == ResampleNearest {
+		return elevationMap.GetElevation(x, y)
+	}
+
+	if x < elevationMap.MinX || x >= elevationMap.MaxX || y < elevationMap.MinY || y >= elevationMap.MaxY {
+		return NodataValue
+	}
+
+	fx := (x-elevationMap.MinX)/elevationMap.CellSize - 0.5
+	fy := (y-elevationMap.MinY)/elevationMap.CellSize - 0.5
+	fx = math.Max(0, math.Min(fx, float64(elevationMap.NumCols-1)))
+	fy = math.Max(0, math.Min(fy, float64(elevationMap.NumRows-1)))
+
+	col0 := int(fx)
+	row0 := int(fy)
+	col1 := min(col0+1, elevationMap.NumCols-1)
+	row1 := min(row0+1, elevationMap.NumRows-1)
+	tx := fx - float64(col0)
+	ty := fy - float64(row0)
+
+	v00 := elevationMap.GetRowCol(row0, col0, true)
+	v10 := elevationMap.GetRowCol(row0, col1, true)
+	v01 := elevationMap.GetRowCol(row1, col0, true)
+	v11 := elevationMap.GetRowCol(row1, col1, true)
+	if v00 == NodataValue || v10 == NodataValue || v01 == NodataValue || v11 == NodataValue {
+		return elevationMap.GetElevation(x, y)
+	}
+
+	if method == ResampleCubic {
+		if value, ok := elevationMap.sampleCubic(col0, row0, tx, ty); ok {
+			return value
+		}
+	}
+
+	top := v00*(1-tx) + v10*tx
+	bottom := v01*(1-tx) + v11*tx
+	return top*(1-ty) + bottom*ty
+}
+
+// sampleCubic interpolates the 4x4 cells around a point with Catmull-Rom
+// splines, clamping the cells at the map edges. It is not ok when one of
+// them is nodata, in which case callers fall back to bilinear.
+func (elevationMap *ElevationMap) sampleCubic(col0, row0 int, tx, ty float64) (float64, bool) {
+	var rows [4]float64
+	for j := range rows {
+		row := max(0, min(row0+j-1, elevationMap.NumRows-1))
+		var values [4]float64
+		for i := range values {
+			col := max(0, min(col0+i-1, elevationMap.NumCols-1))
+			values[i] = elevationMap.GetRowCol(row, col, true)
+			if values[i] == NodataValue {
+				return 0, false
+			}
+		}
+		rows[j] = catmullRom(values, tx)
+	}
+	return catmullRom(rows, ty), true
+}
+
+func catmullRom(v [4]float64, t float64) float64 {
+	return v[1] + 0.5*t*(v[2]-v[0]+t*(2*v[0]-5*v[1]+4*v[2]-v[3]+t*(3*(v[1]-v[2])+v[3]-v[0])))
+}
+
+// CellCenter returns the world coordinates of the centre of a cell given in
+// file order, where row 0 is the northernmost row.
+func (elevationMap *ElevationMap) CellCenter(row, col int) (float64, float64) {
+	x := elevationMap.MinX + (float64(col)+0.5)*elevationMap.CellSize
+	y := elevationMap.MinY + (float64(elevationMap.NumRows-1-row)+0.5)*elevationMap.CellSize
+	return x, y
+}
+
+func (elevationMap *ElevationMap) UpdateElevationRange() {
+	elevationMap.MinElevation = math.MaxFloat64
+	elevationMap.MaxElevation = -math.MaxFloat64
+	for _, value := range elevationMap.Data {
+		if value == NodataValue {
+			continue
+		}
+		elevationMap.MinElevation = math.Min(elevationMap.MinElevation, float64(value))
+		elevationMap.MaxElevation = math.Max(elevationMap.MaxElevation, float64(value))
+	}
+}
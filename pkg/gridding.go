@@ -0,0 +1,380 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type GridMethod int
+
+const (
+	GridMin GridMethod = iota
+	GridMax
+	GridMean
+	// GridIDW is the inverse distance weighted mean of the points within the
+	// search radius of a cell centre.
+	GridIDW
+	// GridNearest takes the point closest to a cell centre within the search
+	// radius.
+	GridNearest
+	// GridTIN interpolates linearly on the Delaunay triangulation of the
+	// points, which suits sparse data.
+	GridTIN
+)
+
+func ParseGridMethod(value string) (GridMethod, error) {
+	switch value {
+	case "min":
+		return GridMin, nil
+	case "max":
+		return GridMax, nil
+	case "mean", "":
+		return GridMean, nil
+	case "idw":
+		return GridIDW, nil
+	case "nearest":
+		return GridNearest, nil
+	case "tin":
+		return GridTIN, nil
+	default:
+		return GridMean, fmt.Errorf("unknown gridding method: %s", value)
+	}
+}
+
+type GridOptions struct {
+	// OriginX and OriginY are the lower left corner of the grid. When the
+	// grid size is 0, the grid is fitted to the points and the origin only
+	// aligns it to whole cells.
+	OriginX, OriginY float64
+	CellSize         float64
+	NumRows, NumCols int
+	Method           GridMethod
+	// Radius is the search radius of IDW and nearest gridding. 0 means twice
+	// the cell size.
+	Radius float64
+	// Power is the IDW distance exponent.
+	Power float64
+	// MaxEdge leaves TIN triangles with a longer side as nodata, so that
+	// gaps in the data are not bridged. 0 means no limit.
+	MaxEdge float64
+}
+
+func DefaultGridOptions() GridOptions {
+	return GridOptions{
+		CellSize: 1,
+		Method:   GridMean,
+		Power:    2,
+	}
+}
+
+// GridResult holds the gridded elevations and, for quality checks, the
+// number of points in each cell and the number per unit of area.
+type GridResult struct {
+	Elevation *ElevationMap
+	Count     *ElevationMap
+	Density   *ElevationMap
+}
+
+type pointGrid struct {
+	minX, minY       float64
+	cellSize         float64
+	numRows, numCols int
+	points           []Point
+	// binStart indexes the points of each cell, with rows counted from the
+	// bottom, in sorted order: those of cell i are sorted[binStart[i]:binStart[i+1]].
+	binStart []int
+	sorted   []int
+}
+
+// GridPoints builds an elevation map from scattered points.
+func GridPoints(points []Point, options GridOptions) (*GridResult, error) {
+	if options.CellSize <= 0 {
+		return nil, fmt.Errorf("cell size must be greater than 0")
+	}
+	if options.NumRows < 0 || options.NumCols < 0 || options.Radius < 0 || options.MaxEdge < 0 {
+		return nil, fmt.Errorf("grid size, radius and maximum edge must not be negative")
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points to grid")
+	}
+
+	grid := newPointGrid(points, options)
+	radius := options.Radius
+	if radius == 0 {
+		radius = 2 * options.CellSize
+	}
+
+	var elevation *ElevationMap
+	var err error
+	switch options.Method {
+	case GridMin, GridMax, GridMean:
+		elevation = grid.binned(options.Method)
+	case GridIDW, GridNearest:
+		elevation = grid.searched(options.Method, radius, options.Power)
+	case GridTIN:
+		elevation, err = grid.triangulated(options.MaxEdge)
+	default:
+		err = fmt.Errorf("unknown gridding method")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	count := grid.newMap()
+	density := grid.newMap()
+	for row := 0; row < grid.numRows; row++ {
+		for col := 0; col < grid.numCols; col++ {
+			i := row*grid.numCols + col
+			n := float64(grid.binStart[i+1] - grid.binStart[i])
+			count.SetRowCol(grid.numRows-1-row, col, n)
+			density.SetRowCol(grid.numRows-1-row, col, n/(grid.cellSize*grid.cellSize))
+		}
+	}
+	count.UpdateElevationRange()
+	density.UpdateElevationRange()
+
+	return &GridResult{Elevation: elevation, Count: count, Density: density}, nil
+}
+
+func newPointGrid(points []Point, options GridOptions) *pointGrid {
+	grid := &pointGrid{
+		minX:     options.OriginX,
+		minY:     options.OriginY,
+		cellSize: options.CellSize,
+		numRows:  options.NumRows,
+		numCols:  options.NumCols,
+		points:   points,
+	}
+	if grid.numRows == 0 || grid.numCols == 0 {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, p := range points {
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+		grid.minX += math.Floor((minX-grid.minX)/grid.cellSize) * grid.cellSize
+		grid.minY += math.Floor((minY-grid.minY)/grid.cellSize) * grid.cellSize
+		grid.numCols = int(math.Floor((maxX-grid.minX)/grid.cellSize)) + 1
+		grid.numRows = int(math.Floor((maxY-grid.minY)/grid.cellSize)) + 1
+	}
+
+	numCells := grid.numRows * grid.numCols
+	bins := make([]int, len(points))
+	grid.binStart = make([]int, numCells+1)
+	for i, p := range points {
+		bins[i] = grid.bin(p)
+		if bins[i] >= 0 {
+			grid.binStart[bins[i]+1]++
+		}
+	}
+	for i := 0; i < numCells; i++ {
+		grid.binStart[i+1] += grid.binStart[i]
+	}
+	grid.sorted = make([]int, grid.binStart[numCells])
+	next := append([]int{}, grid.binStart[:numCells]...)
+	for i, bin := range bins {
+		if bin >= 0 {
+			grid.sorted[next[bin]] = i
+			next[bin]++
+		}
+	}
+
+	return grid
+}
+
+// bin returns the cell of a point, or -1 outside the grid.
+func (grid *pointGrid) bin(p Point) int {
+	col := int(math.Floor((p.X - grid.minX) / grid.cellSize))
+	row := int(math.Floor((p.Y - grid.minY) / grid.cellSize))
+	if col < 0 || row < 0 || col >= grid.numCols || row >= grid.numRows {
+		return -1
+	}
+	return row*grid.numCols + col
+}
+
+func (grid *pointGrid) cellPoints(row, col int) []int {
+	i := row*grid.numCols + col
+	return grid.sorted[grid.binStart[i]:grid.binStart[i+1]]
+}
+
+func (grid *pointGrid) newMap() *ElevationMap {
+	return makeElevationMapWithSize(grid.minX, grid.minY, grid.numRows, grid.numCols, grid.cellSize)
+}
+
+func (grid *pointGrid) binned(method GridMethod) *ElevationMap {
+	result := grid.newMap()
+	for row := 0; row < grid.numRows; row++ {
+		for col := 0; col < grid.numCols; col++ {
+			indices := grid.cellPoints(row, col)
+			if len(indices) == 0 {
+				continue
+			}
+			value := grid.points[indices[0]].Z
+			sum := 0.0
+			for _, i := range indices {
+				z := grid.points[i].Z
+				switch method {
+				case GridMin:
+					value = math.Min(value, z)
+				case GridMax:
+					value = math.Max(value, z)
+				}
+				sum += z
+			}
+			if method == GridMean {
+				value = sum / float64(len(indices))
+			}
+			result.SetRowCol(grid.numRows-1-row, col, value)
+		}
+	}
+	result.UpdateElevationRange()
+	return result
+}
+
+func (grid *pointGrid) searched(method GridMethod, radius, power float64) *ElevationMap {
+	result := grid.newMap()
+	reach := int(math.Ceil(radius / grid.cellSize))
+	for row := 0; row < grid.numRows; row++ {
+		y := grid.minY + (float64(row)+0.5)*grid.cellSize
+		for col := 0; col < grid.numCols; col++ {
+			x := grid.minX + (float64(col)+0.5)*grid.cellSize
+
+			nearest, nearestDistance := -1, math.Inf(1)
+			var weightedSum, weights float64
+			exact := false
+			for r := max(0, row-reach); r <= min(grid.numRows-1, row+reach) && !exact; r++ {
+				for c := max(0, col-reach); c <= min(grid.numCols-1, col+reach) && !exact; c++ {
+					for _, i := range grid.cellPoints(r, c) {
+						p := grid.points[i]
+						distance := math.Hypot(p.X-x, p.Y-y)
+						if distance > radius {
+							continue
+						}
+						if distance < nearestDistance {
+							nearest, nearestDistance = i, distance
+						}
+						if method == GridIDW {
+							if distance == 0 {
+								exact = true
+								break
+							}
+							weight := math.Pow(distance, -power)
+							weightedSum += weight * p.Z
+							weights += weight
+						}
+					}
+				}
+			}
+
+			if nearest < 0 {
+				continue
+			}
+			value := grid.points[nearest].Z
+			if method == GridIDW && !exact {
+				value = weightedSum / weights
+			}
+			result.SetRowCol(grid.numRows-1-row, col, value)
+		}
+	}
+	result.UpdateElevationRange()
+	return result
+}
+
+// triangulated interpolates on the Delaunay triangulation of the points.
+// Points are inserted cell by cell, so that each point is found by a short
+// walk from the previous one. Of points sharing a position only the first
+// is used.
+func (grid *pointGrid) triangulated(maxEdge float64) (*ElevationMap, error) {
+	width := float64(grid.numCols) * grid.cellSize
+	height := float64(grid.numRows) * grid.cellSize
+	// Coordinates are relative to the grid, which keeps them precise when
+	// they are stored as float32 for rasterizing.
+	d := newDelaunayRectangle(-grid.cellSize, -grid.cellSize, width+grid.cellSize, height+grid.cellSize)
+	z := []float64{0, 0, 0, 0}
+
+	order := make([]int, 0, len(grid.sorted))
+	for row := 0; row < grid.numRows; row++ {
+		for k := 0; k < grid.numCols; k++ {
+			col := k
+			if row%2 == 1 {
+				col = grid.numCols - 1 - k
+			}
+			order = append(order, grid.cellPoints(row, col)...)
+		}
+	}
+
+	last := 0
+	for _, i := range order {
+		p := delaunayPoint{grid.points[i].X - grid.minX, grid.points[i].Y - grid.minY}
+		triangle := d.locate(p, last)
+		if triangle < 0 {
+			continue
+		}
+		duplicate := false
+		for _, v := range d.triangles[triangle].vertices {
+			if d.points[v] == p {
+				duplicate = true
+			}
+		}
+		if duplicate {
+			continue
+		}
+		point := d.addPoint(p.X, p.Y)
+		z = append(z, grid.points[i].Z)
+		created := d.insert(point, triangle)
+		last = created[0]
+	}
+
+	triangles := []Triangle{}
+	for _, t := range d.triangles {
+		if t.dead || t.vertices[0] < 4 || t.vertices[1] < 4 || t.vertices[2] < 4 {
+			continue
+		}
+		tooLong := false
+		for k := 0; k < 3; k++ {
+			a, b := d.points[t.vertices[k]], d.points[t.vertices[(k+1)%3]]
+			if maxEdge > 0 && math.Hypot(a.X-b.X, a.Y-b.Y) > maxEdge {
+				tooLong = true
+			}
+		}
+		if tooLong {
+			continue
+		}
+		vertex := func(v int) Vector3 {
+			return Vector3{float32(d.points[v].X), float32(d.points[v].Y), float32(z[v])}
+		}
+		triangles = append(triangles, makeTriangle(vertex(t.vertices[0]), vertex(t.vertices[1]), vertex(t.vertices[2])))
+	}
+	if len(triangles) == 0 {
+		return nil, fmt.Errorf("points do not span any triangle")
+	}
+
+	result, err := RasterizeTriangles(triangles, RasterizeOptions{
+		CellSize: grid.cellSize,
+		NumRows:  grid.numRows,
+		NumCols:  grid.numCols,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.MinX, result.MinY = grid.minX, grid.minY
+	result.MaxX, result.MaxY = grid.minX+width, grid.minY+height
+	return result, nil
+}
+
+// ParsePointClasses parses a comma separated list of LAS classes.
+func ParsePointClasses(value string) ([]uint8, error) {
+	classes := []uint8{}
+	for _, field := range strings.Split(value, ",") {
+		class, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || class < 0 || class > 255 {
+			return nil, fmt.Errorf("invalid point class: %s", field)
+		}
+		classes = append(classes, uint8(class))
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	return classes, nil
+}
@@ -0,0 +1,316 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+// gridHole is a square hole into a straight stretch of wall, from the start
+// to the end boundary grid point inclusive. Heights are above the floor and
+// depth is in map units.
+type gridHole struct {
+	startRow, startCol int
+	endRow, endCol     int
+	inwardX, inwardY   int
+	z0, z1             float64
+	depth              float64
+}
+
+// gridMesher builds a closed solid over grid points [row0, row1] x
+// [col0, col1], with rows counted from the bottom. Vertex coordinates are in
+// map units from the first grid point and elevation units above the floor.
+type gridMesher struct {
+	elevationMap   *ElevationMap
+	floorElevation float64
+	row0, col0     int
+	row1, col1     int
+	// bottom, when set, is the height of the base under a grid point.
+	bottom func(row, col int) float64
+	holes  []gridHole
+
+	mesh        *Mesh
+	cells       []bool
+	top         []int
+	floor       []int
+	levels      map[int][]int
+	holeAtPoint map[int]int
+}
+
+// BuildGridMesh builds a closed solid with two surface triangles per grid
+// cell. Cells with a nodata or sub-floor corner are left out, and walls
+// follow the edges of the remaining surface, including interior holes, down
+// to a base mirroring the surface.
+func (elevationMap *ElevationMap) BuildGridMesh(floorElevation float64) (*Mesh, error) {
+	mesher := &gridMesher{
+		elevationMap:   elevationMap,
+		floorElevation: floorElevation,
+		row1:           elevationMap.NumRows - 1,
+		col1:           elevationMap.NumCols - 1,
+	}
+	return mesher.build()
+}
+
+func (mesher *gridMesher) numCellRows() int { return mesher.row1 - mesher.row0 }
+func (mesher *gridMesher) numCellCols() int { return mesher.col1 - mesher.col0 }
+
+func (mesher *gridMesher) pointIndex(row, col int) int {
+	return (row-mesher.row0)*(mesher.numCellCols()+1) + col - mesher.col0
+}
+
+func (mesher *gridMesher) valid(row, col int) bool {
+	e := mesher.elevationMap.GetRowCol(row, col, true)
+	return e != NodataValue && e >= mesher.floorElevation
+}
+
+func (mesher *gridMesher) hasCell(row, col int) bool {
+	if row < mesher.row0 || col < mesher.col0 || row >= mesher.row1 || col >= mesher.col1 {
+		return false
+	}
+	return mesher.cells[(row-mesher.row0)*mesher.numCellCols()+col-mesher.col0]
+}
+
+func (mesher *gridMesher) setCell(row, col int, value bool) {
+	mesher.cells[(row-mesher.row0)*mesher.numCellCols()+col-mesher.col0] = value
+}
+
+func (mesher *gridMesher) build() (*Mesh, error) {
+	if mesher.numCellRows() < 1 || mesher.numCellCols() < 1 {
+		return nil, fmt.Errorf("map must have at least 2 rows and 2 columns")
+	}
+
+	mesher.selectCells()
+
+	mesher.mesh = &Mesh{}
+	numPoints := (mesher.numCellRows() + 1) * (mesher.numCellCols() + 1)
+	mesher.top = make([]int, numPoints)
+	mesher.floor = make([]int, numPoints)
+	for i := range mesher.top {
+		mesher.top[i], mesher.floor[i] = -1, -1
+	}
+	mesher.levels = map[int][]int{}
+	mesher.holeAtPoint = map[int]int{}
+	for i, hole := range mesher.holes {
+		mesher.forHolePoints(hole, func(row, col int) {
+			mesher.holeAtPoint[mesher.pointIndex(row, col)] = i
+		})
+	}
+
+	for row := mesher.row0; row < mesher.row1; row++ {
+		for col := mesher.col0; col < mesher.col1; col++ {
+			if !mesher.hasCell(row, col) {
+				continue
+			}
+			v00, f00 := mesher.vertex(row, col)
+			v01, f01 := mesher.vertex(row, col+1)
+			v10, f10 := mesher.vertex(row+1, col)
+			v11, f11 := mesher.vertex(row+1, col+1)
+
+			mesher.mesh.AddFace(v00, v01, v10)
+			mesher.mesh.AddFace(v01, v11, v10)
+			mesher.mesh.AddFace(f00, f10, f01)
+			mesher.mesh.AddFace(f01, f10, f11)
+
+			if !mesher.hasCell(row-1, col) {
+				mesher.wall(row, col, row, col+1)
+			}
+			if !mesher.hasCell(row, col+1) {
+				mesher.wall(row, col+1, row+1, col+1)
+			}
+			if !mesher.hasCell(row+1, col) {
+				mesher.wall(row+1, col+1, row+1, col)
+			}
+			if !mesher.hasCell(row, col-1) {
+				mesher.wall(row+1, col, row, col)
+			}
+		}
+	}
+
+	if len(mesher.mesh.Faces) == 0 {
+		return nil, fmt.Errorf("map has no cells with valid elevations above the floor")
+	}
+
+	for _, hole := range mesher.holes {
+		mesher.holeFaces(hole)
+	}
+
+	return mesher.mesh, nil
+}
+
+// selectCells keeps the cells whose corners are all valid.
+func (mesher *gridMesher) selectCells() {
+	mesher.cells = make([]bool, mesher.numCellRows()*mesher.numCellCols())
+	for row := mesher.row0; row < mesher.row1; row++ {
+		for col := mesher.col0; col < mesher.col1; col++ {
+			mesher.setCell(row, col, mesher.valid(row, col) && mesher.valid(row, col+1) && mesher.valid(row+1, col) && mesher.valid(row+1, col+1))
+		}
+	}
+
+	// Two cells touching only at a corner would make that vertex
+	// non-manifold, so one of them is dropped until no such corner is left.
+	for changed := true; changed; {
+		changed = false
+		for row := mesher.row0; row <= mesher.row1; row++ {
+			for col := mesher.col0; col <= mesher.col1; col++ {
+				sw, se := mesher.hasCell(row-1, col-1), mesher.hasCell(row-1, col)
+				nw, ne := mesher.hasCell(row, col-1), mesher.hasCell(row, col)
+				if sw && ne && !se && !nw {
+					mesher.setCell(row, col, false)
+					changed = true
+				} else if se && nw && !sw && !ne {
+					mesher.setCell(row, col-1, false)
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+func (mesher *gridMesher) position(row, col int) (float32, float32) {
+	x := float32(float64(col-mesher.col0) * mesher.elevationMap.CellSize)
+	y := float32(float64(row-mesher.row0) * mesher.elevationMap.CellSize)
+	return x, y
+}
+
+func (mesher *gridMesher) vertex(row, col int) (int, int) {
+	i := mesher.pointIndex(row, col)
+	if mesher.top[i] < 0 {
+		x, y := mesher.position(row, col)
+		bottom := 0.0
+		if mesher.bottom != nil {
+			bottom = mesher.bottom(row, col)
+		}
+		z := math.Max(mesher.elevationMap.GetRowCol(row, col, true)-mesher.floorElevation, bottom+minSurfaceHeight)
+		mesher.top[i] = mesher.mesh.AddVertex(Vector3{x, y, float32(z)})
+		mesher.floor[i] = mesher.mesh.AddVertex(Vector3{x, y, float32(bottom)})
+	}
+	return mesher.top[i], mesher.floor[i]
+}
+
+// wallChain lists the wall vertices below a boundary grid point, from the
+// floor up.
+func (mesher *gridMesher) wallChain(row, col int) []int {
+	top, floor := mesher.vertex(row, col)
+	i := mesher.pointIndex(row, col)
+	holeIndex, ok := mesher.holeAtPoint[i]
+	if !ok {
+		return []int{floor, top}
+	}
+	if _, ok := mesher.levels[i]; !ok {
+		hole := mesher.holes[holeIndex]
+		x, y := mesher.position(row, col)
+		mesher.levels[i] = []int{
+			mesher.mesh.AddVertex(Vector3{x, y, float32(hole.z0)}),
+			mesher.mesh.AddVertex(Vector3{x, y, float32(hole.z1)}),
+		}
+	}
+	return []int{floor, mesher.levels[i][0], mesher.levels[i][1], top}
+}
+
+// wall closes the boundary edge from grid point a to b, which runs
+// counter-clockwise around the surface.
+func (mesher *gridMesher) wall(rowA, colA, rowB, colB int) {
+	a, af := mesher.vertex(rowA, colA)
+	b, bf := mesher.vertex(rowB, colB)
+	left := mesher.wallChain(rowA, colA)
+	right := mesher.wallChain(rowB, colB)
+	if len(left) == 2 && len(right) == 2 {
+		mesher.mesh.AddFace(a, af, bf)
+		mesher.mesh.AddFace(a, bf, b)
+		return
+	}
+
+	outward := Vector3{float32(rowB - rowA), float32(colA - colB), 0}
+	holeA, okA := mesher.holeAtPoint[mesher.pointIndex(rowA, colA)]
+	holeB, okB := mesher.holeAtPoint[mesher.pointIndex(rowB, colB)]
+	if okA && okB && holeA == holeB {
+		mesher.zipper(left[:2], right[:2], outward)
+		mesher.zipper(left[2:], right[2:], outward)
+		return
+	}
+	mesher.zipper(left, right, outward)
+}
+
+// zipper triangulates the vertical strip between two chains of vertices
+// sorted from the bottom up.
+func (mesher *gridMesher) zipper(left, right []int, outward Vector3) {
+	i, j := 0, 0
+	for i < len(left)-1 || j < len(right)-1 {
+		advanceLeft := j == len(right)-1 ||
+			(i < len(left)-1 && mesher.mesh.Vertices[left[i+1]].Z <= mesher.mesh.Vertices[right[j+1]].Z)
+		if advanceLeft {
+			mesher.addOrientedFace(left[i], left[i+1], right[j], outward)
+			i++
+		} else {
+			mesher.addOrientedFace(left[i], right[j+1], right[j], outward)
+			j++
+		}
+	}
+}
+
+// addOrientedFace adds the triangle wound so that its normal points along
+// direction.
+func (mesher *gridMesher) addOrientedFace(a, b, c int, direction Vector3) {
+	n := calculateNormal(mesher.mesh.Vertices[a], mesher.mesh.Vertices[b], mesher.mesh.Vertices[c])
+	if n.X*direction.X+n.Y*direction.Y+n.Z*direction.Z < 0 {
+		b, c = c, b
+	}
+	mesher.mesh.AddFace(a, b, c)
+}
+
+func (mesher *gridMesher) addOrientedQuad(a, b, c, d int, direction Vector3) {
+	mesher.addOrientedFace(a, b, c, direction)
+	mesher.addOrientedFace(a, c, d, direction)
+}
+
+func (mesher *gridMesher) forHolePoints(hole gridHole, fn func(row, col int)) {
+	dRow, dCol := sign(hole.endRow-hole.startRow), sign(hole.endCol-hole.startCol)
+	row, col := hole.startRow, hole.startCol
+	for {
+		fn(row, col)
+		if row == hole.endRow && col == hole.endCol {
+			return
+		}
+		row, col = row+dRow, col+dCol
+	}
+}
+
+// holeFaces builds the inside of a hole as a tube from its rim in the wall
+// to a flat end. Faces point into the hole.
+func (mesher *gridMesher) holeFaces(hole gridHole) {
+	var rimBottom, rimTop, innerBottom, innerTop []int
+	offsetX := float32(float64(hole.inwardX) * hole.depth)
+	offsetY := float32(float64(hole.inwardY) * hole.depth)
+	mesher.forHolePoints(hole, func(row, col int) {
+		levels := mesher.levels[mesher.pointIndex(row, col)]
+		rimBottom = append(rimBottom, levels[0])
+		rimTop = append(rimTop, levels[1])
+		x, y := mesher.position(row, col)
+		innerBottom = append(innerBottom, mesher.mesh.AddVertex(Vector3{x + offsetX, y + offsetY, float32(hole.z0)}))
+		innerTop = append(innerTop, mesher.mesh.AddVertex(Vector3{x + offsetX, y + offsetY, float32(hole.z1)}))
+	})
+
+	up := Vector3{0, 0, 1}
+	down := Vector3{0, 0, -1}
+	opening := Vector3{float32(-hole.inwardX), float32(-hole.inwardY), 0}
+	along := Vector3{float32(sign(hole.endCol - hole.startCol)), float32(sign(hole.endRow - hole.startRow)), 0}
+	back := Vector3{-along.X, -along.Y, 0}
+
+	for k := 0; k+1 < len(rimBottom); k++ {
+		mesher.addOrientedQuad(rimBottom[k], rimBottom[k+1], innerBottom[k+1], innerBottom[k], up)
+		mesher.addOrientedQuad(rimTop[k], rimTop[k+1], innerTop[k+1], innerTop[k], down)
+		mesher.addOrientedQuad(innerBottom[k], innerBottom[k+1], innerTop[k+1], innerTop[k], opening)
+	}
+	last := len(rimBottom) - 1
+	mesher.addOrientedQuad(rimBottom[0], rimTop[0], innerTop[0], innerBottom[0], along)
+	mesher.addOrientedQuad(rimBottom[last], rimTop[last], innerTop[last], innerBottom[last], back)
+}
+
+func sign(value int) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package asctools
+
+import (
+	"image/color"
+	"math"
+)
+
+const (
+	// defaultHillshadeAzimuth and defaultHillshadeAltitude light rendered
+	// maps and lithophanes from the north west, as cartographers usually do.
+	defaultHillshadeAzimuth  = 315
+	defaultHillshadeAltitude = 45
+	// hillshadeBlendStrength is how much fully shaded slopes darken colours
+	// blended with a hillshade.
+	hillshadeBlendStrength = 0.6
+)
+
+// Hillshade returns the illumination of every cell in the [0, 1] range, lit
+// from azimuth degrees clockwise from north and altitude degrees above the
+// horizon. Slopes use Horn's method with elevations multiplied by zFactor,
+// and missing neighbours are replaced by the cell itself. Nodata cells stay
+// nodata.
+func (elevationMap *ElevationMap) Hillshade(azimuth, altitude, zFactor float64) *ElevationMap {
+	azimuthRad := azimuth * math.Pi / 180
+	altitudeRad := altitude * math.Pi / 180
+	lightX := math.Sin(azimuthRad) * math.Cos(altitudeRad)
+	lightY := math.Cos(azimuthRad) * math.Cos(altitudeRad)
+	lightZ := math.Sin(altitudeRad)
+
+	result := makeElevationMapWithSize(elevationMap.MinX, elevationMap.MinY, elevationMap.NumRows, elevationMap.NumCols, elevationMap.CellSize)
+	result.CRS = elevationMap.CRS
+
+	for row := 0; row < elevationMap.NumRows; row++ {
+		for col := 0; col < elevationMap.NumCols; col++ {
+			center := elevationMap.GetRowCol(row, col, false)
+			if center == NodataValue {
+				continue
+			}
+			z := func(dRow, dCol int) float64 {
+				value := elevationMap.GetRowCol(row+dRow, col+dCol, false)
+				if value == NodataValue {
+					value = center
+				}
+				return value * zFactor
+			}
+			// Row 0 is the northern row, so north is at dRow -1.
+			dzdx := (z(-1, 1) + 2*z(0, 1) + z(1, 1) - z(-1, -1) - 2*z(0, -1) - z(1, -1)) / (8 * elevationMap.CellSize)
+			dzdy := (z(-1, -1) + 2*z(-1, 0) + z(-1, 1) - z(1, -1) - 2*z(1, 0) - z(1, 1)) / (8 * elevationMap.CellSize)
+
+			shade := (-dzdx*lightX - dzdy*lightY + lightZ) / math.Sqrt(dzdx*dzdx+dzdy*dzdy+1)
+			result.SetRowCol(row, col, math.Max(0, shade))
+		}
+	}
+	result.UpdateElevationRange()
+
+	return result
+}
+
+// shadeColor darkens a colour by a hillshade illumination in the [0, 1]
+// range.
+func shadeColor(c color.RGBA, shade float64) color.RGBA {
+	k := 1 - hillshadeBlendStrength*(1-shade)
+	return color.RGBA{R: uint8(float64(c.R) * k), G: uint8(float64(c.G) * k), B: uint8(float64(c.B) * k), A: c.A}
+}
@@ -0,0 +1,126 @@
+package asctools
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+//go:embed htmlviewer.html
+var htmlViewerTemplate []byte
+
+// htmlViewerDataMarker is replaced by the map data in the template.
+var htmlViewerDataMarker = []byte("/*MAP_DATA*/null")
+
+type HTMLViewerOptions struct {
+	Title string
+	// MaxSize is the number of cells on the longer side of the embedded
+	// grid. Larger maps are downscaled by averaging.
+	MaxSize int
+	// Exaggeration is the initial vertical exaggeration, which the viewer
+	// can change between 0.1 and 100.
+	Exaggeration float64
+	Ramp         ColorRamp
+	Hillshade    bool
+}
+
+func DefaultHTMLViewerOptions() HTMLViewerOptions {
+	return HTMLViewerOptions{
+		MaxSize:      512,
+		Exaggeration: 1,
+		Ramp:         RampTerrain,
+		Hillshade:    true,
+	}
+}
+
+// htmlViewerData is the map as the viewer script reads it. Heights are
+// little endian float32 values in file order, NaN for nodata, and ramps are
+// 256 RGB entries each.
+type htmlViewerData struct {
+	Title        string            `json:"title"`
+	NumRows      int               `json:"numRows"`
+	NumCols      int               `json:"numCols"`
+	CellSize     float64           `json:"cellSize"`
+	MinElevation float64           `json:"minElevation"`
+	MaxElevation float64           `json:"maxElevation"`
+	Exaggeration float64           `json:"exaggeration"`
+	Ramp         string            `json:"ramp"`
+	Hillshade    bool              `json:"hillshade"`
+	Heights      string            `json:"heights"`
+	Ramps        map[string]string `json:"ramps"`
+}
+
+// WriteHTMLViewer writes a single HTML page that renders the map in 3D with
+// WebGL, without loading anything from the network.
+func (elevationMap *ElevationMap) WriteHTMLViewer(writer *bufio.Writer, options HTMLViewerOptions) error {
+	if options.MaxSize < 2 {
+		return fmt.Errorf("maximum grid size must be greater than or equal to 2")
+	}
+	if options.Exaggeration < 0.1 || options.Exaggeration > 100 {
+		return fmt.Errorf("exaggeration must be in range [0.1, 100]")
+	}
+
+	grid := elevationMap
+	longerSide := max(elevationMap.NumRows, elevationMap.NumCols)
+	if longerSide > options.MaxSize {
+		downscaleOptions := DefaultDownscaleOptions()
+		downscaleOptions.Factor = float64(longerSide) / float64(options.MaxSize)
+		downscaleOptions.Edge = EdgePad
+		var err error
+		grid, err = elevationMap.Downscale(downscaleOptions)
+		if err != nil {
+			return err
+		}
+	}
+	if grid.MinElevation > grid.MaxElevation {
+		return fmt.Errorf("map has no data")
+	}
+
+	heights := make([]byte, 4*len(grid.Data))
+	for i, value := range grid.Data {
+		bits := math.Float32bits(value)
+		if value == NodataValue {
+			bits = math.Float32bits(float32(math.NaN()))
+		}
+		binary.LittleEndian.PutUint32(heights[4*i:], bits)
+	}
+
+	ramps := map[string]string{}
+	for ramp := RampGray; ramp <= RampViridis; ramp++ {
+		lut := make([]byte, 0, 3*256)
+		for i := 0; i < 256; i++ {
+			c := ramp.Color(float64(i) / 255)
+			lut = append(lut, c.R, c.G, c.B)
+		}
+		ramps[ramp.String()] = base64.StdEncoding.EncodeToString(lut)
+	}
+
+	// json.Marshal escapes <, > and &, so the data cannot close the script
+	// element it is embedded in.
+	data, err := json.Marshal(htmlViewerData{
+		Title:        options.Title,
+		NumRows:      grid.NumRows,
+		NumCols:      grid.NumCols,
+		CellSize:     grid.CellSize,
+		MinElevation: grid.MinElevation,
+		MaxElevation: grid.MaxElevation,
+		Exaggeration: options.Exaggeration,
+		Ramp:         options.Ramp.String(),
+		Hillshade:    options.Hillshade,
+		Heights:      base64.StdEncoding.EncodeToString(heights),
+		Ramps:        ramps,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(bytes.Replace(htmlViewerTemplate, htmlViewerDataMarker, data, 1)); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
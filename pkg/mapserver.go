@@ -0,0 +1,400 @@
+package asctools
+
+import (
+	"bytes"
+	"container/list"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed mapserver.html
+var mapViewerHTML []byte
+
+const maxProfileSamples = 10000
+
+type MapServerOptions struct {
+	// Projection of the map coordinates. Tiles are served on the Web
+	// Mercator grid and points can be queried by longitude and latitude when
+	// it is set, and tiles use a local grid in map units otherwise.
+	Projection Projection
+	TileSize   int
+	// CacheSize is the number of rendered tiles kept in memory.
+	CacheSize int
+}
+
+func DefaultMapServerOptions() MapServerOptions {
+	return MapServerOptions{
+		TileSize:  256,
+		CacheSize: 1024,
+	}
+}
+
+// MapServer serves rendered tiles of a map, point and profile queries, map
+// statistics and a viewer over HTTP.
+type MapServer struct {
+	elevationMap *ElevationMap
+	options      MapServerOptions
+	renderer     *TileRenderer
+	cache        *tileCache
+	stats        MapStats
+	mux          *http.ServeMux
+}
+
+type MapStats struct {
+	NumRows      int     `json:"numRows"`
+	NumCols      int     `json:"numCols"`
+	CellSize     float64 `json:"cellSize"`
+	MinX         float64 `json:"minX"`
+	MinY         float64 `json:"minY"`
+	MaxX         float64 `json:"maxX"`
+	MaxY         float64 `json:"maxY"`
+	MinElevation float64 `json:"minElevation"`
+	MaxElevation float64 `json:"maxElevation"`
+	Mean         float64 `json:"mean"`
+	StdDev       float64 `json:"stdDev"`
+	ValidCells   int     `json:"validCells"`
+	NodataCells  int     `json:"nodataCells"`
+}
+
+// Stats returns the extent of the map and statistics of its elevations.
+func (elevationMap *ElevationMap) Stats() MapStats {
+	stats := MapStats{
+		NumRows:      elevationMap.NumRows,
+		NumCols:      elevationMap.NumCols,
+		CellSize:     elevationMap.CellSize,
+		MinX:         elevationMap.MinX,
+		MinY:         elevationMap.MinY,
+		MaxX:         elevationMap.MaxX,
+		MaxY:         elevationMap.MaxY,
+		MinElevation: elevationMap.MinElevation,
+		MaxElevation: elevationMap.MaxElevation,
+	}
+	sum, sumSquares := 0.0, 0.0
+	for _, value := range elevationMap.Data {
+		if value == NodataValue {
+			stats.NodataCells++
+			continue
+		}
+		stats.ValidCells++
+		sum += float64(value)
+		sumSquares += float64(value) * float64(value)
+	}
+	if stats.ValidCells > 0 {
+		n := float64(stats.ValidCells)
+		stats.Mean = sum / n
+		stats.StdDev = math.Sqrt(math.Max(0, sumSquares/n-stats.Mean*stats.Mean))
+	}
+	return stats
+}
+
+func NewMapServer(elevationMap *ElevationMap, options MapServerOptions) (*MapServer, error) {
+	if options.CacheSize < 0 {
+		return nil, fmt.Errorf("cache size must not be negative")
+	}
+	tileOptions := DefaultTilePyramidOptions()
+	tileOptions.TileSize = options.TileSize
+	tileOptions.Projection = options.Projection
+	renderer, err := NewTileRenderer(elevationMap, tileOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &MapServer{
+		elevationMap: elevationMap,
+		options:      options,
+		renderer:     renderer,
+		cache:        newTileCache(options.CacheSize),
+		stats:        elevationMap.Stats(),
+		mux:          http.NewServeMux(),
+	}
+	server.mux.HandleFunc("GET /{$}", server.handleViewer)
+	server.mux.HandleFunc("GET /info", server.handleInfo)
+	server.mux.HandleFunc("GET /stats", server.handleStats)
+	server.mux.HandleFunc("GET /tiles/{z}/{x}/{y}", server.handleTile)
+	server.mux.HandleFunc("GET /elevation", server.handleElevation)
+	server.mux.HandleFunc("GET /profile", server.handleProfile)
+	return server, nil
+}
+
+func (server *MapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+func (server *MapServer) handleViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(mapViewerHTML)
+}
+
+// mapInfo tells the viewer how tiles are laid out, in tile grid coordinates.
+type mapInfo struct {
+	WebMercator      bool       `json:"webMercator"`
+	TileSize         int        `json:"tileSize"`
+	MinZoom          int        `json:"minZoom"`
+	MaxZoom          int        `json:"maxZoom"`
+	Origin           [2]float64 `json:"origin"`
+	Resolution       float64    `json:"resolution"`
+	Bounds           [4]float64 `json:"bounds"`
+	GeographicBounds [4]float64 `json:"geographicBounds"`
+}
+
+func (server *MapServer) handleInfo(w http.ResponseWriter, r *http.Request) {
+	originX, originY := server.renderer.origin()
+	writeJSON(w, mapInfo{
+		WebMercator:      server.options.Projection != nil,
+		TileSize:         server.options.TileSize,
+		MinZoom:          server.renderer.MinZoom(),
+		MaxZoom:          server.renderer.MaxZoom(),
+		Origin:           [2]float64{originX, originY},
+		Resolution:       server.renderer.resolution(0),
+		Bounds:           server.renderer.bounds,
+		GeographicBounds: server.renderer.geographicBounds,
+	})
+}
+
+func (server *MapServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, server.stats)
+}
+
+// handleTile serves /tiles/{z}/{x}/{y}.png with rows numbered from the
+// north. The ramp, hillshade and encoding query parameters select the style.
+func (server *MapServer) handleTile(w http.ResponseWriter, r *http.Request) {
+	z, errZ := strconv.Atoi(r.PathValue("z"))
+	x, errX := strconv.Atoi(r.PathValue("x"))
+	y, errY := strconv.Atoi(strings.TrimSuffix(r.PathValue("y"), ".png"))
+	if errZ != nil || errX != nil || errY != nil {
+		http.Error(w, "invalid tile coordinates", http.StatusBadRequest)
+		return
+	}
+	style, err := parseTileStyle(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if z < server.renderer.MinZoom() || z > server.renderer.MaxZoom() {
+		http.NotFound(w, r)
+		return
+	}
+	minX, minY, maxX, maxY := server.renderer.TileRange(z)
+	if x < minX || x > maxX || y < minY || y > maxY {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := fmt.Sprintf("%d/%d/%d/%d/%d/%t", z, x, y, style.Ramp, style.Encoding, style.Hillshade)
+	data, cached := server.cache.get(key)
+	if !cached {
+		img, hasData := server.renderer.RenderStyle(z, x, y, style)
+		if hasData {
+			var buffer bytes.Buffer
+			if err := png.Encode(&buffer, img); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data = buffer.Bytes()
+		}
+		server.cache.put(key, data)
+	}
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+func parseTileStyle(r *http.Request) (TileStyle, error) {
+	query := r.URL.Query()
+	style := TileStyle{Ramp: RampTerrain}
+	var err error
+	if value := query.Get("ramp"); value != "" {
+		if style.Ramp, err = ParseColorRamp(value); err != nil {
+			return style, err
+		}
+	}
+	if style.Encoding, err = ParseElevationEncoding(query.Get("encoding")); err != nil {
+		return style, err
+	}
+	if value := query.Get("hillshade"); value != "" {
+		if style.Hillshade, err = strconv.ParseBool(value); err != nil {
+			return style, fmt.Errorf("invalid hillshade value: %s", value)
+		}
+	}
+	return style, nil
+}
+
+type pointElevation struct {
+	X         float64  `json:"x"`
+	Y         float64  `json:"y"`
+	Lon       *float64 `json:"lon,omitempty"`
+	Lat       *float64 `json:"lat,omitempty"`
+	Elevation *float64 `json:"elevation"`
+}
+
+// point returns the elevation at map coordinates, with null elevation for
+// nodata.
+func (server *MapServer) point(x, y float64) pointElevation {
+	point := pointElevation{X: x, Y: y}
+	if server.options.Projection != nil {
+		lon, lat := server.options.Projection.ToGeographic(x, y)
+		point.Lon, point.Lat = &lon, &lat
+	}
+	if elevation := server.elevationMap.Sample(x, y, ResampleBilinear); elevation != NodataValue {
+		point.Elevation = &elevation
+	}
+	return point
+}
+
+// queryPoint reads a point given in map coordinates as x and y, or in
+// degrees as lon and lat, with names ending in suffix.
+func (server *MapServer) queryPoint(r *http.Request, suffix string) (float64, float64, error) {
+	query := r.URL.Query()
+	names := [2]string{"x" + suffix, "y" + suffix}
+	geographic := query.Has("lon"+suffix) || query.Has("lat"+suffix)
+	if geographic {
+		if server.options.Projection == nil {
+			return 0, 0, fmt.Errorf("map has no CRS, query with x and y")
+		}
+		names = [2]string{"lon" + suffix, "lat" + suffix}
+	}
+
+	var values [2]float64
+	for i, name := range names {
+		value, err := strconv.ParseFloat(query.Get(name), 64)
+		// ParseFloat accepts NaN and Inf, which JSON cannot represent.
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return 0, 0, fmt.Errorf("invalid or missing %s", name)
+		}
+		values[i] = value
+	}
+	if geographic {
+		x, y := server.options.Projection.FromGeographic(values[0], values[1])
+		if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+			return 0, 0, fmt.Errorf("%s and %s are outside the projection", names[0], names[1])
+		}
+		return x, y, nil
+	}
+	return values[0], values[1], nil
+}
+
+// handleElevation serves /elevation?x=&y= or /elevation?lon=&lat=.
+func (server *MapServer) handleElevation(w http.ResponseWriter, r *http.Request) {
+	x, y, err := server.queryPoint(r, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, server.point(x, y))
+}
+
+type profilePoint struct {
+	Distance float64 `json:"distance"`
+	pointElevation
+}
+
+type elevationProfile struct {
+	Length float64        `json:"length"`
+	Points []profilePoint `json:"points"`
+}
+
+// handleProfile serves /profile?x1=&y1=&x2=&y2=&samples=, or with lon1,
+// lat1, lon2 and lat2. Distances are in map units.
+func (server *MapServer) handleProfile(w http.ResponseWriter, r *http.Request) {
+	x1, y1, err := server.queryPoint(r, "1")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	x2, y2, err := server.queryPoint(r, "2")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	samples := 100
+	if value := r.URL.Query().Get("samples"); value != "" {
+		samples, err = strconv.Atoi(value)
+		if err != nil || samples < 2 || samples > maxProfileSamples {
+			http.Error(w, fmt.Sprintf("samples must be between 2 and %d", maxProfileSamples), http.StatusBadRequest)
+			return
+		}
+	}
+	if math.IsInf(math.Hypot(x2-x1, y2-y1), 0) {
+		http.Error(w, "profile is too long", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, server.profile(x1, y1, x2, y2, samples))
+}
+
+func (server *MapServer) profile(x1, y1, x2, y2 float64, samples int) elevationProfile {
+	profile := elevationProfile{Length: math.Hypot(x2-x1, y2-y1)}
+	for i := 0; i < samples; i++ {
+		f := float64(i) / float64(samples-1)
+		point := server.point(x1+f*(x2-x1), y1+f*(y2-y1))
+		profile.Points = append(profile.Points, profilePoint{Distance: f * profile.Length, pointElevation: point})
+	}
+	return profile
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// tileCache keeps the most recently used tiles. Tiles without data are
+// cached as nil.
+type tileCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type tileCacheEntry struct {
+	key  string
+	data []byte
+}
+
+func newTileCache(capacity int) *tileCache {
+	return &tileCache{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (cache *tileCache) get(key string) ([]byte, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*tileCacheEntry).data, true
+}
+
+func (cache *tileCache) put(key string, data []byte) {
+	if cache.capacity == 0 {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		element.Value.(*tileCacheEntry).data = data
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&tileCacheEntry{key: key, data: data})
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*tileCacheEntry).key)
+	}
+}
@@ -0,0 +1,192 @@
+package asctools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestMapServer serves a 4 x 4 map of unit cells from 0,0 whose cells
+// hold 10*row+col with row 0 in the north, and a nodata cell at row 1,
+// column 2.
+func newTestMapServer(t *testing.T, options MapServerOptions) *MapServer {
+	t.Helper()
+	elevationMap := makeElevationMapWithSize(0, 0, 4, 4, 1)
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			elevationMap.SetRowCol(row, col, float64(10*row+col))
+		}
+	}
+	elevationMap.SetRowCol(1, 2, NodataValue)
+	elevationMap.UpdateElevationRange()
+
+	server, err := NewMapServer(elevationMap, options)
+	if err != nil {
+		t.Fatalf("NewMapServer: %v", err)
+	}
+	return server
+}
+
+func get(t *testing.T, server *MapServer, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, value any) {
+	t.Helper()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("content type %q", contentType)
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+		t.Fatalf("decoding %s: %v", recorder.Body.String(), err)
+	}
+}
+
+func TestMapServerElevation(t *testing.T) {
+	server := newTestMapServer(t, DefaultMapServerOptions())
+
+	tests := []struct {
+		url       string
+		elevation *float64
+	}{
+		{"/elevation?x=0.5&y=3.5", ptr(0.0)},
+		{"/elevation?x=3.5&y=0.5", ptr(33.0)},
+		{"/elevation?x=1.5&y=2.5", ptr(11.0)},
+		{"/elevation?x=2.5&y=2.5", nil},
+		{"/elevation?x=10&y=10", nil},
+	}
+	for _, test := range tests {
+		var point pointElevation
+		decode(t, get(t, server, test.url), &point)
+		switch {
+		case test.elevation == nil && point.Elevation != nil:
+			t.Errorf("%s: elevation %v, want null", test.url, *point.Elevation)
+		case test.elevation != nil && point.Elevation == nil:
+			t.Errorf("%s: elevation null, want %v", test.url, *test.elevation)
+		case test.elevation != nil && *point.Elevation != *test.elevation:
+			t.Errorf("%s: elevation %v, want %v", test.url, *point.Elevation, *test.elevation)
+		}
+	}
+}
+
+func TestMapServerRejectsInvalidPoints(t *testing.T) {
+	server := newTestMapServer(t, DefaultMapServerOptions())
+
+	for _, url := range []string{
+		"/elevation",
+		"/elevation?x=1",
+		"/elevation?x=abc&y=1",
+		"/elevation?x=NaN&y=NaN",
+		"/elevation?x=Inf&y=1",
+		"/elevation?x=1&y=-Inf",
+		"/elevation?lon=1&lat=1",
+		"/profile?x1=0&y1=0&x2=NaN&y2=1",
+		"/profile?x1=0&y1=0&x2=1",
+		"/profile?x1=-1e308&y1=0&x2=1e308&y2=0",
+		"/profile?x1=0&y1=0&x2=1&y2=1&samples=1",
+		"/profile?x1=0&y1=0&x2=1&y2=1&samples=abc",
+	} {
+		if recorder := get(t, server, url); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", url, recorder.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestMapServerProfile(t *testing.T) {
+	server := newTestMapServer(t, DefaultMapServerOptions())
+
+	var profile elevationProfile
+	decode(t, get(t, server, "/profile?x1=0.5&y1=3.5&x2=3.5&y2=3.5&samples=4"), &profile)
+	if profile.Length != 3 {
+		t.Errorf("length %v, want 3", profile.Length)
+	}
+	want := []float64{0, 1, 2, 3}
+	if len(profile.Points) != len(want) {
+		t.Fatalf("%d points, want %d", len(profile.Points), len(want))
+	}
+	for i, point := range profile.Points {
+		if point.Distance != float64(i) {
+			t.Errorf("point %d: distance %v, want %d", i, point.Distance, i)
+		}
+		if point.Elevation == nil || *point.Elevation != want[i] {
+			t.Errorf("point %d: elevation %v, want %v", i, point.Elevation, want[i])
+		}
+	}
+}
+
+func TestMapServerStats(t *testing.T) {
+	server := newTestMapServer(t, DefaultMapServerOptions())
+
+	var stats MapStats
+	decode(t, get(t, server, "/stats"), &stats)
+	if stats.NumRows != 4 || stats.NumCols != 4 || stats.CellSize != 1 {
+		t.Errorf("size %d x %d at %v, want 4 x 4 at 1", stats.NumRows, stats.NumCols, stats.CellSize)
+	}
+	if stats.ValidCells != 15 || stats.NodataCells != 1 {
+		t.Errorf("%d valid and %d nodata cells, want 15 and 1", stats.ValidCells, stats.NodataCells)
+	}
+	if stats.MinElevation != 0 || stats.MaxElevation != 33 {
+		t.Errorf("elevations %v to %v, want 0 to 33", stats.MinElevation, stats.MaxElevation)
+	}
+	// The sum of 10*row+col over all cells is 264, less 12 for the nodata cell.
+	if stats.Mean != 252.0/15 {
+		t.Errorf("mean %v, want %v", stats.Mean, 252.0/15)
+	}
+}
+
+func TestMapServerTileCache(t *testing.T) {
+	options := DefaultMapServerOptions()
+	options.CacheSize = 2
+	server := newTestMapServer(t, options)
+
+	z := server.renderer.MaxZoom()
+	minX, minY, _, _ := server.renderer.TileRange(z)
+	url := fmt.Sprintf("/tiles/%d/%d/%d.png", z, minX, minY)
+
+	first := get(t, server, url)
+	if first.Code != http.StatusOK || first.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("status %d, content type %q", first.Code, first.Header().Get("Content-Type"))
+	}
+	key := fmt.Sprintf("%d/%d/%d/%d/%d/%t", z, minX, minY, RampTerrain, EncodingNone, false)
+	if _, ok := server.cache.get(key); !ok {
+		t.Fatalf("tile %s not cached", key)
+	}
+	second := get(t, server, url)
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("cached tile differs from the rendered one")
+	}
+
+	// Styles are cached separately, and the least recently used tile goes
+	// first.
+	get(t, server, url+"?ramp=gray")
+	get(t, server, url+"?hillshade=true")
+	if _, ok := server.cache.get(key); ok {
+		t.Errorf("least recently used tile still cached")
+	}
+	if len(server.cache.entries) != 2 {
+		t.Errorf("%d cached tiles, want 2", len(server.cache.entries))
+	}
+
+	for _, url := range []string{
+		fmt.Sprintf("/tiles/%d/%d/%d.png", z+1, 0, 0),
+		fmt.Sprintf("/tiles/%d/%d/%d.png", z, minX-1, minY),
+	} {
+		if recorder := get(t, server, url); recorder.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want %d", url, recorder.Code, http.StatusNotFound)
+		}
+	}
+	if recorder := get(t, server, url+"?ramp=nope"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("unknown ramp: status %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func ptr(value float64) *float64 {
+	return &value
+}
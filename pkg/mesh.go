@@ -0,0 +1,284 @@
+package asctools
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Mesh is an indexed triangle mesh. Faces are counter-clockwise when seen
+// from outside the solid.
+type Mesh struct {
+	Vertices []Vector3
+	Faces    [][3]int
+	// TexCoords holds a texture coordinate per vertex, with the origin in the
+	// lower left corner of the texture. It may be empty.
+	TexCoords [][2]float32
+}
+
+type OriginMode int
+
+const (
+	// OriginCorner places the first grid point at the model origin.
+	OriginCorner OriginMode = iota
+	// OriginCenter places the middle of the model at the origin.
+	OriginCenter
+	// OriginWorld places a given map coordinate at the origin, so that models
+	// exported separately with the same origin line up.
+	OriginWorld
+)
+
+type MeshOrigin struct {
+	Mode OriginMode
+	X, Y float64
+}
+
+type MeshOptions struct {
+	FloorElevation float64
+	// Simplify replaces the regular grid with an error bounded TIN when any
+	// of its limits is set.
+	Simplify TINOptions
+	// Scale converts map units to model units. It is ignored when PrintWidth
+	// is set.
+	Scale float64
+	// PrintWidth is the width of the model along X in model units, usually
+	// millimetres.
+	PrintWidth           float64
+	VerticalExaggeration float64
+	// BaseThickness is the minimum thickness of the model below the lowest
+	// point, in model units. It lowers the floor when needed.
+	BaseThickness float64
+	Origin        MeshOrigin
+	Mode          SurfaceMode
+	// MinThickness and MaxThickness bound lithophanes and moulds, in model
+	// units. 0 picks a default for the mode; they do not apply to reliefs.
+	MinThickness float64
+	MaxThickness float64
+	// Plinth, when enabled, raises the model onto a wider block carrying
+	// text, a scale bar and a north arrow.
+	Plinth PlinthOptions
+	// Reference is the map PrintWidth and BaseThickness refer to instead of
+	// the map itself, usually the whole map of which this is a tile, so that
+	// tiles exported separately share a scale and a floor.
+	Reference *ElevationMap
+}
+
+func DefaultMeshOptions() MeshOptions {
+	return MeshOptions{
+		Scale:                1,
+		VerticalExaggeration: 1,
+		Plinth:               DefaultPlinthOptions(),
+	}
+}
+
+func ParseMeshOrigin(value string) (MeshOrigin, error) {
+	switch value {
+	case "corner", "":
+		return MeshOrigin{Mode: OriginCorner}, nil
+	case "center":
+		return MeshOrigin{Mode: OriginCenter}, nil
+	}
+
+	xVal, yVal, ok := strings.Cut(value, ",")
+	if !ok {
+		return MeshOrigin{}, fmt.Errorf("unknown origin: %s (expected 'corner', 'center' or X,Y)", value)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(xVal), 64)
+	if err != nil {
+		return MeshOrigin{}, fmt.Errorf("invalid origin X: %v", err)
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(yVal), 64)
+	if err != nil {
+		return MeshOrigin{}, fmt.Errorf("invalid origin Y: %v", err)
+	}
+	return MeshOrigin{Mode: OriginWorld, X: x, Y: y}, nil
+}
+
+// modelFrame maps grid model coordinates, where X and Y are in map units
+// from the first grid point and Z is in elevation units above the floor, to
+// output coordinates.
+type modelFrame struct {
+	floorElevation   float64
+	scale, zScale    float64
+	offsetX, offsetY float64
+}
+
+func (frame modelFrame) transform(v Vector3) Vector3 {
+	return Vector3{
+		X: float32((float64(v.X) + frame.offsetX) * frame.scale),
+		Y: float32((float64(v.Y) + frame.offsetY) * frame.scale),
+		Z: float32(float64(v.Z) * frame.zScale),
+	}
+}
+
+func (elevationMap *ElevationMap) modelFrame(options MeshOptions) (modelFrame, error) {
+	if options.Scale <= 0 && options.PrintWidth <= 0 {
+		return modelFrame{}, fmt.Errorf("scale must be greater than 0")
+	}
+	if options.PrintWidth < 0 {
+		return modelFrame{}, fmt.Errorf("print width must not be negative")
+	}
+	if options.VerticalExaggeration <= 0 {
+		return modelFrame{}, fmt.Errorf("vertical exaggeration must be greater than 0")
+	}
+	if options.BaseThickness < 0 {
+		return modelFrame{}, fmt.Errorf("base thickness must not be negative")
+	}
+
+	width := float64(elevationMap.NumCols-1) * elevationMap.CellSize
+	height := float64(elevationMap.NumRows-1) * elevationMap.CellSize
+	reference := elevationMap
+	if options.Reference != nil {
+		reference = options.Reference
+	}
+
+	frame := modelFrame{scale: options.Scale}
+	if options.PrintWidth > 0 {
+		referenceWidth := float64(reference.NumCols-1) * reference.CellSize
+		if referenceWidth <= 0 {
+			return modelFrame{}, fmt.Errorf("map is too narrow to scale to a print width")
+		}
+		frame.scale = options.PrintWidth / referenceWidth
+	}
+	frame.zScale = frame.scale * options.VerticalExaggeration
+
+	frame.floorElevation = options.FloorElevation
+	if options.BaseThickness > 0 {
+		frame.floorElevation = math.Min(frame.floorElevation, reference.MinElevation-options.BaseThickness/frame.zScale)
+	}
+
+	switch options.Origin.Mode {
+	case OriginCenter:
+		frame.offsetX, frame.offsetY = -width/2, -height/2
+	case OriginWorld:
+		frame.offsetX = elevationMap.MinX + elevationMap.CellSize/2 - options.Origin.X
+		frame.offsetY = elevationMap.MinY + elevationMap.CellSize/2 - options.Origin.Y
+	}
+
+	return frame, nil
+}
+
+// BuildMesh builds the closed model of the map, either a regular grid or a
+// simplified TIN, in output coordinates. Lithophanes and moulds are built the
+// same way from a derived height field.
+func (elevationMap *ElevationMap) BuildMesh(options MeshOptions) (*Mesh, error) {
+	frame, err := elevationMap.modelFrame(options)
+	if err != nil {
+		return nil, err
+	}
+
+	surface := elevationMap
+	if options.Mode != ModeRelief {
+		if options.Plinth.Enabled() {
+			return nil, fmt.Errorf("plinth is only supported for reliefs")
+		}
+		surface, frame, err = elevationMap.surfaceMap(frame, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var mesh *Mesh
+	if options.Simplify.Enabled() {
+		mesh, err = surface.BuildTIN(frame.floorElevation, options.Simplify)
+	} else {
+		mesh, err = surface.BuildGridMesh(frame.floorElevation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	surface.setTexCoords(mesh)
+	mesh.Transform(frame.transform)
+
+	if options.Plinth.Enabled() {
+		plinth, err := elevationMap.buildPlinth(frame, options.Plinth)
+		if err != nil {
+			return nil, err
+		}
+		mesh.Transform(func(v Vector3) Vector3 {
+			return Vector3{v.X, v.Y, v.Z + float32(options.Plinth.Thickness)}
+		})
+		mesh.Append(plinth)
+	}
+
+	return mesh, nil
+}
+
+// setTexCoords maps vertices in grid model coordinates onto the image
+// rendered by RenderImage, with the origin in the lower left corner.
+func (elevationMap *ElevationMap) setTexCoords(mesh *Mesh) {
+	width := float64(elevationMap.NumCols) * elevationMap.CellSize
+	height := float64(elevationMap.NumRows) * elevationMap.CellSize
+	mesh.TexCoords = make([][2]float32, len(mesh.Vertices))
+	for i, v := range mesh.Vertices {
+		mesh.TexCoords[i] = [2]float32{
+			float32((float64(v.X) + elevationMap.CellSize/2) / width),
+			float32((float64(v.Y) + elevationMap.CellSize/2) / height),
+		}
+	}
+}
+
+func (mesh *Mesh) AddVertex(v Vector3) int {
+	mesh.Vertices = append(mesh.Vertices, v)
+	return len(mesh.Vertices) - 1
+}
+
+func (mesh *Mesh) AddFace(a, b, c int) {
+	mesh.Faces = append(mesh.Faces, [3]int{a, b, c})
+}
+
+// Append adds the vertices and faces of another mesh as a separate shell.
+// Vertices without texture coordinates map to the texture origin.
+func (mesh *Mesh) Append(other *Mesh) {
+	offset := len(mesh.Vertices)
+	mesh.Vertices = append(mesh.Vertices, other.Vertices...)
+	for _, face := range other.Faces {
+		mesh.AddFace(face[0]+offset, face[1]+offset, face[2]+offset)
+	}
+	if len(mesh.TexCoords) == offset && offset > 0 {
+		for i := range other.Vertices {
+			if i < len(other.TexCoords) {
+				mesh.TexCoords = append(mesh.TexCoords, other.TexCoords[i])
+			} else {
+				mesh.TexCoords = append(mesh.TexCoords, [2]float32{})
+			}
+		}
+	}
+}
+
+func (mesh *Mesh) Transform(transform func(Vector3) Vector3) {
+	for i, v := range mesh.Vertices {
+		mesh.Vertices[i] = transform(v)
+	}
+}
+
+func (mesh *Mesh) Triangle(face int) Triangle {
+	f := mesh.Faces[face]
+	return makeTriangle(mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]])
+}
+
+func (mesh *Mesh) WriteSTL(writer *bufio.Writer) error {
+	header := make([]byte, 80)
+	copy(header, []byte("STL binary file generated by asctools"))
+
+	numTriangles := len(mesh.Faces)
+
+	if _, err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	writer.Write([]byte{
+		byte(numTriangles),
+		byte(numTriangles >> 8),
+		byte(numTriangles >> 16),
+		byte(numTriangles >> 24),
+	})
+
+	for i := range mesh.Faces {
+		writeSTLTriangle(writer, mesh.Triangle(i))
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,103 @@
+package asctools
+
+type MeshReport struct {
+	Triangles           int
+	Vertices            int
+	DegenerateTriangles int
+	// OpenEdges are used by a single triangle.
+	OpenEdges int
+	// NonManifoldEdges are shared by more than two triangles.
+	NonManifoldEdges int
+	// InconsistentEdges are traversed in the same direction by both of their
+	// triangles, so one of the triangles is flipped.
+	InconsistentEdges int
+	// InvertedNormals counts triangles whose stored normal points against
+	// their winding.
+	InvertedNormals int
+	// Volume is negative when a closed mesh is inside out.
+	Volume float64
+}
+
+func (report MeshReport) Watertight() bool {
+	return report.OpenEdges == 0 && report.NonManifoldEdges == 0 && report.InconsistentEdges == 0
+}
+
+// MeshFromTriangles builds an indexed mesh, merging vertices with identical
+// coordinates.
+func MeshFromTriangles(triangles []Triangle) *Mesh {
+	mesh := &Mesh{}
+	indices := map[Vector3]int{}
+	vertex := func(v Vector3) int {
+		if index, ok := indices[v]; ok {
+			return index
+		}
+		index := mesh.AddVertex(v)
+		indices[v] = index
+		return index
+	}
+	for _, t := range triangles {
+		mesh.AddFace(vertex(t.Vertex1), vertex(t.Vertex2), vertex(t.Vertex3))
+	}
+	return mesh
+}
+
+func CheckTriangles(triangles []Triangle) MeshReport {
+	mesh := MeshFromTriangles(triangles)
+	report := MeshReport{
+		Triangles: len(mesh.Faces),
+		Vertices:  len(mesh.Vertices),
+	}
+
+	type edgeUse struct {
+		count   int
+		forward int
+	}
+	edges := map[[2]int]*edgeUse{}
+
+	for i, f := range mesh.Faces {
+		a, b, c := mesh.Vertices[f[0]], mesh.Vertices[f[1]], mesh.Vertices[f[2]]
+		ux, uy, uz := float64(b.X-a.X), float64(b.Y-a.Y), float64(b.Z-a.Z)
+		vx, vy, vz := float64(c.X-a.X), float64(c.Y-a.Y), float64(c.Z-a.Z)
+		nx, ny, nz := uy*vz-uz*vy, uz*vx-ux*vz, ux*vy-uy*vx
+
+		if nx == 0 && ny == 0 && nz == 0 {
+			report.DegenerateTriangles++
+		}
+
+		stored := triangles[i].Normal
+		if float64(stored.X)*nx+float64(stored.Y)*ny+float64(stored.Z)*nz < 0 {
+			report.InvertedNormals++
+		}
+
+		report.Volume += (float64(a.X)*(float64(b.Y)*float64(c.Z)-float64(b.Z)*float64(c.Y)) -
+			float64(a.Y)*(float64(b.X)*float64(c.Z)-float64(b.Z)*float64(c.X)) +
+			float64(a.Z)*(float64(b.X)*float64(c.Y)-float64(b.Y)*float64(c.X))) / 6
+
+		for j := 0; j < 3; j++ {
+			from, to := f[j], f[(j+1)%3]
+			key := [2]int{min(from, to), max(from, to)}
+			use, ok := edges[key]
+			if !ok {
+				use = &edgeUse{}
+				edges[key] = use
+			}
+			use.count++
+			if from < to {
+				use.forward++
+			}
+		}
+	}
+
+	for _, use := range edges {
+		switch {
+		case use.count == 1:
+			report.OpenEdges++
+		case use.count > 2:
+			report.NonManifoldEdges++
+		case use.forward != 1:
+			report.InconsistentEdges++
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,562 @@
+package asctools
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type MeshFormat int
+
+const (
+	FormatSTL MeshFormat = iota
+	FormatASCIISTL
+	FormatOBJ
+	FormatPLY
+	Format3MF
+	FormatGLTF
+	FormatGLB
+)
+
+func ParseMeshFormat(value string) (MeshFormat, error) {
+	switch value {
+	case "stl", "":
+		return FormatSTL, nil
+	case "stl_ascii":
+		return FormatASCIISTL, nil
+	case "obj":
+		return FormatOBJ, nil
+	case "ply":
+		return FormatPLY, nil
+	case "3mf":
+		return Format3MF, nil
+	case "gltf":
+		return FormatGLTF, nil
+	case "glb":
+		return FormatGLB, nil
+	default:
+		return FormatSTL, fmt.Errorf("unknown mesh format: %s", value)
+	}
+}
+
+func MeshFormatFromPath(path string) (MeshFormat, error) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "" {
+		return FormatSTL, fmt.Errorf("cannot tell mesh format from %s", path)
+	}
+	return ParseMeshFormat(ext)
+}
+
+// WriteMeshFile writes the mesh to path. OBJ also gets a material library
+// and a texture next to it, named after path.
+func WriteMeshFile(path string, format MeshFormat, mesh *Mesh, texture image.Image) error {
+	if format != FormatOBJ || texture == nil {
+		return writeFile(path, func(writer *bufio.Writer) error {
+			return mesh.Write(writer, format, texture)
+		})
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	mtlPath := base + ".mtl"
+	texturePath := base + ".png"
+
+	err := writeFile(path, func(writer *bufio.Writer) error {
+		return mesh.WriteOBJ(writer, filepath.Base(mtlPath))
+	})
+	if err != nil {
+		return err
+	}
+	err = writeFile(mtlPath, func(writer *bufio.Writer) error {
+		return WriteMTL(writer, filepath.Base(texturePath))
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(texturePath, func(writer *bufio.Writer) error {
+		return png.Encode(writer, texture)
+	})
+}
+
+// Write writes formats that fit in a single file. OBJ is written without a
+// material library.
+func (mesh *Mesh) Write(writer *bufio.Writer, format MeshFormat, texture image.Image) error {
+	switch format {
+	case FormatASCIISTL:
+		return mesh.WriteASCIISTL(writer)
+	case FormatOBJ:
+		return mesh.WriteOBJ(writer, "")
+	case FormatPLY:
+		return mesh.WritePLY(writer, texture)
+	case Format3MF:
+		return mesh.Write3MF(writer, texture)
+	case FormatGLTF:
+		return mesh.WriteGLTF(writer, texture, false)
+	case FormatGLB:
+		return mesh.WriteGLTF(writer, texture, true)
+	}
+	return mesh.WriteSTL(writer)
+}
+
+func formatFloat32(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func (mesh *Mesh) WriteASCIISTL(writer *bufio.Writer) error {
+	fmt.Fprintln(writer, "solid asctools")
+	for i := range mesh.Faces {
+		t := mesh.Triangle(i)
+		fmt.Fprintf(writer, "  facet normal %s %s %s\n", formatFloat32(t.Normal.X), formatFloat32(t.Normal.Y), formatFloat32(t.Normal.Z))
+		fmt.Fprintln(writer, "    outer loop")
+		for _, v := range []Vector3{t.Vertex1, t.Vertex2, t.Vertex3} {
+			fmt.Fprintf(writer, "      vertex %s %s %s\n", formatFloat32(v.X), formatFloat32(v.Y), formatFloat32(v.Z))
+		}
+		fmt.Fprintln(writer, "    endloop")
+		fmt.Fprintln(writer, "  endfacet")
+	}
+	fmt.Fprintln(writer, "endsolid asctools")
+	return writer.Flush()
+}
+
+// WriteOBJ writes the mesh Y up, as most OBJ readers expect. Texture
+// coordinates and the material are only written when mtlName is set.
+func (mesh *Mesh) WriteOBJ(writer *bufio.Writer, mtlName string) error {
+	textured := mtlName != "" && len(mesh.TexCoords) == len(mesh.Vertices)
+
+	fmt.Fprintln(writer, "# generated by asctools")
+	if textured {
+		fmt.Fprintf(writer, "mtllib %s\n", mtlName)
+	}
+	fmt.Fprintln(writer, "o terrain")
+	for _, v := range mesh.Vertices {
+		fmt.Fprintf(writer, "v %s %s %s\n", formatFloat32(v.X), formatFloat32(v.Z), formatFloat32(-v.Y))
+	}
+	if textured {
+		for _, uv := range mesh.TexCoords {
+			fmt.Fprintf(writer, "vt %s %s\n", formatFloat32(uv[0]), formatFloat32(uv[1]))
+		}
+		fmt.Fprintln(writer, "usemtl terrain")
+	}
+	for _, f := range mesh.Faces {
+		if textured {
+			fmt.Fprintf(writer, "f %d/%d %d/%d %d/%d\n", f[0]+1, f[0]+1, f[1]+1, f[1]+1, f[2]+1, f[2]+1)
+		} else {
+			fmt.Fprintf(writer, "f %d %d %d\n", f[0]+1, f[1]+1, f[2]+1)
+		}
+	}
+	return writer.Flush()
+}
+
+func WriteMTL(writer *bufio.Writer, textureName string) error {
+	fmt.Fprintln(writer, "# generated by asctools")
+	fmt.Fprintln(writer, "newmtl terrain")
+	fmt.Fprintln(writer, "Ka 1 1 1")
+	fmt.Fprintln(writer, "Kd 1 1 1")
+	fmt.Fprintln(writer, "Ks 0 0 0")
+	fmt.Fprintln(writer, "d 1")
+	fmt.Fprintln(writer, "illum 1")
+	fmt.Fprintf(writer, "map_Kd %s\n", textureName)
+	return writer.Flush()
+}
+
+// VertexColors samples the texture at each vertex. Vertices without texture
+// coordinates are white.
+func (mesh *Mesh) VertexColors(texture image.Image) []color.RGBA {
+	colors := make([]color.RGBA, len(mesh.Vertices))
+	for i := range colors {
+		colors[i] = color.RGBA{255, 255, 255, 255}
+		if texture == nil || i >= len(mesh.TexCoords) {
+			continue
+		}
+		bounds := texture.Bounds()
+		uv := mesh.TexCoords[i]
+		x := bounds.Min.X + min(max(int(float64(uv[0])*float64(bounds.Dx())), 0), bounds.Dx()-1)
+		y := bounds.Min.Y + min(max(int((1-float64(uv[1]))*float64(bounds.Dy())), 0), bounds.Dy()-1)
+		r, g, b, _ := texture.At(x, y).RGBA()
+		colors[i] = color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255}
+	}
+	return colors
+}
+
+func (mesh *Mesh) WritePLY(writer *bufio.Writer, texture image.Image) error {
+	fmt.Fprintln(writer, "ply")
+	fmt.Fprintln(writer, "format binary_little_endian 1.0")
+	fmt.Fprintln(writer, "comment generated by asctools")
+	fmt.Fprintf(writer, "element vertex %d\n", len(mesh.Vertices))
+	fmt.Fprintln(writer, "property float x")
+	fmt.Fprintln(writer, "property float y")
+	fmt.Fprintln(writer, "property float z")
+	fmt.Fprintln(writer, "property uchar red")
+	fmt.Fprintln(writer, "property uchar green")
+	fmt.Fprintln(writer, "property uchar blue")
+	fmt.Fprintf(writer, "element face %d\n", len(mesh.Faces))
+	fmt.Fprintln(writer, "property list uchar int vertex_indices")
+	fmt.Fprintln(writer, "end_header")
+
+	colors := mesh.VertexColors(texture)
+	for i, v := range mesh.Vertices {
+		writeFloat32(writer, v.X)
+		writeFloat32(writer, v.Y)
+		writeFloat32(writer, v.Z)
+		writer.Write([]byte{colors[i].R, colors[i].G, colors[i].B})
+	}
+	buf := make([]byte, 13)
+	buf[0] = 3
+	for _, f := range mesh.Faces {
+		binary.LittleEndian.PutUint32(buf[1:], uint32(f[0]))
+		binary.LittleEndian.PutUint32(buf[5:], uint32(f[1]))
+		binary.LittleEndian.PutUint32(buf[9:], uint32(f[2]))
+		writer.Write(buf)
+	}
+	return writer.Flush()
+}
+
+// Write3MF writes a 3MF package in millimetres. The texture is attached
+// through the materials extension when both it and texture coordinates are
+// present.
+func (mesh *Mesh) Write3MF(writer *bufio.Writer, texture image.Image) error {
+	textured := texture != nil && len(mesh.TexCoords) == len(mesh.Vertices)
+
+	archive := zip.NewWriter(writer)
+	addFile := func(name string, write func(io.Writer) error) error {
+		file, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		return write(file)
+	}
+	addText := func(name, text string) error {
+		return addFile(name, func(w io.Writer) error {
+			_, err := io.WriteString(w, text)
+			return err
+		})
+	}
+
+	err := addText("[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+ <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+ <Default Extension="model" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodel+xml"/>
+ <Default Extension="png" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodeltexture"/>
+</Types>
+`)
+	if err != nil {
+		return err
+	}
+	err = addText("_rels/.rels", `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+ <Relationship Target="/3D/3dmodel.model" Id="rel0" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>
+`)
+	if err != nil {
+		return err
+	}
+
+	if textured {
+		err = addText("3D/_rels/3dmodel.model.rels", `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+ <Relationship Target="/3D/Textures/texture.png" Id="rel1" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dtexture"/>
+</Relationships>
+`)
+		if err != nil {
+			return err
+		}
+		err = addFile("3D/Textures/texture.png", func(w io.Writer) error {
+			return png.Encode(w, texture)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = addFile("3D/3dmodel.model", func(w io.Writer) error {
+		model := bufio.NewWriter(w)
+		fmt.Fprintln(model, `<?xml version="1.0" encoding="UTF-8"?>`)
+		fmt.Fprintln(model, `<model unit="millimeter" xml:lang="en-US" xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02" xmlns:m="http://schemas.microsoft.com/3dmanufacturing/material/2015/02">`)
+		fmt.Fprintln(model, ` <resources>`)
+		object := ` <object id="1" type="model">`
+		if textured {
+			fmt.Fprintln(model, `  <m:texture2d id="2" path="/3D/Textures/texture.png" contenttype="image/png" tilestyleu="clamp" tilestylev="clamp"/>`)
+			fmt.Fprintln(model, `  <m:texture2dgroup id="3" texid="2">`)
+			for _, uv := range mesh.TexCoords {
+				fmt.Fprintf(model, "   <m:tex2coord u=\"%s\" v=\"%s\"/>\n", formatFloat32(uv[0]), formatFloat32(uv[1]))
+			}
+			fmt.Fprintln(model, `  </m:texture2dgroup>`)
+			object = ` <object id="1" type="model" pid="3" pindex="0">`
+		}
+		fmt.Fprintln(model, " "+object)
+		fmt.Fprintln(model, `   <mesh>`)
+		fmt.Fprintln(model, `    <vertices>`)
+		for _, v := range mesh.Vertices {
+			fmt.Fprintf(model, "     <vertex x=\"%s\" y=\"%s\" z=\"%s\"/>\n", formatFloat32(v.X), formatFloat32(v.Y), formatFloat32(v.Z))
+		}
+		fmt.Fprintln(model, `    </vertices>`)
+		fmt.Fprintln(model, `    <triangles>`)
+		for _, f := range mesh.Faces {
+			if textured {
+				fmt.Fprintf(model, "     <triangle v1=\"%d\" v2=\"%d\" v3=\"%d\" pid=\"3\" p1=\"%d\" p2=\"%d\" p3=\"%d\"/>\n", f[0], f[1], f[2], f[0], f[1], f[2])
+			} else {
+				fmt.Fprintf(model, "     <triangle v1=\"%d\" v2=\"%d\" v3=\"%d\"/>\n", f[0], f[1], f[2])
+			}
+		}
+		fmt.Fprintln(model, `    </triangles>`)
+		fmt.Fprintln(model, `   </mesh>`)
+		fmt.Fprintln(model, `  </object>`)
+		fmt.Fprintln(model, ` </resources>`)
+		fmt.Fprintln(model, ` <build>`)
+		fmt.Fprintln(model, `  <item objectid="1"/>`)
+		fmt.Fprintln(model, ` </build>`)
+		fmt.Fprintln(model, `</model>`)
+		return model.Flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Textures    []gltfTexture    `json:"textures,omitempty"`
+	Images      []gltfImage      `json:"images,omitempty"`
+	Samplers    []gltfSampler    `json:"samplers,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh int `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type gltfMaterial struct {
+	PBRMetallicRoughness gltfPBR `json:"pbrMetallicRoughness"`
+	DoubleSided          bool    `json:"doubleSided"`
+}
+
+type gltfPBR struct {
+	BaseColorTexture *gltfTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float64          `json:"metallicFactor"`
+	RoughnessFactor  float64          `json:"roughnessFactor"`
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfTexture struct {
+	Source  int `json:"source"`
+	Sampler int `json:"sampler"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri,omitempty"`
+	BufferView *int   `json:"bufferView,omitempty"`
+	MimeType   string `json:"mimeType"`
+}
+
+type gltfSampler struct {
+	MagFilter int `json:"magFilter"`
+	MinFilter int `json:"minFilter"`
+	WrapS     int `json:"wrapS"`
+	WrapT     int `json:"wrapT"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri,omitempty"`
+	ByteLength int    `json:"byteLength"`
+}
+
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+	gltfLinear       = 9729
+	gltfClampToEdge  = 33071
+)
+
+// WriteGLTF writes glTF 2.0, Y up as the format requires. The binary GLB
+// variant stores everything in one chunked file, the JSON variant embeds
+// the buffer and texture as data URIs.
+func (mesh *Mesh) WriteGLTF(writer *bufio.Writer, texture image.Image, glb bool) error {
+	textured := texture != nil && len(mesh.TexCoords) == len(mesh.Vertices)
+
+	var buffer bytes.Buffer
+	doc := gltfDocument{
+		Asset:  gltfAsset{Version: "2.0", Generator: "asctools"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Mesh: 0}},
+	}
+	addView := func(data []byte, target int) int {
+		for buffer.Len()%4 != 0 {
+			buffer.WriteByte(0)
+		}
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{
+			ByteOffset: buffer.Len(),
+			ByteLength: len(data),
+			Target:     target,
+		})
+		buffer.Write(data)
+		return len(doc.BufferViews) - 1
+	}
+
+	minPos := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	maxPos := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	positions := make([]byte, 0, len(mesh.Vertices)*12)
+	for _, v := range mesh.Vertices {
+		p := [3]float32{v.X, v.Z, -v.Y}
+		for i, c := range p {
+			minPos[i] = min(minPos[i], c)
+			maxPos[i] = max(maxPos[i], c)
+			positions = binary.LittleEndian.AppendUint32(positions, math.Float32bits(c))
+		}
+	}
+	doc.Accessors = append(doc.Accessors, gltfAccessor{
+		BufferView:    addView(positions, gltfArrayBuffer),
+		ComponentType: gltfFloat,
+		Count:         len(mesh.Vertices),
+		Type:          "VEC3",
+		Min:           minPos,
+		Max:           maxPos,
+	})
+	primitive := gltfPrimitive{Attributes: map[string]int{"POSITION": 0}}
+
+	indices := make([]byte, 0, len(mesh.Faces)*12)
+	for _, f := range mesh.Faces {
+		for _, index := range f {
+			indices = binary.LittleEndian.AppendUint32(indices, uint32(index))
+		}
+	}
+	doc.Accessors = append(doc.Accessors, gltfAccessor{
+		BufferView:    addView(indices, gltfElementArray),
+		ComponentType: gltfUnsignedInt,
+		Count:         len(mesh.Faces) * 3,
+		Type:          "SCALAR",
+	})
+	primitive.Indices = 1
+
+	material := gltfMaterial{PBRMetallicRoughness: gltfPBR{RoughnessFactor: 1}}
+	if textured {
+		texCoords := make([]byte, 0, len(mesh.TexCoords)*8)
+		for _, uv := range mesh.TexCoords {
+			texCoords = binary.LittleEndian.AppendUint32(texCoords, math.Float32bits(uv[0]))
+			texCoords = binary.LittleEndian.AppendUint32(texCoords, math.Float32bits(1-uv[1]))
+		}
+		doc.Accessors = append(doc.Accessors, gltfAccessor{
+			BufferView:    addView(texCoords, gltfArrayBuffer),
+			ComponentType: gltfFloat,
+			Count:         len(mesh.TexCoords),
+			Type:          "VEC2",
+		})
+		primitive.Attributes["TEXCOORD_0"] = 2
+
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, texture); err != nil {
+			return fmt.Errorf("error encoding texture: %v", err)
+		}
+		if glb {
+			view := addView(encoded.Bytes(), 0)
+			doc.Images = []gltfImage{{BufferView: &view, MimeType: "image/png"}}
+		} else {
+			doc.Images = []gltfImage{{URI: "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes()), MimeType: "image/png"}}
+		}
+		doc.Samplers = []gltfSampler{{MagFilter: gltfLinear, MinFilter: gltfLinear, WrapS: gltfClampToEdge, WrapT: gltfClampToEdge}}
+		doc.Textures = []gltfTexture{{Source: 0, Sampler: 0}}
+		material.PBRMetallicRoughness.BaseColorTexture = &gltfTextureInfo{Index: 0}
+	}
+	materialIndex := 0
+	primitive.Material = &materialIndex
+	doc.Materials = []gltfMaterial{material}
+	doc.Meshes = []gltfMesh{{Primitives: []gltfPrimitive{primitive}}}
+
+	for buffer.Len()%4 != 0 {
+		buffer.WriteByte(0)
+	}
+	doc.Buffers = []gltfBuffer{{ByteLength: buffer.Len()}}
+	if !glb {
+		doc.Buffers[0].URI = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes())
+	}
+
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error encoding glTF: %v", err)
+	}
+
+	if !glb {
+		writer.Write(jsonData)
+		return writer.Flush()
+	}
+
+	for len(jsonData)%4 != 0 {
+		jsonData = append(jsonData, ' ')
+	}
+	header := make([]byte, 0, 28)
+	header = binary.LittleEndian.AppendUint32(header, 0x46546C67)
+	header = binary.LittleEndian.AppendUint32(header, 2)
+	header = binary.LittleEndian.AppendUint32(header, uint32(12+8+len(jsonData)+8+buffer.Len()))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(jsonData)))
+	header = binary.LittleEndian.AppendUint32(header, 0x4E4F534A)
+	writer.Write(header)
+	writer.Write(jsonData)
+	chunk := make([]byte, 0, 8)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(buffer.Len()))
+	chunk = binary.LittleEndian.AppendUint32(chunk, 0x004E4942)
+	writer.Write(chunk)
+	writer.Write(buffer.Bytes())
+	return writer.Flush()
+}
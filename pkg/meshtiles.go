@@ -0,0 +1,397 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+type JointType int
+
+const (
+	JointNone JointType = iota
+	// JointDowel cuts square holes into matching cut faces of neighbouring
+	// tiles, to be joined with separately printed pegs.
+	JointDowel
+)
+
+func ParseJointType(value string) (JointType, error) {
+	switch value {
+	case "none", "":
+		return JointNone, nil
+	case "dowel":
+		return JointDowel, nil
+	default:
+		return JointNone, fmt.Errorf("unknown joint type: %s", value)
+	}
+}
+
+type TileOptions struct {
+	Rows, Cols int
+	// Mesh applies to the whole map, so that all tiles share the floor,
+	// scale and exaggeration. Simplify, Origin and Plinth are not supported; every
+	// tile has its first grid point at the origin.
+	Mesh  MeshOptions
+	Joint JointType
+	// Dowel dimensions are in model units. The hole size is rounded to whole
+	// cells so that holes line up with the grid on both sides of a cut.
+	DowelSize      float64
+	DowelDepth     float64
+	DowelClearance float64
+	DowelsPerSide  int
+	// EngraveDepth is the depth of the tile index engraved into the
+	// underside, in model units. 0 leaves the underside flat.
+	EngraveDepth float64
+	// LabelHeight is the height of the engraved index in model units. When 0
+	// it is a quarter of the shorter tile side.
+	LabelHeight float64
+}
+
+func DefaultTileOptions() TileOptions {
+	return TileOptions{
+		Rows:           2,
+		Cols:           2,
+		Mesh:           DefaultMeshOptions(),
+		Joint:          JointNone,
+		DowelSize:      5,
+		DowelDepth:     5,
+		DowelClearance: 0.2,
+		DowelsPerSide:  2,
+		EngraveDepth:   0.6,
+	}
+}
+
+// MeshTile is a tile in the same layout as Split: row 0 is the southern
+// row and column 0 the western one.
+type MeshTile struct {
+	Row, Col int
+	Mesh     *Mesh
+}
+
+type MeshTiles struct {
+	Tiles []MeshTile
+	// Dowel is a peg fitting the joint holes, nil without joints.
+	Dowel *Mesh
+	// SkippedJoints counts holes left out because the model is too thin or
+	// has nodata where they would go. Both tiles of a cut skip the same
+	// holes.
+	SkippedJoints int
+	// UnlabelledTiles counts tiles whose index could not be engraved, either
+	// because it did not fit or the grid is too coarse for it.
+	UnlabelledTiles int
+}
+
+type tileBuilder struct {
+	elevationMap *ElevationMap
+	options      TileOptions
+	frame        modelFrame
+	rowBounds    []int
+	colBounds    []int
+	holeCells    int
+	holeDepth    float64
+	skipped      map[[4]int]bool
+}
+
+// BuildMeshTiles cuts the model of the map into a grid of closed parts.
+// Neighbouring tiles share the grid points along their cut.
+func (elevationMap *ElevationMap) BuildMeshTiles(options TileOptions) (*MeshTiles, error) {
+	if options.Rows <= 0 || options.Cols <= 0 {
+		return nil, fmt.Errorf("invalid dimensions")
+	}
+	if options.Mesh.Simplify.Enabled() || options.Mesh.Origin.Mode != OriginCorner || options.Mesh.Plinth.Enabled() {
+		return nil, fmt.Errorf("tiles do not support simplification, a custom origin or a plinth")
+	}
+	if options.Mesh.Mode != ModeRelief {
+		return nil, fmt.Errorf("tiles are only supported for reliefs")
+	}
+	if elevationMap.NumRows-1 < options.Rows || elevationMap.NumCols-1 < options.Cols {
+		return nil, fmt.Errorf("map is too small for %dx%d tiles", options.Rows, options.Cols)
+	}
+	if options.EngraveDepth < 0 || options.LabelHeight < 0 {
+		return nil, fmt.Errorf("engraving depth and label height must not be negative")
+	}
+
+	frame, err := elevationMap.modelFrame(options.Mesh)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := &tileBuilder{
+		elevationMap: elevationMap,
+		options:      options,
+		frame:        frame,
+		rowBounds:    tileBounds(elevationMap.NumRows-1, options.Rows),
+		colBounds:    tileBounds(elevationMap.NumCols-1, options.Cols),
+		skipped:      map[[4]int]bool{},
+	}
+
+	result := &MeshTiles{}
+
+	if options.Joint == JointDowel {
+		if err := builder.prepareDowels(); err != nil {
+			return nil, err
+		}
+		size := float64(builder.holeCells)*elevationMap.CellSize*frame.scale - 2*options.DowelClearance
+		length := 2*options.DowelDepth - 2*options.DowelClearance
+		if size <= 0 || length <= 0 {
+			return nil, fmt.Errorf("dowel clearance is too large for the dowel size")
+		}
+		result.Dowel = boxMesh(size, size, length)
+	}
+
+	for row := 0; row < options.Rows; row++ {
+		for col := 0; col < options.Cols; col++ {
+			mesh, labelled, err := builder.tile(row, col)
+			if err != nil {
+				return nil, fmt.Errorf("tile %d_%d: %v", row, col, err)
+			}
+			if !labelled {
+				result.UnlabelledTiles++
+			}
+			mesh.Transform(modelFrame{scale: frame.scale, zScale: frame.zScale}.transform)
+			result.Tiles = append(result.Tiles, MeshTile{Row: row, Col: col, Mesh: mesh})
+		}
+	}
+	result.SkippedJoints = len(builder.skipped)
+
+	return result, nil
+}
+
+// tileBounds splits numCells cells into parts of near equal size, returning
+// the grid point index of each boundary.
+func tileBounds(numCells, parts int) []int {
+	bounds := make([]int, parts+1)
+	for i := range bounds {
+		bounds[i] = int(math.Round(float64(i) * float64(numCells) / float64(parts)))
+	}
+	return bounds
+}
+
+func (builder *tileBuilder) prepareDowels() error {
+	options := builder.options
+	cellSize := builder.elevationMap.CellSize
+	if options.DowelSize <= 0 || options.DowelDepth <= 0 || options.DowelsPerSide <= 0 {
+		return fmt.Errorf("dowel size, depth and count must be greater than 0")
+	}
+	if options.DowelClearance < 0 {
+		return fmt.Errorf("dowel clearance must not be negative")
+	}
+
+	builder.holeCells = max(1, int(math.Round(options.DowelSize/(cellSize*builder.frame.scale))))
+	builder.holeDepth = options.DowelDepth / builder.frame.scale
+
+	// A hole must end before it reaches the holes of the perpendicular cuts
+	// near the tile corners.
+	minCells := math.MaxInt
+	for i := 0; i+1 < len(builder.rowBounds); i++ {
+		minCells = min(minCells, builder.rowBounds[i+1]-builder.rowBounds[i])
+	}
+	for i := 0; i+1 < len(builder.colBounds); i++ {
+		minCells = min(minCells, builder.colBounds[i+1]-builder.colBounds[i])
+	}
+	limit := (float64(minCells)/(2*float64(options.DowelsPerSide)) - float64(builder.holeCells)/2 - 1) * cellSize
+	if builder.holeDepth >= limit {
+		return fmt.Errorf("dowels are too large or too many for the tile size")
+	}
+	return nil
+}
+
+// cutHoles places the holes of the cut along grid line fixed, spanning grid
+// points [from, to] in the other direction. vertical cuts run along a grid
+// column. Holes are computed from the whole map, so both tiles of a cut get
+// the same ones.
+func (builder *tileBuilder) cutHoles(vertical bool, fixed, from, to int) []gridHole {
+	if builder.options.Joint != JointDowel {
+		return nil
+	}
+
+	elevationMap := builder.elevationMap
+	options := builder.options
+	depthCells := int(math.Ceil(builder.holeDepth/elevationMap.CellSize)) + 1
+	size := float64(builder.holeCells) * elevationMap.CellSize * builder.frame.scale / builder.frame.zScale
+	margin := size / 4
+	engraved := options.EngraveDepth / builder.frame.zScale
+
+	holes := []gridHole{}
+	for k := 0; k < options.DowelsPerSide; k++ {
+		center := from + int(math.Round((float64(k)+0.5)*float64(to-from)/float64(options.DowelsPerSide)))
+		start := center - builder.holeCells/2
+		end := start + builder.holeCells
+		if start <= from || end >= to {
+			builder.skip(vertical, fixed, from, k)
+			continue
+		}
+
+		minTop := math.Inf(1)
+		valid := true
+		for along := start; along <= end && valid; along++ {
+			for across := fixed - depthCells; across <= fixed+depthCells; across++ {
+				row, col := along, across
+				if !vertical {
+					row, col = across, along
+				}
+				if row < 0 || col < 0 || row >= elevationMap.NumRows || col >= elevationMap.NumCols {
+					continue
+				}
+				e := elevationMap.GetRowCol(row, col, true)
+				if e == NodataValue || e < builder.frame.floorElevation {
+					valid = false
+					break
+				}
+				minTop = math.Min(minTop, e-builder.frame.floorElevation)
+			}
+		}
+
+		z0 := (minTop - size) / 2
+		z1 := z0 + size
+		if !valid || z0 < engraved+margin || minTop-z1 < margin {
+			builder.skip(vertical, fixed, from, k)
+			continue
+		}
+
+		hole := gridHole{z0: z0, z1: z1, depth: builder.holeDepth}
+		if vertical {
+			hole.startRow, hole.startCol, hole.endRow, hole.endCol = start, fixed, end, fixed
+		} else {
+			hole.startRow, hole.startCol, hole.endRow, hole.endCol = fixed, start, fixed, end
+		}
+		holes = append(holes, hole)
+	}
+	return holes
+}
+
+// skip records a skipped hole once, although both tiles of its cut ask
+// for it.
+func (builder *tileBuilder) skip(vertical bool, fixed, from, k int) {
+	axis := 0
+	if vertical {
+		axis = 1
+	}
+	builder.skipped[[4]int{axis, fixed, from, k}] = true
+}
+
+func (builder *tileBuilder) tile(tileRow, tileCol int) (*Mesh, bool, error) {
+	row0, row1 := builder.rowBounds[tileRow], builder.rowBounds[tileRow+1]
+	col0, col1 := builder.colBounds[tileCol], builder.colBounds[tileCol+1]
+
+	mesher := &gridMesher{
+		elevationMap:   builder.elevationMap,
+		floorElevation: builder.frame.floorElevation,
+		row0:           row0,
+		col0:           col0,
+		row1:           row1,
+		col1:           col1,
+	}
+
+	// Holes are listed along the boundary in counter-clockwise order, which
+	// is the direction walls run in.
+	if tileRow > 0 {
+		for _, hole := range builder.cutHoles(false, row0, col0, col1) {
+			hole.inwardY = 1
+			mesher.holes = append(mesher.holes, hole)
+		}
+	}
+	if tileCol+1 < builder.options.Cols {
+		for _, hole := range builder.cutHoles(true, col1, row0, row1) {
+			hole.inwardX = -1
+			mesher.holes = append(mesher.holes, hole)
+		}
+	}
+	if tileRow+1 < builder.options.Rows {
+		for _, hole := range builder.cutHoles(false, row1, col0, col1) {
+			hole.startCol, hole.endCol = hole.endCol, hole.startCol
+			hole.inwardY = -1
+			mesher.holes = append(mesher.holes, hole)
+		}
+	}
+	if tileCol > 0 {
+		for _, hole := range builder.cutHoles(true, col0, row0, row1) {
+			hole.startRow, hole.endRow = hole.endRow, hole.startRow
+			hole.inwardX = 1
+			mesher.holes = append(mesher.holes, hole)
+		}
+	}
+
+	labelled := true
+	if builder.options.EngraveDepth > 0 {
+		var bottom func(row, col int) float64
+		bottom, labelled = builder.label(fmt.Sprintf("%d-%d", tileRow, tileCol), row0, col0, row1, col1)
+		mesher.bottom = bottom
+	}
+
+	mesh, err := mesher.build()
+	return mesh, labelled, err
+}
+
+// label engraves text into the middle of the tile underside, mirrored so
+// that it reads correctly from below.
+func (builder *tileBuilder) label(text string, row0, col0, row1, col1 int) (func(row, col int) float64, bool) {
+	cellSize := builder.elevationMap.CellSize
+	width := float64(col1-col0) * cellSize
+	height := float64(row1-row0) * cellSize
+
+	textHeight := builder.options.LabelHeight / builder.frame.scale
+	if textHeight == 0 {
+		textHeight = math.Min(width, height) / 4
+	}
+	_, textWidth := layoutText(text, textHeight)
+	if textWidth > width*0.6 {
+		textHeight *= width * 0.6 / textWidth
+	}
+	segments, textWidth := layoutText(text, textHeight)
+	strokeWidth := textHeight / 5
+	if strokeWidth < 1.5*cellSize || textHeight > height*0.6 {
+		return nil, false
+	}
+
+	left := (width + textWidth) / 2
+	base := (height - textHeight) / 2
+	for i := range segments {
+		segments[i] = segments[i].transform(func(x, y float64) (float64, float64) {
+			return left - x, base + y
+		})
+	}
+
+	depth := builder.options.EngraveDepth / builder.frame.zScale
+	return func(row, col int) float64 {
+		x := float64(col-col0) * cellSize
+		y := float64(row-row0) * cellSize
+		if x < left-textWidth-strokeWidth || x > left+strokeWidth || y < base-strokeWidth || y > base+textHeight+strokeWidth {
+			return 0
+		}
+		e := builder.elevationMap.GetRowCol(row, col, true)
+		if e == NodataValue || e-builder.frame.floorElevation < 2*depth {
+			return 0
+		}
+		for _, segment := range segments {
+			if segment.distance(x, y) <= strokeWidth/2 {
+				return depth
+			}
+		}
+		return 0
+	}, true
+}
+
+// boxMesh builds a closed box with one corner at the origin.
+func boxMesh(sizeX, sizeY, sizeZ float64) *Mesh {
+	mesh := &Mesh{}
+	for i := 0; i < 8; i++ {
+		mesh.AddVertex(Vector3{
+			X: float32(float64(i&1) * sizeX),
+			Y: float32(float64(i>>1&1) * sizeY),
+			Z: float32(float64(i>>2&1) * sizeZ),
+		})
+	}
+	quads := [][4]int{
+		{0, 2, 3, 1}, // bottom
+		{4, 5, 7, 6}, // top
+		{0, 1, 5, 4}, // front
+		{2, 6, 7, 3}, // back
+		{0, 4, 6, 2}, // left
+		{1, 3, 7, 5}, // right
+	}
+	for _, q := range quads {
+		mesh.AddFace(q[0], q[1], q[2])
+		mesh.AddFace(q[0], q[2], q[3])
+	}
+	return mesh
+}
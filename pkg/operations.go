@@ -0,0 +1,632 @@
+package asctools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var operations = map[string]operation{
+	"load":           {numInputs: 0, params: []string{"path", "crs"}, run: runLoad},
+	"merge":          {numInputs: -1, params: []string{"name"}, run: runMerge},
+	"crop":           {numInputs: 1, params: []string{"relative", "start_x", "start_y", "end_x", "end_y"}, run: runCrop},
+	"split":          {numInputs: 1, params: []string{"nrows", "ncols", "uniform", "prefix"}, run: runSplit},
+	"denoise":        {numInputs: 1, params: []string{"window", "method", "nodata", "fill_max_nodata", "min_valid", "sigma", "range_sigma", "spike_threshold"}, run: runDenoise},
+	"downscale":      {numInputs: 1, params: []string{"factor", "cellsize", "aggregation", "percentile", "edges", "min_coverage"}, run: runDownscale},
+	"subtract":       {numInputs: 2, params: []string{}, run: runSubtract},
+	"calc":           {numInputs: -1, params: []string{"expr", "extent", "cellsize", "resampling"}, run: runCalc},
+	"warp":           {numInputs: 1, params: []string{"target_crs", "cellsize", "resampling"}, run: runWarp},
+	"write_asc":      {numInputs: 1, params: []string{"path"}, run: runWriteASC},
+	"write_xyz":      {numInputs: 1, params: []string{"path", "skip_nodata", "delimiter", "precision", "header", "every"}, run: runWriteXYZ},
+	"write_html":     {numInputs: 1, params: []string{"path", "title", "max_size", "exaggeration", "ramp", "hillshade"}, run: runWriteHTML},
+	"write_png":      {numInputs: 1, params: []string{"path", "scaling_operation", "scale", "ramp", "encoding", "aux_xml"}, run: runWritePNG},
+	"write_stl":      {numInputs: 1, params: append([]string{"path"}, meshParams...), run: runWriteSTL},
+	"write_mesh":     {numInputs: 1, params: append([]string{"path", "format", "ramp"}, meshParams...), run: runWriteMesh},
+	"write_diff_png": {numInputs: 2, params: []string{"path", "diff_pow", "diff_only", "clamp", "clamp_percentile", "dead_band", "hillshade", "legend", "units", "extent", "cellsize", "resampling", "aux_xml"}, run: runWriteDiffPNG},
+}
+
+var meshParams = []string{"floor", "floor_margin", "max_error", "max_triangles", "scale", "print_width", "exaggeration", "base_thickness", "origin",
+	"mode", "min_thickness", "max_thickness", "plinth", "plinth_margin", "plinth_text", "plinth_coords", "scale_bar", "north_arrow", "text_height", "text_relief", "reference"}
+
+func runLoad(inputs [][]Layer, params *Params) ([]Layer, error) {
+	pattern := params.String("path", "")
+	crs, err := crsFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern: %v", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+	sort.Strings(paths)
+
+	layers := make([]Layer, 0, len(paths))
+	for _, path := range paths {
+		elevationMap, err := ReadASCFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		if crs != nil {
+			elevationMap.CRS = crs
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		layers = append(layers, Layer{Name: name, Map: elevationMap})
+	}
+
+	return layers, nil
+}
+
+func runMerge(inputs [][]Layer, params *Params) ([]Layer, error) {
+	name := params.String("name", "merged")
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	var maps []*ElevationMap
+	for _, input := range inputs {
+		for _, layer := range input {
+			maps = append(maps, layer.Map)
+		}
+	}
+
+	merged, err := MergeMaps(maps)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Layer{{Name: name, Map: merged}}, nil
+}
+
+func runCrop(inputs [][]Layer, params *Params) ([]Layer, error) {
+	relative := params.Bool("relative", false)
+	startX := params.Float("start_x", 0)
+	startY := params.Float("start_y", 0)
+	endX := params.Float("end_x", 1)
+	endY := params.Float("end_y", 1)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	return mapLayers(inputs[0], func(elevationMap *ElevationMap) (*ElevationMap, error) {
+		if relative {
+			return elevationMap.CropRelative(startX, startY, endX, endY)
+		}
+		return elevationMap.Crop(startX, startY, endX, endY)
+	})
+}
+
+func runSplit(inputs [][]Layer, params *Params) ([]Layer, error) {
+	nrows := params.Int("nrows", 2)
+	ncols := params.Int("ncols", 2)
+	uniform := params.Bool("uniform", false)
+	prefix := params.String("prefix", "")
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []Layer
+	for _, layer := range inputs[0] {
+		tiles, err := layer.Map.Split(nrows, ncols, uniform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+		tilePrefix := prefix
+		if tilePrefix == "" {
+			tilePrefix = layer.Name
+		}
+		for row := range tiles {
+			for col, tile := range tiles[row] {
+				if tile == nil {
+					continue
+				}
+				result = append(result, Layer{Name: fmt.Sprintf("%s_%d_%d", tilePrefix, row, col), Map: tile})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func runDenoise(inputs [][]Layer, params *Params) ([]Layer, error) {
+	options := DefaultDenoiseOptions()
+	method, err := ParseDenoiseMethod(params.String("method", "median"))
+	if err != nil {
+		return nil, err
+	}
+	options.Method = method
+	nodataMode, err := ParseNodataMode(params.String("nodata", "preserve"))
+	if err != nil {
+		return nil, err
+	}
+	options.NodataMode = nodataMode
+	options.FillMaxNodata = params.Int("fill_max_nodata", options.FillMaxNodata)
+	options.MinValidNeighbours = params.Int("min_valid", options.MinValidNeighbours)
+	options.WindowSize = params.Int("window", options.WindowSize)
+	options.Sigma = params.Float("sigma", options.Sigma)
+	options.RangeSigma = params.Float("range_sigma", options.RangeSigma)
+	options.SpikeThreshold = params.Float("spike_threshold", options.SpikeThreshold)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	return mapLayers(inputs[0], func(elevationMap *ElevationMap) (*ElevationMap, error) {
+		return elevationMap.Denoise(options)
+	})
+}
+
+func runDownscale(inputs [][]Layer, params *Params) ([]Layer, error) {
+	options := DefaultDownscaleOptions()
+	aggregation, err := ParseAggregation(params.String("aggregation", "mean"))
+	if err != nil {
+		return nil, err
+	}
+	options.Aggregation = aggregation
+	edge, err := ParseEdgePolicy(params.String("edges", "drop"))
+	if err != nil {
+		return nil, err
+	}
+	options.Edge = edge
+	options.Factor = params.Float("factor", options.Factor)
+	options.CellSize = params.Float("cellsize", options.CellSize)
+	options.Percentile = params.Float("percentile", options.Percentile)
+	options.MinCoverage = params.Float("min_coverage", options.MinCoverage)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	return mapLayers(inputs[0], func(elevationMap *ElevationMap) (*ElevationMap, error) {
+		return elevationMap.Downscale(options)
+	})
+}
+
+func runWarp(inputs [][]Layer, params *Params) ([]Layer, error) {
+	definition := params.String("target_crs", "")
+	resampling, err := ParseResampling(params.String("resampling", "bilinear"))
+	if err != nil {
+		return nil, err
+	}
+	options := WarpOptions{CellSize: params.Float("cellsize", 0), Resampling: resampling}
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	if definition == "" {
+		return nil, fmt.Errorf("target_crs is required")
+	}
+	target, err := ParseCRS(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapLayers(inputs[0], func(elevationMap *ElevationMap) (*ElevationMap, error) {
+		return elevationMap.Warp(target, options)
+	})
+}
+
+func runSubtract(inputs [][]Layer, params *Params) ([]Layer, error) {
+	return zipLayers(inputs[0], inputs[1], func(name string, layer1, layer2 Layer) (Layer, error) {
+		result, err := layer1.Map.Subtract(layer2.Map)
+		return Layer{Name: name, Map: result}, err
+	})
+}
+
+// runCalc refers to its inputs in the expression by the names of the
+// results they come from.
+func runCalc(inputs [][]Layer, params *Params) ([]Layer, error) {
+	expression, err := ParseExpression(params.String("expr", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %v", err)
+	}
+	extent, err := ParseGridExtent(params.String("extent", "intersection"))
+	if err != nil {
+		return nil, err
+	}
+	cellSize := params.Float("cellsize", 0)
+	resampling, err := ParseResampling(params.String("resampling", "nearest"))
+	if err != nil {
+		return nil, err
+	}
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	count := 1
+	for _, input := range inputs {
+		if len(input) != 1 {
+			if count != 1 && count != len(input) {
+				return nil, fmt.Errorf("inputs have different numbers of layers")
+			}
+			count = len(input)
+		}
+	}
+
+	result := make([]Layer, 0, count)
+	for i := 0; i < count; i++ {
+		maps := map[string]*ElevationMap{}
+		name := ""
+		for j, input := range inputs {
+			layer := input[min(i, len(input)-1)]
+			maps[params.inputNames[j]] = layer.Map
+			if name == "" && (len(input) > 1 || count == 1) {
+				name = layer.Name
+			}
+		}
+		elevationMap, err := Calculate(expression, maps, CalcOptions{Extent: extent, First: params.inputNames[0], CellSize: cellSize, Resampling: resampling})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		result = append(result, Layer{Name: name, Map: elevationMap})
+	}
+
+	return result, nil
+}
+
+func runWriteASC(inputs [][]Layer, params *Params) ([]Layer, error) {
+	layers, err := writeLayers(inputs[0], params, func(writer *bufio.Writer, elevationMap *ElevationMap) error {
+		return elevationMap.WriteASC(writer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		if err := writePRJ(expandOutputPath(params.String("path", ""), layer.Name), layer.Map.CRS); err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+	}
+	return layers, nil
+}
+
+func runWriteXYZ(inputs [][]Layer, params *Params) ([]Layer, error) {
+	options := DefaultXYZOptions()
+	options.SkipNodata = params.Bool("skip_nodata", options.SkipNodata)
+	delimiter, err := ParseXYZDelimiter(params.String("delimiter", "space"))
+	if err != nil {
+		return nil, err
+	}
+	options.Delimiter = delimiter
+	options.Precision = params.Int("precision", options.Precision)
+	options.Header = params.Bool("header", options.Header)
+	options.Every = params.Int("every", options.Every)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	return writeLayers(inputs[0], params, func(writer *bufio.Writer, elevationMap *ElevationMap) error {
+		return elevationMap.WriteXYZ(writer, options)
+	})
+}
+
+func runWriteHTML(inputs [][]Layer, params *Params) ([]Layer, error) {
+	options := DefaultHTMLViewerOptions()
+	options.Title = params.String("title", options.Title)
+	options.MaxSize = params.Int("max_size", options.MaxSize)
+	options.Exaggeration = params.Float("exaggeration", options.Exaggeration)
+	ramp, err := ParseColorRamp(params.String("ramp", "terrain"))
+	if err != nil {
+		return nil, err
+	}
+	options.Ramp = ramp
+	options.Hillshade = params.Bool("hillshade", options.Hillshade)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	return writeLayers(inputs[0], params, func(writer *bufio.Writer, elevationMap *ElevationMap) error {
+		return elevationMap.WriteHTMLViewer(writer, options)
+	})
+}
+
+func runWritePNG(inputs [][]Layer, params *Params) ([]Layer, error) {
+	scalingOperation, err := ParseScalingOperation(params.String("scaling_operation", "none"))
+	if err != nil {
+		return nil, err
+	}
+	scale := params.Int("scale", 1)
+	ramp, err := ParseColorRamp(params.String("ramp", "gray"))
+	if err != nil {
+		return nil, err
+	}
+	encoding, err := ParseElevationEncoding(params.String("encoding", "none"))
+	if err != nil {
+		return nil, err
+	}
+	auxXML := params.Bool("aux_xml", false)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	if scale < 1 {
+		return nil, fmt.Errorf("scale must be greater than or equal to 1")
+	}
+	if encoding != EncodingNone && scalingOperation != ScaleNone {
+		return nil, fmt.Errorf("scaling is not supported with an elevation encoding")
+	}
+
+	layers, err := writeLayers(inputs[0], params, func(writer *bufio.Writer, elevationMap *ElevationMap) error {
+		if encoding != EncodingNone {
+			return elevationMap.WriteEncodedPNG(writer, encoding)
+		}
+		return elevationMap.WritePNG(writer, scalingOperation, scale, ramp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		georef := layer.Map.PNGGeoreference(scalingOperation, scale, ramp, encoding)
+		if err := WritePNGSidecars(expandOutputPath(params.String("path", ""), layer.Name), georef, auxXML); err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+	}
+	return layers, nil
+}
+
+func crsFromParams(params *Params) (*CRS, error) {
+	definition := params.String("crs", "")
+	if definition == "" {
+		return nil, nil
+	}
+	return ParseCRS(definition)
+}
+
+func runWriteSTL(inputs [][]Layer, params *Params) ([]Layer, error) {
+	meshOptions, err := meshOptionsFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return writeLayers(inputs[0], params, func(writer *bufio.Writer, elevationMap *ElevationMap) error {
+		return elevationMap.WriteSTL(writer, meshOptions(elevationMap))
+	})
+}
+
+func runWriteMesh(inputs [][]Layer, params *Params) ([]Layer, error) {
+	path := params.String("path", "")
+	formatVal := params.String("format", "")
+	rampVal := params.String("ramp", "gray")
+	meshOptions, err := meshOptionsFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOutputPath(path, len(inputs[0])); err != nil {
+		return nil, err
+	}
+
+	var format MeshFormat
+	if formatVal != "" {
+		format, err = ParseMeshFormat(formatVal)
+	} else {
+		format, err = MeshFormatFromPath(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ramp, err := ParseColorRamp(rampVal)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, layer := range inputs[0] {
+		mesh, err := layer.Map.BuildMesh(meshOptions(layer.Map))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+		texture := layer.Map.RenderImage(ScaleNone, 1, ramp)
+		if err := WriteMeshFile(expandOutputPath(path, layer.Name), format, mesh, texture); err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+	}
+
+	return inputs[0], nil
+}
+
+// meshOptionsFromParams reads meshParams. The floor depends on each map's
+// elevations, so options are returned per map.
+func meshOptionsFromParams(params *Params) (func(*ElevationMap) MeshOptions, error) {
+	floor := params.Float("floor", 0)
+	floorMargin := params.Float("floor_margin", 0)
+	options := DefaultMeshOptions()
+	options.Simplify = TINOptions{
+		MaxError:     params.Float("max_error", 0),
+		MaxTriangles: params.Int("max_triangles", 0),
+	}
+	options.Scale = params.Float("scale", options.Scale)
+	options.PrintWidth = params.Float("print_width", options.PrintWidth)
+	options.VerticalExaggeration = params.Float("exaggeration", options.VerticalExaggeration)
+	options.BaseThickness = params.Float("base_thickness", options.BaseThickness)
+	originVal := params.String("origin", "corner")
+	modeVal := params.String("mode", "relief")
+	options.MinThickness = params.Float("min_thickness", 0)
+	options.MaxThickness = params.Float("max_thickness", 0)
+	options.Plinth.Thickness = params.Float("plinth", options.Plinth.Thickness)
+	options.Plinth.Margin = params.Float("plinth_margin", options.Plinth.Margin)
+	options.Plinth.Text = params.Strings("plinth_text")
+	options.Plinth.Coordinates = params.Bool("plinth_coords", false)
+	options.Plinth.ScaleBar = params.Bool("scale_bar", false)
+	options.Plinth.NorthArrow = params.Bool("north_arrow", false)
+	options.Plinth.TextHeight = params.Float("text_height", options.Plinth.TextHeight)
+	options.Plinth.Relief = params.Float("text_relief", options.Plinth.Relief)
+	referencePath := params.String("reference", "")
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	if referencePath != "" {
+		reference, err := ReadASCFile(referencePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", referencePath, err)
+		}
+		options.Reference = reference
+	}
+	origin, err := ParseMeshOrigin(originVal)
+	if err != nil {
+		return nil, err
+	}
+	options.Origin = origin
+	options.Mode, err = ParseSurfaceMode(modeVal)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(elevationMap *ElevationMap) MeshOptions {
+		mapOptions := options
+		floorMap := elevationMap
+		if options.Reference != nil {
+			floorMap = options.Reference
+		}
+		mapOptions.FloorElevation = floorMap.FloorElevation(floor, floorMargin)
+		return mapOptions
+	}, nil
+}
+
+func runWriteDiffPNG(inputs [][]Layer, params *Params) ([]Layer, error) {
+	path := params.String("path", "")
+	options := DefaultDiffOptions()
+	options.DiffPow = params.Float("diff_pow", options.DiffPow)
+	options.DiffOnly = params.Bool("diff_only", options.DiffOnly)
+	options.Clamp = params.Float("clamp", options.Clamp)
+	options.ClampPercentile = params.Float("clamp_percentile", options.ClampPercentile)
+	options.DeadBand = params.Float("dead_band", options.DeadBand)
+	options.Hillshade = params.Bool("hillshade", options.Hillshade)
+	options.Legend = params.Bool("legend", options.Legend)
+	options.Units = params.String("units", options.Units)
+	extent, err := ParseGridExtent(params.String("extent", "intersection"))
+	if err != nil {
+		return nil, err
+	}
+	options.Extent = extent
+	options.CellSize = params.Float("cellsize", options.CellSize)
+	resampling, err := ParseResampling(params.String("resampling", "nearest"))
+	if err != nil {
+		return nil, err
+	}
+	options.Resampling = resampling
+	auxXML := params.Bool("aux_xml", false)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkOutputPath(path, max(len(inputs[0]), len(inputs[1]))); err != nil {
+		return nil, err
+	}
+
+	return zipLayers(inputs[0], inputs[1], func(name string, layer1, layer2 Layer) (Layer, error) {
+		img, georef, err := RenderDiffImage(layer1.Map, layer2.Map, options)
+		if err != nil {
+			return Layer{}, err
+		}
+		outputPath := expandOutputPath(path, name)
+		err = writeFile(outputPath, func(writer *bufio.Writer) error {
+			return WriteGeoreferencedPNG(writer, img, georef)
+		})
+		if err != nil {
+			return Layer{}, err
+		}
+		return Layer{Name: name, Map: layer1.Map}, WritePNGSidecars(outputPath, georef, auxXML)
+	})
+}
+
+func mapLayers(layers []Layer, fn func(*ElevationMap) (*ElevationMap, error)) ([]Layer, error) {
+	result := make([]Layer, 0, len(layers))
+	for _, layer := range layers {
+		elevationMap, err := fn(layer.Map)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+		result = append(result, Layer{Name: layer.Name, Map: elevationMap})
+	}
+	return result, nil
+}
+
+// zipLayers pairs two inputs by position. A single-layer input is paired
+// with every layer of the other one, which then also names the result.
+func zipLayers(layers1, layers2 []Layer, fn func(string, Layer, Layer) (Layer, error)) ([]Layer, error) {
+	count := len(layers1)
+	if len(layers2) > count {
+		count = len(layers2)
+	}
+	if len(layers1) != count && len(layers1) != 1 || len(layers2) != count && len(layers2) != 1 {
+		return nil, fmt.Errorf("inputs have %d and %d layers, cannot pair them", len(layers1), len(layers2))
+	}
+
+	result := make([]Layer, 0, count)
+	for i := 0; i < count; i++ {
+		layer1 := layers1[min(i, len(layers1)-1)]
+		layer2 := layers2[min(i, len(layers2)-1)]
+		name := layer1.Name
+		if len(layers1) == 1 && count > 1 {
+			name = layer2.Name
+		}
+		layer, err := fn(name, layer1, layer2)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s: %v", layer1.Name, layer2.Name, err)
+		}
+		result = append(result, layer)
+	}
+	return result, nil
+}
+
+func writeLayers(layers []Layer, params *Params, write func(*bufio.Writer, *ElevationMap) error) ([]Layer, error) {
+	path := params.String("path", "")
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkOutputPath(path, len(layers)); err != nil {
+		return nil, err
+	}
+
+	for _, layer := range layers {
+		err := writeFile(expandOutputPath(path, layer.Name), func(writer *bufio.Writer) error {
+			return write(writer, layer.Map)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", layer.Name, err)
+		}
+	}
+
+	return layers, nil
+}
+
+func checkOutputPath(path string, numLayers int) error {
+	if path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if numLayers > 1 && !strings.Contains(path, "{name}") {
+		return fmt.Errorf("path must contain {name} when writing %d layers", numLayers)
+	}
+	return nil
+}
+
+func expandOutputPath(path, name string) string {
+	return strings.ReplaceAll(path, "{name}", name)
+}
+
+func writeFile(path string, write func(*bufio.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
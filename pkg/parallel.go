@@ -0,0 +1,34 @@
+package asctools
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelRows splits [0, numRows) into bands and processes them on
+// workers goroutines. process is called with the worker index so that
+// callers can keep per-worker scratch buffers.
+func parallelRows(numRows, workers int, process func(worker, startRow, endRow int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = max(1, min(workers, numRows))
+	bandHeight := max(1, numRows/(workers*4))
+
+	bands := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for startRow := range bands {
+				process(worker, startRow, min(startRow+bandHeight, numRows))
+			}
+		}(w)
+	}
+	for startRow := 0; startRow < numRows; startRow += bandHeight {
+		bands <- startRow
+	}
+	close(bands)
+	wg.Wait()
+}
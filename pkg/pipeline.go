@@ -0,0 +1,263 @@
+package asctools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Recipe struct {
+	Steps []RecipeStep `json:"steps" yaml:"steps"`
+}
+
+type RecipeStep struct {
+	Operation string         `json:"op" yaml:"op"`
+	Inputs    []string       `json:"inputs" yaml:"inputs"`
+	Output    string         `json:"output" yaml:"output"`
+	Params    map[string]any `json:"params" yaml:"params"`
+}
+
+// Layer is a single named map flowing through a pipeline. Steps operate on
+// lists of layers so that a glob input runs every following step in batch.
+type Layer struct {
+	Name string
+	Map  *ElevationMap
+}
+
+type Params struct {
+	values     map[string]any
+	inputNames []string
+	err        error
+}
+
+type operation struct {
+	numInputs int
+	params    []string
+	run       func(inputs [][]Layer, params *Params) ([]Layer, error)
+}
+
+func ParseRecipe(reader io.Reader, format string) (*Recipe, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading recipe: %v", err)
+	}
+
+	recipe := &Recipe{}
+	switch strings.ToLower(format) {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(recipe)
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(recipe)
+	default:
+		return nil, fmt.Errorf("unknown recipe format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing recipe: %v", err)
+	}
+
+	if len(recipe.Steps) == 0 {
+		return nil, fmt.Errorf("recipe has no steps")
+	}
+
+	return recipe, nil
+}
+
+func RecipeFormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
+
+func (recipe *Recipe) Validate() error {
+	defined := map[string]bool{}
+	previous := ""
+	for i, step := range recipe.Steps {
+		op, ok := operations[step.Operation]
+		if !ok {
+			return fmt.Errorf("step %d: unknown operation %q", i+1, step.Operation)
+		}
+		for key := range step.Params {
+			if !containsString(op.params, key) {
+				return fmt.Errorf("step %d (%s): unknown parameter %q", i+1, step.Operation, key)
+			}
+		}
+		inputs := stepInputNames(step, op, previous)
+		if op.numInputs >= 0 && len(inputs) != op.numInputs {
+			return fmt.Errorf("step %d (%s): expected %d input(s), got %d", i+1, step.Operation, op.numInputs, len(inputs))
+		}
+		if op.numInputs < 0 && len(inputs) == 0 {
+			return fmt.Errorf("step %d (%s): no inputs", i+1, step.Operation)
+		}
+		for _, input := range inputs {
+			if !defined[input] {
+				return fmt.Errorf("step %d (%s): undefined input %q", i+1, step.Operation, input)
+			}
+		}
+		name := stepOutputName(step, i)
+		defined[name] = true
+		previous = name
+	}
+	return nil
+}
+
+func (recipe *Recipe) Run() (map[string][]Layer, error) {
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+
+	results := map[string][]Layer{}
+	previous := ""
+	for i, step := range recipe.Steps {
+		op := operations[step.Operation]
+		names := stepInputNames(step, op, previous)
+
+		inputs := make([][]Layer, len(names))
+		for j, name := range names {
+			inputs[j] = results[name]
+		}
+
+		output, err := op.run(inputs, &Params{values: step.Params, inputNames: names})
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1, step.Operation, err)
+		}
+
+		name := stepOutputName(step, i)
+		results[name] = output
+		previous = name
+	}
+
+	return results, nil
+}
+
+func stepInputNames(step RecipeStep, op operation, previous string) []string {
+	if len(step.Inputs) == 0 && op.numInputs != 0 && previous != "" {
+		return []string{previous}
+	}
+	return step.Inputs
+}
+
+func stepOutputName(step RecipeStep, index int) string {
+	if step.Output != "" {
+		return step.Output
+	}
+	return fmt.Sprintf("step%d", index+1)
+}
+
+func OperationNames() []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (params *Params) Float(key string, defaultValue float64) float64 {
+	raw, ok := params.values[key]
+	if !ok {
+		return defaultValue
+	}
+	switch value := raw.(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	}
+	params.fail(fmt.Errorf("parameter %s must be a number", key))
+	return defaultValue
+}
+
+func (params *Params) Int(key string, defaultValue int) int {
+	value := params.Float(key, float64(defaultValue))
+	if value != math.Trunc(value) {
+		params.fail(fmt.Errorf("parameter %s must be an integer", key))
+		return defaultValue
+	}
+	return int(value)
+}
+
+func (params *Params) String(key string, defaultValue string) string {
+	raw, ok := params.values[key]
+	if !ok {
+		return defaultValue
+	}
+	value, ok := raw.(string)
+	if !ok {
+		params.fail(fmt.Errorf("parameter %s must be a string", key))
+		return defaultValue
+	}
+	return value
+}
+
+// Strings reads a list of strings, or a single string as a list of one.
+func (params *Params) Strings(key string) []string {
+	raw, ok := params.values[key]
+	if !ok {
+		return nil
+	}
+	switch value := raw.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, len(value))
+		for i, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				params.fail(fmt.Errorf("parameter %s must be a list of strings", key))
+				return nil
+			}
+			values[i] = s
+		}
+		return values
+	}
+	params.fail(fmt.Errorf("parameter %s must be a string or a list of strings", key))
+	return nil
+}
+
+func (params *Params) Bool(key string, defaultValue bool) bool {
+	raw, ok := params.values[key]
+	if !ok {
+		return defaultValue
+	}
+	value, ok := raw.(bool)
+	if !ok {
+		params.fail(fmt.Errorf("parameter %s must be a boolean", key))
+		return defaultValue
+	}
+	return value
+}
+
+func (params *Params) Err() error {
+	return params.err
+}
+
+func (params *Params) fail(err error) {
+	if params.err == nil {
+		params.err = err
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
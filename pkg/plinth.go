@@ -0,0 +1,256 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+// PlinthOptions describe a flat block the model stands on, with a label
+// strip in front of it. All sizes are in model units.
+type PlinthOptions struct {
+	// Thickness of the plinth under the model. 0 disables the plinth.
+	Thickness float64
+	// Margin is the width of the plinth around the model.
+	Margin float64
+	// Text lines are written into the label strip, e.g. a title and a date.
+	Text []string
+	// Coordinates adds a line with the map coordinates of the model centre.
+	Coordinates bool
+	// ScaleBar adds a bar of a round length in map units, labelled assuming
+	// the map units are metres.
+	ScaleBar bool
+	// NorthArrow adds an arrow pointing along the map Y axis.
+	NorthArrow bool
+	TextHeight float64
+	// Relief is the height of text and symbols above the plinth top. Negative
+	// values engrave them instead.
+	Relief float64
+}
+
+func DefaultPlinthOptions() PlinthOptions {
+	return PlinthOptions{
+		Margin:     5,
+		TextHeight: 5,
+		Relief:     0.8,
+	}
+}
+
+func (options PlinthOptions) Enabled() bool {
+	return options.Thickness > 0
+}
+
+// plinthLayout is the plinth outline and its text and symbols, in model
+// units with the first model grid point at the origin.
+type plinthLayout struct {
+	minX, minY float64
+	maxX, maxY float64
+	// labelTop is the top of the label strip. Text and symbols lie below it.
+	labelTop    float64
+	strokes     []strokeSegment
+	strokeWidth float64
+	polygons    [][][2]float64
+}
+
+// buildPlinth builds the plinth as a closed solid in output coordinates,
+// with its top at the plinth thickness.
+func (elevationMap *ElevationMap) buildPlinth(frame modelFrame, options PlinthOptions) (*Mesh, error) {
+	if options.Margin < 0 || options.TextHeight <= 0 {
+		return nil, fmt.Errorf("plinth margin must not be negative and text height must be greater than 0")
+	}
+	if options.Relief <= -options.Thickness {
+		return nil, fmt.Errorf("plinth engraving must be shallower than the plinth")
+	}
+
+	layout, err := elevationMap.layoutPlinth(frame, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The plinth is rendered into a height field fine enough to resolve the
+	// strokes, then simplified, which collapses the flat areas.
+	resolution := layout.strokeWidth / 3
+	numCols := int(math.Ceil((layout.maxX-layout.minX)/resolution)) + 1
+	numRows := int(math.Ceil((layout.maxY-layout.minY)/resolution)) + 1
+	resolution = math.Max((layout.maxX-layout.minX)/float64(numCols-1), (layout.maxY-layout.minY)/float64(numRows-1))
+
+	heights := makeElevationMapWithSize(0, 0, numRows, numCols, resolution)
+	heights.MinElevation = options.Thickness
+	heights.MaxElevation = options.Thickness
+	for row := 0; row < numRows; row++ {
+		y := layout.minY + float64(row)*resolution
+		for col := 0; col < numCols; col++ {
+			x := layout.minX + float64(col)*resolution
+			height := options.Thickness
+			if layout.inside(x, y) {
+				height += options.Relief
+			}
+			heights.Data[(numRows-1-row)*numCols+col] = float32(height)
+			heights.MinElevation = math.Min(heights.MinElevation, height)
+			heights.MaxElevation = math.Max(heights.MaxElevation, height)
+		}
+	}
+
+	mesh, err := heights.BuildTIN(0, TINOptions{MaxError: math.Max(math.Abs(options.Relief)/10, minSurfaceHeight)})
+	if err != nil {
+		return nil, err
+	}
+	corner := frame.transform(Vector3{})
+	mesh.Transform(func(v Vector3) Vector3 {
+		return Vector3{
+			X: v.X + float32(layout.minX) + corner.X,
+			Y: v.Y + float32(layout.minY) + corner.Y,
+			Z: v.Z,
+		}
+	})
+	return mesh, nil
+}
+
+func (elevationMap *ElevationMap) layoutPlinth(frame modelFrame, options PlinthOptions) (*plinthLayout, error) {
+	width := float64(elevationMap.NumCols-1) * elevationMap.CellSize * frame.scale
+	height := float64(elevationMap.NumRows-1) * elevationMap.CellSize * frame.scale
+	textHeight := options.TextHeight
+	lineSpacing := textHeight * 1.6
+
+	lines := append([]string{}, options.Text...)
+	if options.Coordinates {
+		lines = append(lines, fmt.Sprintf("%.7g, %.7g", (elevationMap.MinX+elevationMap.MaxX)/2, (elevationMap.MinY+elevationMap.MaxY)/2))
+	}
+
+	contentHeight := 0.0
+	if len(lines) > 0 {
+		contentHeight = float64(len(lines)-1)*lineSpacing + textHeight
+	}
+	if options.NorthArrow {
+		contentHeight = math.Max(contentHeight, 2.5*textHeight)
+	}
+	if options.ScaleBar {
+		contentHeight = math.Max(contentHeight, 1.8*textHeight)
+	}
+
+	layout := &plinthLayout{
+		minX:        -options.Margin,
+		minY:        -options.Margin,
+		maxX:        width + options.Margin,
+		maxY:        height + options.Margin,
+		strokeWidth: textHeight / 6,
+	}
+	if contentHeight == 0 {
+		return layout, nil
+	}
+	layout.minY -= contentHeight + options.Margin
+	top := -options.Margin
+	layout.labelTop = top
+
+	// Symbols are laid out from the right edge of the model.
+	right := width
+	if options.NorthArrow {
+		arrowWidth := 0.8 * textHeight
+		center := right - arrowWidth/2
+		base := top - 2.5*textHeight
+		layout.polygons = append(layout.polygons, [][2]float64{
+			{center, base + 1.2*textHeight},
+			{center - arrowWidth/2, base},
+			{center, base + 0.3*textHeight},
+			{center + arrowWidth/2, base},
+		})
+		letter, letterWidth := layoutText("N", textHeight)
+		layout.addText(letter, center-letterWidth/2, top-textHeight)
+		right -= arrowWidth + textHeight
+	}
+	if options.ScaleBar {
+		length := niceLength(0.25 * width / frame.scale)
+		barWidth := length * frame.scale
+		if barWidth > right {
+			return nil, fmt.Errorf("model is too narrow for a scale bar")
+		}
+		barHeight := 0.5 * textHeight
+		base := top - 1.8*textHeight
+		left := right - barWidth
+		layout.strokes = append(layout.strokes,
+			strokeSegment{left, base, right, base},
+			strokeSegment{right, base, right, base + barHeight},
+			strokeSegment{right, base + barHeight, left, base + barHeight},
+			strokeSegment{left, base + barHeight, left, base},
+		)
+		for i := 0; i < 4; i += 2 {
+			x0 := left + float64(i)*barWidth/4
+			x1 := left + float64(i+1)*barWidth/4
+			layout.polygons = append(layout.polygons, [][2]float64{
+				{x0, base}, {x1, base}, {x1, base + barHeight}, {x0, base + barHeight},
+			})
+		}
+		label, labelWidth := layoutText(formatDistance(length), textHeight)
+		labelLeft := math.Min(left+(barWidth-labelWidth)/2, right-labelWidth)
+		layout.addText(label, labelLeft, top-textHeight)
+		right = math.Min(left, labelLeft) - textHeight
+	}
+
+	for i, line := range lines {
+		segments, lineWidth := layoutText(line, textHeight)
+		if lineWidth > right {
+			return nil, fmt.Errorf("plinth text %q does not fit next to the model, use a smaller text height", line)
+		}
+		layout.addText(segments, 0, top-textHeight-float64(i)*lineSpacing)
+	}
+
+	return layout, nil
+}
+
+func (layout *plinthLayout) addText(segments []strokeSegment, x, y float64) {
+	for _, segment := range segments {
+		layout.strokes = append(layout.strokes, segment.transform(func(sx, sy float64) (float64, float64) {
+			return sx + x, sy + y
+		}))
+	}
+}
+
+func (layout *plinthLayout) inside(x, y float64) bool {
+	radius := layout.strokeWidth / 2
+	if y > layout.labelTop+radius {
+		return false
+	}
+	for _, segment := range layout.strokes {
+		if x < math.Min(segment.X0, segment.X1)-radius || x > math.Max(segment.X0, segment.X1)+radius ||
+			y < math.Min(segment.Y0, segment.Y1)-radius || y > math.Max(segment.Y0, segment.Y1)+radius {
+			continue
+		}
+		if segment.distance(x, y) <= radius {
+			return true
+		}
+	}
+	for _, polygon := range layout.polygons {
+		if pointInPolygon(polygon, x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func pointInPolygon(polygon [][2]float64, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a[1] > y) != (b[1] > y) && x < a[0]+(y-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// niceLength rounds a length down to 1, 2 or 5 times a power of ten.
+func niceLength(length float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(length)))
+	for _, factor := range []float64{5, 2, 1} {
+		if factor*magnitude <= length {
+			return factor * magnitude
+		}
+	}
+	return magnitude
+}
+
+func formatDistance(metres float64) string {
+	if metres >= 1000 {
+		return fmt.Sprintf("%g km", metres/1000)
+	}
+	return fmt.Sprintf("%g m", metres)
+}
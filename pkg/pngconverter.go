@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
 	"math"
 
 	"golang.org/x/image/draw"
@@ -19,7 +18,27 @@ const (
 	ScaleUp
 )
 
-func (elevationMap *ElevationMap) WritePNG(writer *bufio.Writer, scalingOperation ScalingOperation, scale int) error {
+func ParseScalingOperation(value string) (ScalingOperation, error) {
+	switch value {
+	case "up":
+		return ScaleUp, nil
+	case "down":
+		return ScaleDown, nil
+	case "none", "":
+		return ScaleNone, nil
+	default:
+		return ScaleNone, fmt.Errorf("unknown scaling operation: %s", value)
+	}
+}
+
+func (elevationMap *ElevationMap) WritePNG(writer *bufio.Writer, scalingOperation ScalingOperation, scale int, ramp ColorRamp) error {
+	img := elevationMap.RenderImage(scalingOperation, scale, ramp)
+	return WriteGeoreferencedPNG(writer, img, elevationMap.PNGGeoreference(scalingOperation, scale, ramp, EncodingNone))
+}
+
+// RenderImage renders one pixel per cell, north up. RampGray produces a 16
+// bit grayscale image, other ramps produce RGBA with transparent nodata.
+func (elevationMap *ElevationMap) RenderImage(scalingOperation ScalingOperation, scale int, ramp ColorRamp) image.Image {
 	scaleStep := 1
 	if scalingOperation == ScaleDown && scale > 1 {
 		scaleStep = scale
@@ -31,7 +50,14 @@ func (elevationMap *ElevationMap) WritePNG(writer *bufio.Writer, scalingOperatio
 		imgWidth = imgWidth / scale
 		imgHeight = imgHeight / scale
 	}
-	img := image.NewGray16(image.Rect(0, 0, imgWidth, imgHeight))
+
+	newImage := func(width, height int) draw.Image {
+		if ramp == RampGray {
+			return image.NewGray16(image.Rect(0, 0, width, height))
+		}
+		return image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	img := newImage(imgWidth, imgHeight)
 
 	elevationRange := elevationMap.MaxElevation - elevationMap.MinElevation
 
@@ -40,12 +66,17 @@ func (elevationMap *ElevationMap) WritePNG(writer *bufio.Writer, scalingOperatio
 		imgX := 0
 		for x := 0.0; x < elevationMap.GetWidth(); x += elevationMap.CellSize * float64(scaleStep) {
 			elevation := elevationMap.GetElevation(elevationMap.MinX+x, elevationMap.MinY+y)
-			if elevation == NodataValue {
-				img.SetGray16(imgX, imgY, color.Gray16{Y: 0})
-			} else {
-				normalized := (elevation - elevationMap.MinElevation) / elevationRange
-				grayValue := uint16(normalized * math.MaxUint16)
-				img.SetGray16(imgX, imgY, color.Gray16{Y: grayValue})
+			normalized := (elevation - elevationMap.MinElevation) / elevationRange
+			switch img := img.(type) {
+			case *image.Gray16:
+				if elevation == NodataValue {
+					img.SetGray16(imgX, imgY, color.Gray16{Y: 0})
+				} else {
+					grayValue := uint16(normalized * math.MaxUint16)
+					img.SetGray16(imgX, imgY, color.Gray16{Y: grayValue})
+				}
+			case *image.RGBA:
+				img.SetRGBA(imgX, imgY, elevationMap.elevationColor(elevation, ramp))
 			}
 			imgX++
 		}
@@ -55,101 +86,23 @@ func (elevationMap *ElevationMap) WritePNG(writer *bufio.Writer, scalingOperatio
 	if scalingOperation == ScaleUp && scale > 1 {
 		newWidth := int(float64(img.Bounds().Dx()) * float64(scale))
 		newHeight := int(float64(img.Bounds().Dy()) * float64(scale))
-		scaledImg := image.NewGray16(image.Rect(0, 0, newWidth, newHeight))
+		scaledImg := newImage(newWidth, newHeight)
 		draw.NearestNeighbor.Scale(scaledImg, scaledImg.Bounds(), img, img.Bounds(), draw.Over, nil)
 		img = scaledImg
 	}
 
-	err := png.Encode(writer, img)
-	if err != nil {
-		return fmt.Errorf("error encoding PNG: %v", err)
-	}
-	return writer.Flush()
+	return img
 }
 
-func WriteDiffPNG(writer *bufio.Writer, elevationMap1 *ElevationMap, elevationMap2 *ElevationMap, diffPow float64, diffOnly bool) error {
-	minX := math.Max(elevationMap1.MinX, elevationMap2.MinX)
-	maxX := math.Min(elevationMap1.MaxX, elevationMap2.MaxX)
-	minY := math.Max(elevationMap1.MinY, elevationMap2.MinY)
-	maxY := math.Min(elevationMap1.MaxY, elevationMap2.MaxY)
-
-	if minX >= maxX || minY >= maxY {
-		return fmt.Errorf("elevation maps do not overlap")
-	}
-
-	imgWidth := int(elevationMap1.GetWidth() / elevationMap1.CellSize)
-	imgHeight := int(elevationMap1.GetHeight() / elevationMap1.CellSize)
-
-	img := image.NewRGBA64(image.Rect(0, 0, imgWidth, imgHeight))
-
-	maxDiff := -math.MaxFloat64
-
-	step := elevationMap1.CellSize
-
-	for y := minY; y < maxY; y += step {
-		for x := minX; x < maxX; x += step {
-			elevation1 := elevationMap1.GetElevation(x, y)
-			elevation2 := elevationMap2.GetElevation(x, y)
-			if elevation1 != NodataValue && elevation2 != NodataValue {
-				diff := math.Abs(elevation2 - elevation1)
-				if diff > maxDiff {
-					maxDiff = diff
-				}
-			}
-		}
-	}
-
-	elevationRange1 := elevationMap1.MaxElevation - elevationMap1.MinElevation
-	elevationRange2 := elevationMap2.MaxElevation - elevationMap2.MinElevation
-	elevationRange := math.Max(elevationRange1, elevationRange2)
-
-	for imgY := 0; imgY < imgHeight; imgY++ {
-		for imgX := 0; imgX < imgWidth; imgX++ {
-			flippedImgY := imgHeight - imgY - 1
-			mapX := elevationMap1.MinX + float64(imgX)*elevationMap1.CellSize
-			mapY := elevationMap1.MinY + float64(imgY)*elevationMap1.CellSize
-
-			elevation1 := elevationMap1.GetElevation(mapX, mapY)
-			elevation2 := elevationMap2.GetElevation(mapX, mapY)
-			elevationDiff := math.Abs(elevation2 - elevation1)
-
-			var tintColor color.RGBA64
-			if elevation2 < elevation1 {
-				tintColor = color.RGBA64{R: math.MaxUint16, G: 0, B: 0, A: math.MaxUint16}
-			} else {
-				tintColor = color.RGBA64{R: 0, G: math.MaxUint16, B: 0, A: math.MaxUint16}
-			}
-			if elevation1 == NodataValue || elevation2 == NodataValue {
-				img.SetRGBA64(imgX, flippedImgY, color.RGBA64{R: 0, G: 0, B: 0, A: 0})
-			} else {
-				normalized := (elevation1 - elevationMap1.MinElevation) / elevationRange
-				emphasized := math.Pow(normalized, diffPow)
-
-				if emphasized > 1 {
-					emphasized = 1
-				}
-
-				grayValue := uint16(emphasized * math.MaxUint16)
-				elevationColor := color.RGBA64{R: grayValue, G: grayValue, B: grayValue, A: math.MaxUint16}
-				if diffOnly {
-					elevationColor = color.RGBA64{R: 0, G: 0, B: 0, A: math.MaxUint16}
-				}
-				interpolationFactor := elevationDiff / maxDiff
-
-				diffColor := color.RGBA64{
-					R: uint16(float64(elevationColor.R)*(1-interpolationFactor) + float64(tintColor.R)*interpolationFactor),
-					G: uint16(float64(elevationColor.G)*(1-interpolationFactor) + float64(tintColor.G)*interpolationFactor),
-					B: uint16(float64(elevationColor.B)*(1-interpolationFactor) + float64(tintColor.B)*interpolationFactor),
-					A: math.MaxUint16,
-				}
-				img.SetRGBA64(imgX, flippedImgY, diffColor)
-			}
-		}
+// elevationColor returns the ramp colour of an elevation over the range of
+// the map, transparent for nodata.
+func (elevationMap *ElevationMap) elevationColor(elevation float64, ramp ColorRamp) color.RGBA {
+	if elevation == NodataValue {
+		return color.RGBA{}
 	}
-	err := png.Encode(writer, img)
-	if err != nil {
-		return fmt.Errorf("error encoding PNG: %v", err)
+	elevationRange := elevationMap.MaxElevation - elevationMap.MinElevation
+	if elevationRange <= 0 {
+		return ramp.Color(0)
 	}
-
-	return nil
+	return ramp.Color((elevation - elevationMap.MinElevation) / elevationRange)
 }
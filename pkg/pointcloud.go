@@ -0,0 +1,178 @@
+package asctools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Point struct {
+	X, Y, Z float64
+	// Classification is the ASPRS class of LAS points, e.g. 2 for ground. It
+	// is 0 (never classified) for points from text files.
+	Classification uint8
+}
+
+// lasMinRecordLength is the size of the fields each LAS point data format
+// defines. Records may be longer with extra bytes.
+var lasMinRecordLength = []int{20, 28, 26, 34, 57, 63, 30, 36, 38, 59, 67}
+
+// ReadLAS reads uncompressed LAS 1.0 to 1.4 files with point data formats 0
+// to 10. Withheld points are left out.
+func ReadLAS(reader io.Reader) ([]Point, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LAS: %v", err)
+	}
+	if len(data) < 227 || string(data[0:4]) != "LASF" {
+		return nil, fmt.Errorf("not a LAS file")
+	}
+
+	versionMajor, versionMinor := data[24], data[25]
+	if versionMajor != 1 || versionMinor > 4 {
+		return nil, fmt.Errorf("unsupported LAS version %d.%d", versionMajor, versionMinor)
+	}
+	headerSize := int(binary.LittleEndian.Uint16(data[94:]))
+	pointOffset := int(binary.LittleEndian.Uint32(data[96:]))
+	formatByte := data[104]
+	if formatByte&0xC0 != 0 {
+		return nil, fmt.Errorf("compressed LAZ files are not supported")
+	}
+	format := int(formatByte)
+	if format >= len(lasMinRecordLength) {
+		return nil, fmt.Errorf("unsupported LAS point data format %d", format)
+	}
+	recordLength := int(binary.LittleEndian.Uint16(data[105:]))
+	if recordLength < lasMinRecordLength[format] {
+		return nil, fmt.Errorf("LAS point records of %d bytes are too short for format %d", recordLength, format)
+	}
+	numPoints := int(binary.LittleEndian.Uint32(data[107:]))
+	if numPoints == 0 && headerSize >= 255 && len(data) >= 255 {
+		numPoints = int(binary.LittleEndian.Uint64(data[247:]))
+	}
+
+	readFloat := func(offset int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+	}
+	scaleX, scaleY, scaleZ := readFloat(131), readFloat(139), readFloat(147)
+	offsetX, offsetY, offsetZ := readFloat(155), readFloat(163), readFloat(171)
+
+	if pointOffset+numPoints*recordLength > len(data) {
+		return nil, fmt.Errorf("LAS file is truncated, expected %d points", numPoints)
+	}
+
+	points := make([]Point, 0, numPoints)
+	for i := 0; i < numPoints; i++ {
+		record := data[pointOffset+i*recordLength:]
+		var classification uint8
+		var withheld bool
+		if format < 6 {
+			classification = record[15] & 0x1F
+			withheld = record[15]&0x80 != 0
+		} else {
+			classification = record[16]
+			withheld = record[15]&0x04 != 0
+		}
+		if withheld {
+			continue
+		}
+		points = append(points, Point{
+			X:              float64(int32(binary.LittleEndian.Uint32(record[0:])))*scaleX + offsetX,
+			Y:              float64(int32(binary.LittleEndian.Uint32(record[4:])))*scaleY + offsetY,
+			Z:              float64(int32(binary.LittleEndian.Uint32(record[8:])))*scaleZ + offsetZ,
+			Classification: classification,
+		})
+	}
+
+	return points, nil
+}
+
+// ReadXYZ reads points from text with x, y and z in the first three columns,
+// separated by whitespace, commas or semicolons. Further columns are
+// ignored, as are header, comment and empty lines.
+func ReadXYZ(reader io.Reader) ([]Point, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	points := []Point{}
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || unicode.IsSpace(r)
+		})
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected x, y and z", lineNumber)
+		}
+		var values [3]float64
+		var err error
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if len(points) == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		points = append(points, Point{X: values[0], Y: values[1], Z: values[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading points: %v", err)
+	}
+
+	return points, nil
+}
+
+// ReadPoints reads LAS or XYZ points, telling them apart by the LAS file
+// signature.
+func ReadPoints(reader io.Reader) ([]Point, error) {
+	buffered := bufio.NewReader(reader)
+	signature, _ := buffered.Peek(4)
+	if bytes.Equal(signature, []byte("LASF")) {
+		return ReadLAS(buffered)
+	}
+	return ReadXYZ(buffered)
+}
+
+func ReadPointFile(path string) ([]Point, error) {
+	if strings.EqualFold(filepath.Ext(path), ".laz") {
+		return nil, fmt.Errorf("compressed LAZ files are not supported")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadPoints(file)
+}
+
+// FilterPointClasses keeps the points of the given classes.
+func FilterPointClasses(points []Point, classes []uint8) []Point {
+	keep := [256]bool{}
+	for _, class := range classes {
+		keep[class] = true
+	}
+	filtered := make([]Point, 0, len(points))
+	for _, point := range points {
+		if keep[point.Classification] {
+			filtered = append(filtered, point)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,240 @@
+package asctools
+
+import (
+	"math"
+)
+
+// Projection converts between projected coordinates of a CRS and WGS84
+// longitude and latitude in degrees.
+type Projection interface {
+	ToGeographic(x, y float64) (lon, lat float64)
+	FromGeographic(lon, lat float64) (x, y float64)
+}
+
+const (
+	wgs84SemiMajorAxis = 6378137.0
+	wgs84Flattening    = 1 / 298.257223563
+	// webMercatorHalfWidth is half the width of the Web Mercator world, the
+	// easting of the antimeridian.
+	webMercatorHalfWidth = math.Pi * wgs84SemiMajorAxis
+	// webMercatorMaxLatitude is the latitude of the top edge of the square
+	// Web Mercator world.
+	webMercatorMaxLatitude = 85.0511287798
+)
+
+type geographicProjection struct{}
+
+func (geographicProjection) ToGeographic(x, y float64) (float64, float64) {
+	return x, y
+}
+
+func (geographicProjection) FromGeographic(lon, lat float64) (float64, float64) {
+	return lon, lat
+}
+
+type webMercatorProjection struct{}
+
+func (webMercatorProjection) ToGeographic(x, y float64) (float64, float64) {
+	lon := x / wgs84SemiMajorAxis * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(y/wgs84SemiMajorAxis)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+func (webMercatorProjection) FromGeographic(lon, lat float64) (float64, float64) {
+	x := wgs84SemiMajorAxis * lon * math.Pi / 180
+	y := wgs84SemiMajorAxis * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360))
+	return x, y
+}
+
+// transverseMercatorProjection uses Krüger's series to the third order in
+// the third flattening, which is accurate to well under a millimetre within
+// a few degrees of the central meridian.
+type transverseMercatorProjection struct {
+	centralMeridian float64
+	scale           float64
+	falseEasting    float64
+	falseNorthing   float64
+	// originNorthing is the northing of the latitude of origin, which is 0
+	// for grids with their origin on the equator.
+	originNorthing float64
+	n              float64
+	radius         float64
+	alpha          [3]float64
+	beta           [3]float64
+	delta          [3]float64
+}
+
+func newTransverseMercator(semiMajorAxis, flattening, centralMeridian, latitudeOfOrigin, scale, falseEasting, falseNorthing float64) *transverseMercatorProjection {
+	n := flattening / (2 - flattening)
+	n2, n3 := n*n, n*n*n
+	tm := &transverseMercatorProjection{
+		centralMeridian: centralMeridian * math.Pi / 180,
+		scale:           scale,
+		falseEasting:    falseEasting,
+		falseNorthing:   falseNorthing,
+		n:               n,
+		radius:          semiMajorAxis / (1 + n) * (1 + n2/4 + n2*n2/64),
+		alpha:           [3]float64{n/2 - 2*n2/3 + 5*n3/16, 13*n2/48 - 3*n3/5, 61 * n3 / 240},
+		beta:            [3]float64{n/2 - 2*n2/3 + 37*n3/96, n2/48 + n3/15, 17 * n3 / 480},
+		delta:           [3]float64{2*n - 2*n2/3 - 2*n3, 7*n2/3 - 8*n3/5, 56 * n3 / 15},
+	}
+	if latitudeOfOrigin != 0 {
+		_, northing := tm.FromGeographic(centralMeridian, latitudeOfOrigin)
+		tm.originNorthing = northing - falseNorthing
+	}
+	return tm
+}
+
+func (tm *transverseMercatorProjection) FromGeographic(lon, lat float64) (float64, float64) {
+	phi := lat * math.Pi / 180
+	lambda := lon*math.Pi/180 - tm.centralMeridian
+	e := 2 * math.Sqrt(tm.n) / (1 + tm.n)
+	sinPhi := math.Sin(phi)
+	t := math.Sinh(math.Atanh(sinPhi) - e*math.Atanh(e*sinPhi))
+	xi := math.Atan2(t, math.Cos(lambda))
+	eta := math.Atanh(math.Sin(lambda) / math.Sqrt(1+t*t))
+
+	easting, northing := eta, xi
+	for j, alpha := range tm.alpha {
+		k := 2 * float64(j+1)
+		easting += alpha * math.Cos(k*xi) * math.Sinh(k*eta)
+		northing += alpha * math.Sin(k*xi) * math.Cosh(k*eta)
+	}
+	return tm.falseEasting + tm.scale*tm.radius*easting, tm.falseNorthing + tm.scale*tm.radius*northing - tm.originNorthing
+}
+
+func (tm *transverseMercatorProjection) ToGeographic(x, y float64) (float64, float64) {
+	xi := (y - tm.falseNorthing + tm.originNorthing) / (tm.scale * tm.radius)
+	eta := (x - tm.falseEasting) / (tm.scale * tm.radius)
+
+	xiPrime, etaPrime := xi, eta
+	for j, beta := range tm.beta {
+		k := 2 * float64(j+1)
+		xiPrime -= beta * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaPrime -= beta * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	chi := math.Asin(math.Sin(xiPrime) / math.Cosh(etaPrime))
+	phi := chi
+	for j, delta := range tm.delta {
+		phi += delta * math.Sin(2*float64(j+1)*chi)
+	}
+	lambda := tm.centralMeridian + math.Atan2(math.Sinh(etaPrime), math.Cos(xiPrime))
+	return lambda * 180 / math.Pi, phi * 180 / math.Pi
+}
+
+// lambertConformalConicProjection is the ellipsoidal Lambert Conformal Conic
+// with two standard parallels, following Snyder's Map Projections: A Working
+// Manual.
+type lambertConformalConicProjection struct {
+	centralMeridian float64
+	falseEasting    float64
+	falseNorthing   float64
+	e               float64
+	n               float64
+	// af is the semi-major axis times Snyder's F, rho0 the radius of the
+	// parallel of the latitude of origin.
+	af   float64
+	rho0 float64
+}
+
+func newLambertConformalConic(semiMajorAxis, flattening, centralMeridian, latitudeOfOrigin, standardParallel1, standardParallel2, falseEasting, falseNorthing float64) *lambertConformalConicProjection {
+	e := math.Sqrt(flattening * (2 - flattening))
+	phi1 := standardParallel1 * math.Pi / 180
+	phi2 := standardParallel2 * math.Pi / 180
+	m1, m2 := lccM(e, phi1), lccM(e, phi2)
+	t1, t2 := lccT(e, phi1), lccT(e, phi2)
+
+	n := math.Sin(phi1)
+	if standardParallel1 != standardParallel2 {
+		n = (math.Log(m1) - math.Log(m2)) / (math.Log(t1) - math.Log(t2))
+	}
+	af := semiMajorAxis * m1 / (n * math.Pow(t1, n))
+	return &lambertConformalConicProjection{
+		centralMeridian: centralMeridian * math.Pi / 180,
+		falseEasting:    falseEasting,
+		falseNorthing:   falseNorthing,
+		e:               e,
+		n:               n,
+		af:              af,
+		rho0:            af * math.Pow(lccT(e, latitudeOfOrigin*math.Pi/180), n),
+	}
+}
+
+func lccM(e, phi float64) float64 {
+	sinPhi := math.Sin(phi)
+	return math.Cos(phi) / math.Sqrt(1-e*e*sinPhi*sinPhi)
+}
+
+func lccT(e, phi float64) float64 {
+	sinPhi := math.Sin(phi)
+	return math.Tan(math.Pi/4-phi/2) / math.Pow((1-e*sinPhi)/(1+e*sinPhi), e/2)
+}
+
+func (lcc *lambertConformalConicProjection) FromGeographic(lon, lat float64) (float64, float64) {
+	rho := lcc.af * math.Pow(lccT(lcc.e, lat*math.Pi/180), lcc.n)
+	theta := lcc.n * (lon*math.Pi/180 - lcc.centralMeridian)
+	return lcc.falseEasting + rho*math.Sin(theta), lcc.falseNorthing + lcc.rho0 - rho*math.Cos(theta)
+}
+
+func (lcc *lambertConformalConicProjection) ToGeographic(x, y float64) (float64, float64) {
+	dx := x - lcc.falseEasting
+	dy := lcc.rho0 - (y - lcc.falseNorthing)
+	rho := math.Copysign(math.Hypot(dx, dy), lcc.n)
+	theta := math.Atan2(dx, dy)
+	if lcc.n < 0 {
+		theta = math.Atan2(-dx, -dy)
+	}
+	t := math.Pow(rho/lcc.af, 1/lcc.n)
+
+	// The latitude is the fixed point of Snyder's equation 7-9.
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 15; i++ {
+		esinPhi := lcc.e * math.Sin(phi)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-esinPhi)/(1+esinPhi), lcc.e/2))
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	lambda := theta/lcc.n + lcc.centralMeridian
+	return lambda * 180 / math.Pi, phi * 180 / math.Pi
+}
+
+// ParseProjection parses a CRS given as an EPSG code, e.g. "EPSG:32633", or
+// as WKT, and returns its projection. See lookupEPSG for the supported codes.
+func ParseProjection(definition string) (Projection, error) {
+	crs, err := ParseCRS(definition)
+	if err != nil {
+		return nil, err
+	}
+	return crs.Projection()
+}
+
+// geographicBounds returns the west, south, east and north edges of a map in
+// degrees.
+func (elevationMap *ElevationMap) geographicBounds(projection Projection) [4]float64 {
+	return elevationMap.transformedBounds(projection.ToGeographic)
+}
+
+// transformedBounds returns the bounding box of the outline of a map
+// transformed to other coordinates. The outline is sampled densely, since
+// its transformed edges are curved.
+func (elevationMap *ElevationMap) transformedBounds(transform func(x, y float64) (float64, float64)) [4]float64 {
+	bounds := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	const samples = 32
+	for i := 0; i <= samples; i++ {
+		f := float64(i) / samples
+		x := elevationMap.MinX + f*elevationMap.GetWidth()
+		y := elevationMap.MinY + f*elevationMap.GetHeight()
+		for _, point := range [][2]float64{{x, elevationMap.MinY}, {x, elevationMap.MaxY}, {elevationMap.MinX, y}, {elevationMap.MaxX, y}} {
+			tx, ty := transform(point[0], point[1])
+			if math.IsNaN(tx) || math.IsInf(tx, 0) || math.IsNaN(ty) || math.IsInf(ty, 0) {
+				continue
+			}
+			bounds[0], bounds[1] = math.Min(bounds[0], tx), math.Min(bounds[1], ty)
+			bounds[2], bounds[3] = math.Max(bounds[2], tx), math.Max(bounds[3], ty)
+		}
+	}
+	return bounds
+}
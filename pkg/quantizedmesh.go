@@ -0,0 +1,467 @@
+package asctools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// quantizedMeshGridSize is the number of sample intervals along each side of
+// a quantized-mesh tile before simplification.
+const quantizedMeshGridSize = 64
+
+// quantizedMeshMaxCoordinate is the largest quantized u, v or height.
+const quantizedMeshMaxCoordinate = 32767
+
+// quantizedMeshLevelZeroError is the geometric error Cesium expects of level
+// 0 terrain tiles, which halves with every level.
+var quantizedMeshLevelZeroError = wgs84SemiMajorAxis * 2 * math.Pi * 0.25 / (65 * 2)
+
+type QuantizedMeshOptions struct {
+	// MaxLevel of -1 is the level at which tile samples are about the size
+	// of map cells. Tiles are written from level 0 up, since Cesium loads
+	// every level on the way down.
+	MaxLevel int
+	// Projection of the map coordinates.
+	Projection Projection
+	// Normals adds oct-encoded vertex normals for lighting.
+	Normals bool
+}
+
+func DefaultQuantizedMeshOptions() QuantizedMeshOptions {
+	return QuantizedMeshOptions{
+		MaxLevel: -1,
+	}
+}
+
+// QuantizedMeshPyramid builds Cesium quantized-mesh-1.0 terrain tiles on the
+// geographic TMS grid, whose level 0 has two tiles, west and east.
+type QuantizedMeshPyramid struct {
+	elevationMap *ElevationMap
+	options      QuantizedMeshOptions
+	// bounds are the west, south, east and north edges of the map in
+	// degrees.
+	bounds [4]float64
+}
+
+type quantizedMeshTile struct {
+	u, v, heightCode []uint16
+	height           []float64
+	indices          []int
+	minHeight        float64
+	maxHeight        float64
+}
+
+func NewQuantizedMeshPyramid(elevationMap *ElevationMap, options QuantizedMeshOptions) (*QuantizedMeshPyramid, error) {
+	if options.Projection == nil {
+		return nil, fmt.Errorf("a CRS is required for terrain tiles")
+	}
+	if options.MaxLevel < -1 {
+		return nil, fmt.Errorf("level must not be negative")
+	}
+
+	pyramid := &QuantizedMeshPyramid{
+		elevationMap: elevationMap,
+		options:      options,
+		bounds:       elevationMap.geographicBounds(options.Projection),
+	}
+	if !(pyramid.bounds[0] < pyramid.bounds[2] && pyramid.bounds[1] < pyramid.bounds[3]) {
+		return nil, fmt.Errorf("map has no geographic extent")
+	}
+
+	if pyramid.options.MaxLevel == -1 {
+		cellSize := elevationMap.CellSize
+		if _, isGeographic := options.Projection.(geographicProjection); !isGeographic {
+			centerLat := (pyramid.bounds[1] + pyramid.bounds[3]) / 2 * math.Pi / 180
+			cellSize = elevationMap.CellSize / (wgs84SemiMajorAxis * math.Pi / 180 * math.Cos(centerLat))
+		}
+		pyramid.options.MaxLevel = max(0, int(math.Ceil(math.Log2(180/(quantizedMeshGridSize*cellSize)))))
+	}
+
+	return pyramid, nil
+}
+
+func (pyramid *QuantizedMeshPyramid) MaxLevel() int {
+	return pyramid.options.MaxLevel
+}
+
+// TileRange returns the first and last column and row, counted from the
+// south, of the tiles that cover the map at a level. Level 0 always has both
+// tiles.
+func (pyramid *QuantizedMeshPyramid) TileRange(level int) (minX, minY, maxX, maxY int) {
+	if level == 0 {
+		return 0, 0, 1, 0
+	}
+	tileDegrees := 180 / math.Pow(2, float64(level))
+	minX = max(0, int(math.Floor((pyramid.bounds[0]+180)/tileDegrees)))
+	minY = max(0, int(math.Floor((pyramid.bounds[1]+90)/tileDegrees)))
+	maxX = min(1<<(level+1)-1, int(math.Ceil((pyramid.bounds[2]+180)/tileDegrees))-1)
+	maxY = min(1<<level-1, int(math.Ceil((pyramid.bounds[3]+90)/tileDegrees))-1)
+	return minX, minY, max(minX, maxX), max(minY, maxY)
+}
+
+// tile samples the map over a tile and simplifies the samples to the
+// geometric error of the level. Samples outside the map or at nodata are at
+// height 0.
+func (pyramid *QuantizedMeshPyramid) tile(level, x, y int) (*quantizedMeshTile, error) {
+	tileDegrees := 180 / math.Pow(2, float64(level))
+	west := -180 + float64(x)*tileDegrees
+	south := -90 + float64(y)*tileDegrees
+
+	const n = quantizedMeshGridSize
+	samples := makeElevationMapWithSize(0, 0, n+1, n+1, 1)
+	for row := 0; row <= n; row++ {
+		lat := south + float64(row)/n*tileDegrees
+		for col := 0; col <= n; col++ {
+			lon := west + float64(col)/n*tileDegrees
+			mapX, mapY := pyramid.options.Projection.FromGeographic(lon, lat)
+			elevation := pyramid.elevationMap.GetElevation(mapX, mapY)
+			if elevation == NodataValue {
+				elevation = 0
+			}
+			samples.SetRowCol(n-row, col, elevation)
+		}
+	}
+	samples.UpdateElevationRange()
+
+	floorElevation := samples.MinElevation - 1
+	builder, err := samples.buildTINSurface(floorElevation, TINOptions{MaxError: quantizedMeshLevelZeroError / math.Pow(2, float64(level))}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Vertices are numbered in the order triangles first use them, as the
+	// high water mark encoding of indices requires.
+	d := builder.d
+	remap := make([]int, len(d.points))
+	for i := range remap {
+		remap[i] = -1
+	}
+	tile := &quantizedMeshTile{minHeight: math.Inf(1), maxHeight: math.Inf(-1)}
+	for _, t := range d.triangles {
+		if t.dead {
+			continue
+		}
+		// Triangles are counter-clockwise seen from above, as the format
+		// requires.
+		for _, vertex := range t.vertices {
+			if remap[vertex] < 0 {
+				remap[vertex] = len(tile.u)
+				p := d.points[vertex]
+				height := builder.z[vertex] + floorElevation
+				tile.u = append(tile.u, uint16(math.Round(p.X/n*quantizedMeshMaxCoordinate)))
+				tile.v = append(tile.v, uint16(math.Round(p.Y/n*quantizedMeshMaxCoordinate)))
+				tile.height = append(tile.height, height)
+				tile.minHeight = math.Min(tile.minHeight, height)
+				tile.maxHeight = math.Max(tile.maxHeight, height)
+			}
+			tile.indices = append(tile.indices, remap[vertex])
+		}
+	}
+
+	heightRange := tile.maxHeight - tile.minHeight
+	tile.heightCode = make([]uint16, len(tile.height))
+	for i, height := range tile.height {
+		if heightRange > 0 {
+			tile.heightCode[i] = uint16(math.Round((height - tile.minHeight) / heightRange * quantizedMeshMaxCoordinate))
+		}
+	}
+
+	return tile, nil
+}
+
+// TileData encodes the tile at level, x and y, with y counted from the
+// south, in the quantized-mesh-1.0 format.
+func (pyramid *QuantizedMeshPyramid) TileData(level, x, y int) ([]byte, error) {
+	tile, err := pyramid.tile(level, x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	tileDegrees := 180 / math.Pow(2, float64(level))
+	west := -180 + float64(x)*tileDegrees
+	south := -90 + float64(y)*tileDegrees
+	positions := make([][3]float64, len(tile.u))
+	for i := range positions {
+		lon := west + float64(tile.u[i])/quantizedMeshMaxCoordinate*tileDegrees
+		lat := south + float64(tile.v[i])/quantizedMeshMaxCoordinate*tileDegrees
+		positions[i] = geodeticToECEF(lon, lat, tile.height[i])
+	}
+
+	center := geodeticToECEF(west+tileDegrees/2, south+tileDegrees/2, (tile.minHeight+tile.maxHeight)/2)
+	radius := 0.0
+	for _, p := range positions {
+		radius = math.Max(radius, vectorLength(vectorSub(p, center)))
+	}
+
+	var buffer bytes.Buffer
+	write := func(data any) {
+		binary.Write(&buffer, binary.LittleEndian, data)
+	}
+	write(center)
+	write([2]float32{float32(tile.minHeight), float32(tile.maxHeight)})
+	write(center)
+	write(radius)
+	write(horizonOcclusionPoint(center, positions))
+
+	write(uint32(len(tile.u)))
+	for _, values := range [][]uint16{tile.u, tile.v, tile.heightCode} {
+		previous := 0
+		for _, value := range values {
+			delta := int(value) - previous
+			write(uint16((delta << 1) ^ (delta >> 31)))
+			previous = int(value)
+		}
+	}
+
+	wide := len(tile.u) > 65536
+	writeIndex := func(index int) {
+		if wide {
+			write(uint32(index))
+		} else {
+			write(uint16(index))
+		}
+	}
+	if wide {
+		for buffer.Len()%4 != 0 {
+			buffer.WriteByte(0)
+		}
+	}
+	write(uint32(len(tile.indices) / 3))
+	highest := 0
+	for _, index := range tile.indices {
+		code := highest - index
+		writeIndex(code)
+		if code == 0 {
+			highest++
+		}
+	}
+
+	// West, south, east and north edge vertices, sorted along the edge.
+	edges := []struct {
+		coordinate []uint16
+		value      uint16
+		along      []uint16
+	}{
+		{tile.u, 0, tile.v},
+		{tile.v, 0, tile.u},
+		{tile.u, quantizedMeshMaxCoordinate, tile.v},
+		{tile.v, quantizedMeshMaxCoordinate, tile.u},
+	}
+	for _, edge := range edges {
+		vertices := []int{}
+		for i, value := range edge.coordinate {
+			if value == edge.value {
+				vertices = append(vertices, i)
+			}
+		}
+		sort.Slice(vertices, func(i, j int) bool { return edge.along[vertices[i]] < edge.along[vertices[j]] })
+		write(uint32(len(vertices)))
+		for _, vertex := range vertices {
+			writeIndex(vertex)
+		}
+	}
+
+	if pyramid.options.Normals {
+		write(uint8(1))
+		write(uint32(2 * len(positions)))
+		for _, normal := range vertexNormals(positions, tile.indices) {
+			write(octEncode(normal))
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+type quantizedMeshTileRange struct {
+	StartX int `json:"startX"`
+	StartY int `json:"startY"`
+	EndX   int `json:"endX"`
+	EndY   int `json:"endY"`
+}
+
+// quantizedMeshLayer is the layer.json Cesium reads to find the tiles.
+type quantizedMeshLayer struct {
+	TileJSON   string                     `json:"tilejson"`
+	Name       string                     `json:"name,omitempty"`
+	Version    string                     `json:"version"`
+	Format     string                     `json:"format"`
+	Scheme     string                     `json:"scheme"`
+	Tiles      []string                   `json:"tiles"`
+	Projection string                     `json:"projection"`
+	Bounds     [4]float64                 `json:"bounds"`
+	MinZoom    int                        `json:"minzoom"`
+	MaxZoom    int                        `json:"maxzoom"`
+	Available  [][]quantizedMeshTileRange `json:"available"`
+	Extensions []string                   `json:"extensions"`
+}
+
+// WritePyramid writes the tiles of levels 0 to the maximum level as
+// dir/{z}/{x}/{y}.terrain and their description as dir/layer.json. It
+// returns the number of tiles written.
+func (pyramid *QuantizedMeshPyramid) WritePyramid(dir, name string) (int, error) {
+	available := [][]quantizedMeshTileRange{}
+
+	written := 0
+	for level := 0; level <= pyramid.options.MaxLevel; level++ {
+		minX, minY, maxX, maxY := pyramid.TileRange(level)
+		available = append(available, []quantizedMeshTileRange{{minX, minY, maxX, maxY}})
+		for x := minX; x <= maxX; x++ {
+			for y := minY; y <= maxY; y++ {
+				data, err := pyramid.TileData(level, x, y)
+				if err != nil {
+					return written, err
+				}
+				path := filepath.Join(dir, fmt.Sprint(level), fmt.Sprint(x), fmt.Sprintf("%d.terrain", y))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return written, err
+				}
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					return written, err
+				}
+				written++
+			}
+		}
+	}
+
+	layer := quantizedMeshLayer{
+		TileJSON:   "2.1.0",
+		Name:       name,
+		Version:    "1.0.0",
+		Format:     "quantized-mesh-1.0",
+		Scheme:     "tms",
+		Tiles:      []string{"{z}/{x}/{y}.terrain?v={version}"},
+		Projection: "EPSG:4326",
+		Bounds:     pyramid.bounds,
+		MinZoom:    0,
+		MaxZoom:    pyramid.options.MaxLevel,
+		Available:  available,
+		Extensions: []string{},
+	}
+	if pyramid.options.Normals {
+		layer.Extensions = append(layer.Extensions, "octvertexnormals")
+	}
+	data, err := json.MarshalIndent(layer, "", "  ")
+	if err != nil {
+		return written, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "layer.json"), append(data, '\n'), 0644); err != nil {
+		return written, err
+	}
+
+	return written, nil
+}
+
+func geodeticToECEF(lon, lat, height float64) [3]float64 {
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	n := wgs84SemiMajorAxis / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return [3]float64{
+		(n + height) * math.Cos(phi) * math.Cos(lambda),
+		(n + height) * math.Cos(phi) * math.Sin(lambda),
+		(n*(1-e2) + height) * math.Sin(phi),
+	}
+}
+
+// horizonOcclusionPoint returns the point, in coordinates scaled by the
+// ellipsoid radii, that is below the horizon only when all positions are,
+// following Cesium's EllipsoidalOccluder.
+func horizonOcclusionPoint(center [3]float64, positions [][3]float64) [3]float64 {
+	polarRadius := wgs84SemiMajorAxis * (1 - wgs84Flattening)
+	scale := func(p [3]float64) [3]float64 {
+		return [3]float64{p[0] / wgs84SemiMajorAxis, p[1] / wgs84SemiMajorAxis, p[2] / polarRadius}
+	}
+	direction := vectorNormalize(scale(center))
+
+	magnitude := 0.0
+	for _, p := range positions {
+		scaled := scale(p)
+		length := vectorLength(scaled)
+		pointDirection := vectorScale(scaled, 1/length)
+		length = math.Max(1, length)
+		cosAlpha := vectorDot(pointDirection, direction)
+		sinAlpha := vectorLength(vectorCross(pointDirection, direction))
+		cosBeta := 1 / length
+		sinBeta := math.Sqrt(length*length-1) * cosBeta
+		denominator := cosAlpha*cosBeta - sinAlpha*sinBeta
+		if denominator <= 0 {
+			// No point on the ray occludes this position, so the tile is
+			// placed far enough out to never be culled.
+			return vectorScale(direction, 1e6)
+		}
+		magnitude = math.Max(magnitude, 1/denominator)
+	}
+	return vectorScale(direction, magnitude)
+}
+
+func vertexNormals(positions [][3]float64, indices []int) [][3]float64 {
+	normals := make([][3]float64, len(positions))
+	for i := 0; i < len(indices); i += 3 {
+		a, b, c := positions[indices[i]], positions[indices[i+1]], positions[indices[i+2]]
+		normal := vectorCross(vectorSub(b, a), vectorSub(c, a))
+		for _, index := range indices[i : i+3] {
+			normals[index] = vectorAdd(normals[index], normal)
+		}
+	}
+	for i := range normals {
+		normals[i] = vectorNormalize(normals[i])
+	}
+	return normals
+}
+
+// octEncode packs a unit vector into two bytes by projecting it onto an
+// octahedron unfolded into a square.
+func octEncode(normal [3]float64) [2]uint8 {
+	sign := func(value float64) float64 {
+		if value < 0 {
+			return -1
+		}
+		return 1
+	}
+	sum := math.Abs(normal[0]) + math.Abs(normal[1]) + math.Abs(normal[2])
+	x, y := normal[0]/sum, normal[1]/sum
+	if normal[2] < 0 {
+		x, y = (1-math.Abs(y))*sign(x), (1-math.Abs(x))*sign(y)
+	}
+	toByte := func(value float64) uint8 {
+		return uint8(math.Round((math.Max(-1, math.Min(1, value))*0.5 + 0.5) * 255))
+	}
+	return [2]uint8{toByte(x), toByte(y)}
+}
+
+func vectorAdd(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func vectorSub(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func vectorScale(a [3]float64, s float64) [3]float64 {
+	return [3]float64{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func vectorDot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func vectorCross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func vectorLength(a [3]float64) float64 {
+	return math.Sqrt(vectorDot(a, a))
+}
+
+func vectorNormalize(a [3]float64) [3]float64 {
+	length := vectorLength(a)
+	if length == 0 {
+		return a
+	}
+	return vectorScale(a, 1/length)
+}
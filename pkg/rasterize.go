@@ -0,0 +1,147 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+type RasterSurface int
+
+const (
+	SurfaceTop RasterSurface = iota
+	SurfaceBottom
+	// SurfaceThickness is the distance between the top and bottom surface.
+	SurfaceThickness
+)
+
+func ParseRasterSurface(value string) (RasterSurface, error) {
+	switch value {
+	case "top", "":
+		return SurfaceTop, nil
+	case "bottom":
+		return SurfaceBottom, nil
+	case "thickness":
+		return SurfaceThickness, nil
+	default:
+		return SurfaceTop, fmt.Errorf("unknown surface: %s", value)
+	}
+}
+
+type RasterizeOptions struct {
+	// OriginX and OriginY are the lower left corner of the grid. When the
+	// grid size is 0, the grid is fitted to the triangles and the origin only
+	// aligns it to whole cells.
+	OriginX, OriginY float64
+	CellSize         float64
+	NumRows, NumCols int
+	Surface          RasterSurface
+}
+
+// GridRasterizeOptions returns options that rasterize onto the grid of an
+// existing map, so that the result can be compared with it cell by cell.
+func GridRasterizeOptions(elevationMap *ElevationMap, surface RasterSurface) RasterizeOptions {
+	return RasterizeOptions{
+		OriginX:  elevationMap.MinX,
+		OriginY:  elevationMap.MinY,
+		CellSize: elevationMap.CellSize,
+		NumRows:  elevationMap.NumRows,
+		NumCols:  elevationMap.NumCols,
+		Surface:  surface,
+	}
+}
+
+// RasterizeTriangles samples triangles, seen from above, at the centre of
+// every grid cell. Cells no triangle covers are nodata.
+func RasterizeTriangles(triangles []Triangle, options RasterizeOptions) (*ElevationMap, error) {
+	if options.CellSize <= 0 {
+		return nil, fmt.Errorf("cell size must be greater than 0")
+	}
+	if options.NumRows < 0 || options.NumCols < 0 {
+		return nil, fmt.Errorf("grid size must not be negative")
+	}
+	if len(triangles) == 0 {
+		return nil, fmt.Errorf("no triangles to rasterize")
+	}
+
+	cellSize := options.CellSize
+	minX, minY := options.OriginX, options.OriginY
+	numRows, numCols := options.NumRows, options.NumCols
+	if numRows == 0 || numCols == 0 {
+		boundsMinX, boundsMinY := math.Inf(1), math.Inf(1)
+		boundsMaxX, boundsMaxY := math.Inf(-1), math.Inf(-1)
+		for _, t := range triangles {
+			for _, v := range []Vector3{t.Vertex1, t.Vertex2, t.Vertex3} {
+				boundsMinX = math.Min(boundsMinX, float64(v.X))
+				boundsMinY = math.Min(boundsMinY, float64(v.Y))
+				boundsMaxX = math.Max(boundsMaxX, float64(v.X))
+				boundsMaxY = math.Max(boundsMaxY, float64(v.Y))
+			}
+		}
+		minX += math.Floor((boundsMinX-minX)/cellSize) * cellSize
+		minY += math.Floor((boundsMinY-minY)/cellSize) * cellSize
+		numCols = max(1, int(math.Ceil((boundsMaxX-minX)/cellSize)))
+		numRows = max(1, int(math.Ceil((boundsMaxY-minY)/cellSize)))
+	}
+
+	top := make([]float64, numRows*numCols)
+	bottom := make([]float64, numRows*numCols)
+	for i := range top {
+		top[i], bottom[i] = math.Inf(-1), math.Inf(1)
+	}
+
+	for _, t := range triangles {
+		x0, y0 := float64(t.Vertex1.X)-minX, float64(t.Vertex1.Y)-minY
+		x1, y1 := float64(t.Vertex2.X)-minX, float64(t.Vertex2.Y)-minY
+		x2, y2 := float64(t.Vertex3.X)-minX, float64(t.Vertex3.Y)-minY
+		area := (x1-x0)*(y2-y0) - (x2-x0)*(y1-y0)
+		if area == 0 {
+			continue
+		}
+
+		// Rows are counted from the bottom, cell centres are half a cell in.
+		colStart := max(0, int(math.Ceil(math.Min(x0, math.Min(x1, x2))/cellSize-0.5)))
+		colEnd := min(numCols-1, int(math.Floor(math.Max(x0, math.Max(x1, x2))/cellSize-0.5)))
+		rowStart := max(0, int(math.Ceil(math.Min(y0, math.Min(y1, y2))/cellSize-0.5)))
+		rowEnd := min(numRows-1, int(math.Floor(math.Max(y0, math.Max(y1, y2))/cellSize-0.5)))
+
+		for row := rowStart; row <= rowEnd; row++ {
+			y := (float64(row) + 0.5) * cellSize
+			for col := colStart; col <= colEnd; col++ {
+				x := (float64(col) + 0.5) * cellSize
+				w0 := ((x1-x)*(y2-y) - (x2-x)*(y1-y)) / area
+				w1 := ((x2-x)*(y0-y) - (x0-x)*(y2-y)) / area
+				w2 := 1 - w0 - w1
+				// A small tolerance keeps cells on shared edges from falling
+				// through the gap between two triangles.
+				if w0 < -1e-9 || w1 < -1e-9 || w2 < -1e-9 {
+					continue
+				}
+				z := w0*float64(t.Vertex1.Z) + w1*float64(t.Vertex2.Z) + w2*float64(t.Vertex3.Z)
+				i := row*numCols + col
+				top[i] = math.Max(top[i], z)
+				bottom[i] = math.Min(bottom[i], z)
+			}
+		}
+	}
+
+	result := makeElevationMapWithSize(minX, minY, numRows, numCols, cellSize)
+	for row := 0; row < numRows; row++ {
+		for col := 0; col < numCols; col++ {
+			i := row*numCols + col
+			if math.IsInf(top[i], -1) {
+				continue
+			}
+			value := top[i]
+			switch options.Surface {
+			case SurfaceBottom:
+				value = bottom[i]
+			case SurfaceThickness:
+				value = top[i] - bottom[i]
+			}
+			result.SetRowCol(numRows-1-row, col, value)
+		}
+	}
+	result.UpdateElevationRange()
+
+	return result, nil
+}
@@ -2,7 +2,13 @@ package asctools
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
 	"math"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -17,44 +23,39 @@ type Triangle struct {
 	Vertex3 Vector3
 }
 
-func (elevationMap *ElevationMap) WriteSTL(writer *bufio.Writer, floorElevation float64) error {
-	header := make([]byte, 80)
-	copy(header, []byte("STL binary file generated by asctools"))
-
-	numTriangles := countTriangles(elevationMap)
-
-	writer.Write(header)
+func (elevationMap *ElevationMap) WriteSTL(writer *bufio.Writer, options MeshOptions) error {
+	mesh, err := elevationMap.BuildMesh(options)
+	if err != nil {
+		return err
+	}
+	return mesh.WriteSTL(writer)
+}
 
-	writer.Write([]byte{
-		byte(numTriangles),
-		byte(numTriangles >> 8),
-		byte(numTriangles >> 16),
-		byte(numTriangles >> 24),
-	})
+func writeSTLTriangle(writer *bufio.Writer, t Triangle) {
+	writeFloat32(writer, t.Normal.X)
+	writeFloat32(writer, t.Normal.Y)
+	writeFloat32(writer, t.Normal.Z)
 
-	writeTriangle := func(t Triangle) {
-		writeFloat32(writer, t.Normal.X)
-		writeFloat32(writer, t.Normal.Y)
-		writeFloat32(writer, t.Normal.Z)
+	writeFloat32(writer, t.Vertex1.X)
+	writeFloat32(writer, t.Vertex1.Y)
+	writeFloat32(writer, t.Vertex1.Z)
 
-		writeFloat32(writer, t.Vertex1.X)
-		writeFloat32(writer, t.Vertex1.Y)
-		writeFloat32(writer, t.Vertex1.Z)
+	writeFloat32(writer, t.Vertex2.X)
+	writeFloat32(writer, t.Vertex2.Y)
+	writeFloat32(writer, t.Vertex2.Z)
 
-		writeFloat32(writer, t.Vertex2.X)
-		writeFloat32(writer, t.Vertex2.Y)
-		writeFloat32(writer, t.Vertex2.Z)
+	writeFloat32(writer, t.Vertex3.X)
+	writeFloat32(writer, t.Vertex3.Y)
+	writeFloat32(writer, t.Vertex3.Z)
 
-		writeFloat32(writer, t.Vertex3.X)
-		writeFloat32(writer, t.Vertex3.Y)
-		writeFloat32(writer, t.Vertex3.Z)
+	writer.Write([]byte{0, 0})
+}
 
-		writer.Write([]byte{0, 0})
+func (elevationMap *ElevationMap) FloorElevation(floorElevation, floorMargin float64) float64 {
+	if floorElevation == 0 && floorMargin > 0 {
+		return elevationMap.MinElevation - floorMargin
 	}
-
-	generateAndWriteTriangles(elevationMap, floorElevation, writeTriangle)
-
-	return writer.Flush()
+	return floorElevation
 }
 
 func writeFloat32(writer *bufio.Writer, f float32) {
@@ -67,124 +68,6 @@ func writeFloat32(writer *bufio.Writer, f float32) {
 	})
 }
 
-func countTriangles(elevationMap *ElevationMap) int {
-	rows := elevationMap.NumRows
-	cols := elevationMap.NumCols
-
-	count := (rows - 1) * (cols - 1) * 2
-	count += 4 * (rows + cols - 2)
-	count += 2
-
-	return count
-}
-
-func generateAndWriteTriangles(elevationMap *ElevationMap, floorElevation float64, writeTriangle func(Triangle)) {
-	corner1 := Vector3{0, 0, 0}
-	corner2 := Vector3{float32(elevationMap.GetWidth()), 0, 0}
-	corner3 := Vector3{0, float32(elevationMap.GetHeight()), 0}
-	corner4 := Vector3{float32(elevationMap.GetWidth()), float32(elevationMap.GetHeight()), 0}
-
-	writeTriangle(makeTriangle(corner1, corner3, corner2))
-	writeTriangle(makeTriangle(corner2, corner3, corner4))
-
-	epsilon := 0.0001
-
-	for row := 0; row < elevationMap.NumRows-1; row++ {
-		for col := 0; col < elevationMap.NumCols-1; col++ {
-			e1 := elevationMap.GetRowCol(row, col, true)
-			e2 := elevationMap.GetRowCol(row, col+1, true)
-			e3 := elevationMap.GetRowCol(row+1, col, true)
-			e4 := elevationMap.GetRowCol(row+1, col+1, true)
-
-			if e1 == NodataValue {
-				e1 = floorElevation + epsilon
-			} else {
-				e1 -= floorElevation
-			}
-
-			if e2 == NodataValue {
-				e2 = floorElevation
-			} else {
-				e2 -= floorElevation
-			}
-
-			if e3 == NodataValue {
-				e3 = floorElevation
-			} else {
-				e3 -= floorElevation
-			}
-
-			if e4 == NodataValue {
-				e4 = floorElevation
-			} else {
-				e4 -= floorElevation
-			}
-
-			v1 := Vector3{float32(col), float32(row), float32(e1)}
-			v2 := Vector3{float32(col + 1), float32(row), float32(e2)}
-			v3 := Vector3{float32(col), float32(row + 1), float32(e3)}
-			v4 := Vector3{float32(col + 1), float32(row + 1), float32(e4)}
-
-			v1.X *= float32(elevationMap.CellSize)
-			v1.Y *= float32(elevationMap.CellSize)
-			v2.X *= float32(elevationMap.CellSize)
-			v2.Y *= float32(elevationMap.CellSize)
-			v3.X *= float32(elevationMap.CellSize)
-			v3.Y *= float32(elevationMap.CellSize)
-			v4.X *= float32(elevationMap.CellSize)
-			v4.Y *= float32(elevationMap.CellSize)
-
-			if e3 < 0 || e2 < 0 {
-				if v1.Z >= 0 && v4.Z >= 0 && v3.Z >= 0 {
-					writeTriangle(makeTriangle(v1, v4, v3))
-				}
-				if v1.Z >= 0 && v2.Z >= 0 && v4.Z >= 0 {
-					writeTriangle(makeTriangle(v1, v2, v4))
-				}
-			} else {
-				if v1.Z >= 0 && v2.Z >= 0 && v3.Z >= 0 {
-					writeTriangle(makeTriangle(v1, v2, v3))
-				}
-				if v2.Z >= 0 && v4.Z >= 0 && v3.Z >= 0 {
-					writeTriangle(makeTriangle(v2, v4, v3))
-				}
-			}
-
-			if (e1 >= 0 && (col == 0 || elevationMap.GetRowCol(row, col-1, true) < floorElevation)) && (e3 >= 0) && (col == 0 || elevationMap.GetRowCol(row+1, col-1, true) < floorElevation) {
-				writeWall(v1, v3, writeTriangle)
-			}
-
-			if (e2 >= 0 && (col == elevationMap.NumCols-2 || elevationMap.GetRowCol(row, col+2, true) < floorElevation)) && (e4 >= 0) && (col == elevationMap.NumCols-2 || elevationMap.GetRowCol(row+1, col+2, true) < floorElevation) {
-				writeWall(v4, v2, writeTriangle)
-			}
-
-			if (e1 >= 0 && (row == 0 || elevationMap.GetRowCol(row-1, col, true) < floorElevation)) && (e2 >= 0) && (row == 0 || elevationMap.GetRowCol(row-1, col+1, true) < floorElevation) {
-				writeWall(v2, v1, writeTriangle)
-			}
-
-			if (e3 >= 0 && (row == elevationMap.NumRows-2 || elevationMap.GetRowCol(row+2, col, true) < floorElevation)) && (e4 >= 0) && (row == elevationMap.NumRows-2 || elevationMap.GetRowCol(row+2, col+1, true) < floorElevation) {
-				writeWall(v3, v4, writeTriangle)
-			}
-
-			if e1 >= 0 && e4 < 0 && e2 >= 0 && e3 >= 0 {
-				writeWall(v3, v2, writeTriangle)
-			}
-
-			if e1 < 0 && e4 >= 0 && e2 >= 0 && e3 >= 0 {
-				writeWall(v2, v3, writeTriangle)
-			}
-
-			if e2 >= 0 && e3 < 0 && e1 >= 0 && e4 >= 0 {
-				writeWall(v1, v4, writeTriangle)
-			}
-
-			if e2 < 0 && e3 >= 0 && e1 >= 0 && e4 >= 0 {
-				writeWall(v4, v1, writeTriangle)
-			}
-		}
-	}
-}
-
 func makeTriangle(v1, v2, v3 Vector3) Triangle {
 	return Triangle{
 		Normal:  calculateNormal(v1, v2, v3),
@@ -194,14 +77,6 @@ func makeTriangle(v1, v2, v3 Vector3) Triangle {
 	}
 }
 
-func writeWall(v1, v2 Vector3, writeTriangle func(Triangle)) {
-	v1floor := Vector3{v1.X, v1.Y, 0}
-	v2floor := Vector3{v2.X, v2.Y, 0}
-
-	writeTriangle(makeTriangle(v1, v2, v1floor))
-	writeTriangle(makeTriangle(v2, v2floor, v1floor))
-}
-
 func calculateNormal(p1, p2, p3 Vector3) Vector3 {
 	edge1 := Vector3{
 		X: p2.X - p1.X,
@@ -231,3 +106,107 @@ func calculateNormal(p1, p2, p3 Vector3) Vector3 {
 
 	return normal
 }
+
+// ReadSTL reads binary and ASCII STL files.
+func ReadSTL(reader io.Reader) ([]Triangle, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read STL: %v", err)
+	}
+
+	if len(data) >= 84 {
+		numTriangles := int(binary.LittleEndian.Uint32(data[80:84]))
+		if len(data) == 84+50*numTriangles {
+			return readBinarySTL(data[84:], numTriangles), nil
+		}
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return readASCIISTL(data)
+	}
+
+	return nil, fmt.Errorf("file is neither a binary STL with a matching triangle count nor an ASCII STL")
+}
+
+func readBinarySTL(data []byte, numTriangles int) []Triangle {
+	readVector := func(offset int) Vector3 {
+		return Vector3{
+			X: math.Float32frombits(binary.LittleEndian.Uint32(data[offset:])),
+			Y: math.Float32frombits(binary.LittleEndian.Uint32(data[offset+4:])),
+			Z: math.Float32frombits(binary.LittleEndian.Uint32(data[offset+8:])),
+		}
+	}
+
+	triangles := make([]Triangle, numTriangles)
+	for i := range triangles {
+		offset := i * 50
+		triangles[i] = Triangle{
+			Normal:  readVector(offset),
+			Vertex1: readVector(offset + 12),
+			Vertex2: readVector(offset + 24),
+			Vertex3: readVector(offset + 36),
+		}
+	}
+	return triangles
+}
+
+func readASCIISTL(data []byte) ([]Triangle, error) {
+	fields := strings.Fields(string(data))
+
+	parseVector := func(i int) (Vector3, error) {
+		if i+3 > len(fields) {
+			return Vector3{}, fmt.Errorf("unexpected end of file")
+		}
+		var v [3]float32
+		for j := range v {
+			f, err := strconv.ParseFloat(fields[i+j], 32)
+			if err != nil {
+				return Vector3{}, fmt.Errorf("invalid number %s", fields[i+j])
+			}
+			v[j] = float32(f)
+		}
+		return Vector3{v[0], v[1], v[2]}, nil
+	}
+
+	triangles := []Triangle{}
+	var current Triangle
+	numVertices := 0
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "facet":
+			if i+1 < len(fields) && fields[i+1] == "normal" {
+				normal, err := parseVector(i + 2)
+				if err != nil {
+					return nil, err
+				}
+				current = Triangle{Normal: normal}
+				numVertices = 0
+				i += 4
+			}
+		case "vertex":
+			v, err := parseVector(i + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch numVertices {
+			case 0:
+				current.Vertex1 = v
+			case 1:
+				current.Vertex2 = v
+			case 2:
+				current.Vertex3 = v
+			default:
+				return nil, fmt.Errorf("facet with more than 3 vertices")
+			}
+			numVertices++
+			i += 3
+		case "endfacet":
+			if numVertices != 3 {
+				return nil, fmt.Errorf("facet with %d vertices", numVertices)
+			}
+			triangles = append(triangles, current)
+		}
+	}
+
+	return triangles, nil
+}
@@ -0,0 +1,118 @@
+package asctools
+
+import (
+	"math"
+	"unicode"
+)
+
+// Glyphs are polylines on a grid 4 units wide and 6 units tall, with the
+// baseline at 0. A zero length segment is a dot. Lower case letters
+// are drawn as capitals.
+const (
+	glyphWidth   = 4.0
+	glyphHeight  = 6.0
+	glyphAdvance = 6.0
+)
+
+var strokeGlyphs = map[rune][][]float64{
+	'0':  {{1, 0, 3, 0, 4, 1, 4, 5, 3, 6, 1, 6, 0, 5, 0, 1, 1, 0}, {4, 5, 0, 1}},
+	'1':  {{1, 5, 2, 6, 2, 0}, {1, 0, 3, 0}},
+	'2':  {{0, 5, 1, 6, 3, 6, 4, 5, 4, 4, 0, 0, 4, 0}},
+	'3':  {{0, 5, 1, 6, 3, 6, 4, 5, 4, 4, 3, 3, 1, 3}, {3, 3, 4, 2, 4, 1, 3, 0, 1, 0, 0, 1}},
+	'4':  {{3, 0, 3, 6, 0, 2, 4, 2}},
+	'5':  {{4, 6, 0, 6, 0, 3, 3, 3, 4, 2, 4, 1, 3, 0, 0, 0}},
+	'6':  {{4, 5, 3, 6, 1, 6, 0, 5, 0, 1, 1, 0, 3, 0, 4, 1, 4, 2, 3, 3, 0, 3}},
+	'7':  {{0, 6, 4, 6, 1, 0}},
+	'8':  {{1, 3, 0, 4, 0, 5, 1, 6, 3, 6, 4, 5, 4, 4, 3, 3, 1, 3, 0, 2, 0, 1, 1, 0, 3, 0, 4, 1, 4, 2, 3, 3}},
+	'9':  {{4, 3, 1, 3, 0, 4, 0, 5, 1, 6, 3, 6, 4, 5, 4, 1, 3, 0, 1, 0, 0, 1}},
+	'A':  {{0, 0, 0, 4, 2, 6, 4, 4, 4, 0}, {0, 3, 4, 3}},
+	'B':  {{0, 3, 3, 3, 4, 4, 4, 5, 3, 6, 0, 6, 0, 0, 3, 0, 4, 1, 4, 2, 3, 3}},
+	'C':  {{4, 5, 3, 6, 1, 6, 0, 5, 0, 1, 1, 0, 3, 0, 4, 1}},
+	'D':  {{0, 0, 0, 6, 2, 6, 4, 4, 4, 2, 2, 0, 0, 0}},
+	'E':  {{4, 6, 0, 6, 0, 0, 4, 0}, {0, 3, 3, 3}},
+	'F':  {{4, 6, 0, 6, 0, 0}, {0, 3, 3, 3}},
+	'G':  {{4, 5, 3, 6, 1, 6, 0, 5, 0, 1, 1, 0, 3, 0, 4, 1, 4, 3, 2, 3}},
+	'H':  {{0, 0, 0, 6}, {4, 0, 4, 6}, {0, 3, 4, 3}},
+	'I':  {{1, 6, 3, 6}, {2, 6, 2, 0}, {1, 0, 3, 0}},
+	'J':  {{4, 6, 4, 1, 3, 0, 1, 0, 0, 1}},
+	'K':  {{0, 0, 0, 6}, {4, 6, 0, 2}, {1, 3, 4, 0}},
+	'L':  {{0, 6, 0, 0, 4, 0}},
+	'M':  {{0, 0, 0, 6, 2, 3, 4, 6, 4, 0}},
+	'N':  {{0, 0, 0, 6, 4, 0, 4, 6}},
+	'O':  {{1, 0, 3, 0, 4, 1, 4, 5, 3, 6, 1, 6, 0, 5, 0, 1, 1, 0}},
+	'P':  {{0, 0, 0, 6, 3, 6, 4, 5, 4, 4, 3, 3, 0, 3}},
+	'Q':  {{1, 0, 3, 0, 4, 1, 4, 5, 3, 6, 1, 6, 0, 5, 0, 1, 1, 0}, {2, 2, 4, 0}},
+	'R':  {{0, 0, 0, 6, 3, 6, 4, 5, 4, 4, 3, 3, 0, 3}, {2, 3, 4, 0}},
+	'S':  {{4, 5, 3, 6, 1, 6, 0, 5, 0, 4, 1, 3, 3, 3, 4, 2, 4, 1, 3, 0, 1, 0, 0, 1}},
+	'T':  {{0, 6, 4, 6}, {2, 6, 2, 0}},
+	'U':  {{0, 6, 0, 1, 1, 0, 3, 0, 4, 1, 4, 6}},
+	'V':  {{0, 6, 2, 0, 4, 6}},
+	'W':  {{0, 6, 1, 0, 2, 3, 3, 0, 4, 6}},
+	'X':  {{0, 6, 4, 0}, {0, 0, 4, 6}},
+	'Y':  {{0, 6, 2, 3, 4, 6}, {2, 3, 2, 0}},
+	'Z':  {{0, 6, 4, 6, 0, 0, 4, 0}},
+	'.':  {{2, 0, 2, 0}},
+	',':  {{2, 0.5, 1, -1}},
+	':':  {{2, 1, 2, 1}, {2, 4, 2, 4}},
+	';':  {{2, 4, 2, 4}, {2, 1, 1, -1}},
+	'-':  {{1, 3, 3, 3}},
+	'+':  {{0, 3, 4, 3}, {2, 1, 2, 5}},
+	'=':  {{0, 2, 4, 2}, {0, 4, 4, 4}},
+	'_':  {{0, 0, 4, 0}},
+	'/':  {{0, 0, 4, 6}},
+	'#':  {{1, 0, 1, 6}, {3, 0, 3, 6}, {0, 2, 4, 2}, {0, 4, 4, 4}},
+	'!':  {{2, 6, 2, 2}, {2, 0, 2, 0}},
+	'?':  {{0, 5, 1, 6, 3, 6, 4, 5, 4, 4, 2, 3, 2, 2}, {2, 0, 2, 0}},
+	'(':  {{3, 6, 2, 5, 1.5, 3, 2, 1, 3, 0}},
+	')':  {{1, 6, 2, 5, 2.5, 3, 2, 1, 1, 0}},
+	'\'': {{2, 6, 2, 4.5}},
+	'"':  {{1, 6, 1, 4.5}, {3, 6, 3, 4.5}},
+	'°':  {{1, 5, 2, 6, 3, 5, 2, 4, 1, 5}},
+}
+
+type strokeSegment struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// layoutText returns the strokes of a single line of text with the given
+// cap height and its lower left corner at the origin, and the text width.
+// Characters without a glyph are left as spaces.
+func layoutText(text string, height float64) ([]strokeSegment, float64) {
+	unit := height / glyphHeight
+	segments := []strokeSegment{}
+	x := 0.0
+	numChars := 0
+	for _, r := range text {
+		for _, line := range strokeGlyphs[unicode.ToUpper(r)] {
+			for i := 0; i+3 < len(line); i += 2 {
+				segments = append(segments, strokeSegment{
+					X0: (x + line[i]) * unit,
+					Y0: line[i+1] * unit,
+					X1: (x + line[i+2]) * unit,
+					Y1: line[i+3] * unit,
+				})
+			}
+		}
+		x += glyphAdvance
+		numChars++
+	}
+	if numChars == 0 {
+		return segments, 0
+	}
+	return segments, (x - glyphAdvance + glyphWidth) * unit
+}
+
+func (segment strokeSegment) distance(x, y float64) float64 {
+	dx, dy := segment.X1-segment.X0, segment.Y1-segment.Y0
+	t := 0.0
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, ((x-segment.X0)*dx+(y-segment.Y0)*dy)/lengthSquared))
+	}
+	return math.Hypot(x-(segment.X0+t*dx), y-(segment.Y0+t*dy))
+}
+
+func (segment strokeSegment) transform(fn func(x, y float64) (float64, float64)) strokeSegment {
+	x0, y0 := fn(segment.X0, segment.Y0)
+	x1, y1 := fn(segment.X1, segment.Y1)
+	return strokeSegment{x0, y0, x1, y1}
+}
@@ -0,0 +1,124 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+type SurfaceMode int
+
+const (
+	// ModeRelief models the terrain itself.
+	ModeRelief SurfaceMode = iota
+	// ModeLithophane models a flat plate whose thickness follows the darkness
+	// of a hillshade, to be lit from behind.
+	ModeLithophane
+	// ModeMould models a block with the terrain as a cavity, mirrored so that
+	// a cast taken from it reads the right way round.
+	ModeMould
+)
+
+func ParseSurfaceMode(value string) (SurfaceMode, error) {
+	switch value {
+	case "relief", "":
+		return ModeRelief, nil
+	case "lithophane":
+		return ModeLithophane, nil
+	case "mould", "mold":
+		return ModeMould, nil
+	default:
+		return ModeRelief, fmt.Errorf("unknown surface mode: %s", value)
+	}
+}
+
+// surfaceMap returns the height field a lithophane or mould is built from,
+// with heights in model units, and the frame that places it.
+func (elevationMap *ElevationMap) surfaceMap(frame modelFrame, options MeshOptions) (*ElevationMap, modelFrame, error) {
+	minThickness, maxThickness := options.MinThickness, options.MaxThickness
+	if minThickness < 0 || maxThickness < 0 {
+		return nil, modelFrame{}, fmt.Errorf("thickness must not be negative")
+	}
+	if elevationMap.MinElevation > elevationMap.MaxElevation {
+		return nil, modelFrame{}, fmt.Errorf("map has no valid elevations")
+	}
+
+	var surface *ElevationMap
+	switch options.Mode {
+	case ModeLithophane:
+		if minThickness == 0 {
+			minThickness = 0.8
+		}
+		if maxThickness == 0 {
+			maxThickness = 3
+		}
+		if maxThickness <= minThickness {
+			return nil, modelFrame{}, fmt.Errorf("maximum thickness must be greater than the minimum thickness")
+		}
+		surface = elevationMap.lithophaneSurface(minThickness, maxThickness, options.VerticalExaggeration)
+	case ModeMould:
+		if minThickness == 0 {
+			minThickness = 2
+		}
+		var padding int
+		surface, padding = elevationMap.mouldSurface(frame, minThickness, maxThickness)
+		if surface == nil {
+			return nil, modelFrame{}, fmt.Errorf("maximum thickness must leave room for the relief, more than %.4g", minThickness+(elevationMap.MaxElevation-elevationMap.MinElevation)*frame.zScale)
+		}
+		frame.offsetX -= float64(padding) * elevationMap.CellSize
+		frame.offsetY -= float64(padding) * elevationMap.CellSize
+	default:
+		return nil, modelFrame{}, fmt.Errorf("unknown surface mode")
+	}
+
+	frame.floorElevation = 0
+	frame.zScale = 1
+	return surface, frame, nil
+}
+
+// lithophaneSurface maps the brightest cells to the minimum thickness and
+// the darkest to the maximum. Nodata cells are dark.
+func (elevationMap *ElevationMap) lithophaneSurface(minThickness, maxThickness, zFactor float64) *ElevationMap {
+	shade := elevationMap.Hillshade(defaultHillshadeAzimuth, defaultHillshadeAltitude, zFactor)
+	for i, value := range shade.Data {
+		brightness := 0.0
+		if value != NodataValue {
+			brightness = float64(value)
+		}
+		shade.Data[i] = float32(maxThickness - brightness*(maxThickness-minThickness))
+	}
+	shade.UpdateElevationRange()
+	return shade
+}
+
+// mouldSurface inverts the terrain so that its highest point lies
+// minThickness above the base, mirrored left to right, and surrounds it
+// with a solid rim at maxThickness. The rim also fills nodata cells. A
+// maxThickness of 0 leaves minThickness of material above the lowest point.
+// It returns nil if maxThickness is too small for the relief.
+func (elevationMap *ElevationMap) mouldSurface(frame modelFrame, minThickness, maxThickness float64) (*ElevationMap, int) {
+	relief := (elevationMap.MaxElevation - elevationMap.MinElevation) * frame.zScale
+	if maxThickness == 0 {
+		maxThickness = 2*minThickness + relief
+	}
+	if maxThickness <= minThickness+relief {
+		return nil, 0
+	}
+
+	padding := max(1, int(math.Ceil(minThickness/(elevationMap.CellSize*frame.scale))))
+	numRows := elevationMap.NumRows + 2*padding
+	numCols := elevationMap.NumCols + 2*padding
+	surface := makeElevationMapWithSize(elevationMap.MinX-float64(padding)*elevationMap.CellSize, elevationMap.MinY-float64(padding)*elevationMap.CellSize, numRows, numCols, elevationMap.CellSize)
+
+	for row := 0; row < numRows; row++ {
+		for col := 0; col < numCols; col++ {
+			value := elevationMap.GetRowCol(row-padding, elevationMap.NumCols-1-(col-padding), false)
+			height := maxThickness
+			if value != NodataValue {
+				height = minThickness + (elevationMap.MaxElevation-value)*frame.zScale
+			}
+			surface.SetRowCol(row, col, height)
+		}
+	}
+	surface.UpdateElevationRange()
+	return surface, padding
+}
@@ -0,0 +1,338 @@
+package asctools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type TileScheme int
+
+const (
+	// SchemeXYZ numbers tile rows from the north, as Leaflet and most web
+	// maps expect.
+	SchemeXYZ TileScheme = iota
+	// SchemeTMS numbers tile rows from the south.
+	SchemeTMS
+)
+
+func ParseTileScheme(value string) (TileScheme, error) {
+	switch value {
+	case "xyz", "":
+		return SchemeXYZ, nil
+	case "tms":
+		return SchemeTMS, nil
+	default:
+		return SchemeXYZ, fmt.Errorf("unknown tile scheme: %s", value)
+	}
+}
+
+func (scheme TileScheme) String() string {
+	if scheme == SchemeTMS {
+		return "tms"
+	}
+	return "xyz"
+}
+
+// TileStyle selects how tiles show elevations.
+type TileStyle struct {
+	Ramp ColorRamp
+	// Encoding packs elevations into the tiles instead of colouring them,
+	// for raster DEM sources of web maps.
+	Encoding ElevationEncoding
+	// Hillshade darkens the ramp colours by the illumination of the terrain
+	// lit from the north west.
+	Hillshade bool
+}
+
+type TilePyramidOptions struct {
+	TileStyle
+	MinZoom int
+	// MaxZoom of -1 is the zoom at which tile pixels are about the size of
+	// map cells.
+	MaxZoom  int
+	Scheme   TileScheme
+	TileSize int
+	// Projection of the map coordinates. Tiles use the Web Mercator grid of
+	// web maps when it is set, and otherwise a local grid in map units whose
+	// zoom 0 tile covers the whole map, anchored at its north west corner.
+	Projection Projection
+}
+
+func DefaultTilePyramidOptions() TilePyramidOptions {
+	return TilePyramidOptions{
+		TileStyle: TileStyle{Ramp: RampTerrain},
+		MaxZoom:   -1,
+		TileSize:  256,
+	}
+}
+
+// TileRenderer renders the tiles of a pyramid on demand.
+type TileRenderer struct {
+	elevationMap *ElevationMap
+	options      TilePyramidOptions
+	// bounds are the west, south, east and north edges of the map in tile
+	// grid coordinates, Web Mercator metres or map units.
+	bounds [4]float64
+	// geographicBounds are the bounds in longitude and latitude for Web
+	// Mercator tiles, and the same as bounds for local tiles.
+	geographicBounds [4]float64
+	nativeZoom       int
+
+	shadeOnce sync.Once
+	shade     *ElevationMap
+}
+
+func NewTileRenderer(elevationMap *ElevationMap, options TilePyramidOptions) (*TileRenderer, error) {
+	if options.TileSize < 1 {
+		return nil, fmt.Errorf("tile size must be greater than or equal to 1")
+	}
+	if options.MinZoom < 0 || options.MaxZoom < -1 {
+		return nil, fmt.Errorf("zoom levels must not be negative")
+	}
+
+	renderer := &TileRenderer{elevationMap: elevationMap, options: options}
+	tileSize := float64(options.TileSize)
+	if options.Projection == nil {
+		renderer.bounds = [4]float64{elevationMap.MinX, elevationMap.MinY, elevationMap.MaxX, elevationMap.MaxY}
+		renderer.geographicBounds = renderer.bounds
+		cells := float64(max(elevationMap.NumRows, elevationMap.NumCols))
+		renderer.nativeZoom = max(0, int(math.Ceil(math.Log2(cells/tileSize))))
+	} else {
+		geographicBounds := elevationMap.geographicBounds(options.Projection)
+		lonMin, lonMax := geographicBounds[0], geographicBounds[2]
+		latMin := math.Max(-webMercatorMaxLatitude, geographicBounds[1])
+		latMax := math.Min(webMercatorMaxLatitude, geographicBounds[3])
+		// Web Mercator is monotonic in longitude and latitude, so the corners
+		// give the bounds.
+		west, south := webMercatorProjection{}.FromGeographic(lonMin, latMin)
+		east, north := webMercatorProjection{}.FromGeographic(lonMax, latMax)
+		if !(west < east && south < north) {
+			return nil, fmt.Errorf("map does not project onto the Web Mercator grid")
+		}
+		renderer.bounds = [4]float64{west, south, east, north}
+		renderer.geographicBounds = [4]float64{lonMin, latMin, lonMax, latMax}
+
+		// Web Mercator stretches distances by 1/cos(latitude), so cells are
+		// compared with tile pixels at the centre of the map.
+		centerLat := (latMin + latMax) / 2 * math.Pi / 180
+		cellSize := elevationMap.CellSize / math.Cos(centerLat)
+		if _, isGeographic := options.Projection.(geographicProjection); isGeographic {
+			cellSize = elevationMap.CellSize * math.Pi / 180 * wgs84SemiMajorAxis
+		}
+		renderer.nativeZoom = max(0, int(math.Ceil(math.Log2(2*webMercatorHalfWidth/(tileSize*cellSize)))))
+	}
+
+	if renderer.options.MaxZoom == -1 {
+		renderer.options.MaxZoom = max(renderer.nativeZoom, options.MinZoom)
+	}
+	if renderer.options.MaxZoom < renderer.options.MinZoom {
+		return nil, fmt.Errorf("maximum zoom must be greater than or equal to the minimum zoom")
+	}
+
+	return renderer, nil
+}
+
+func (renderer *TileRenderer) MinZoom() int {
+	return renderer.options.MinZoom
+}
+
+func (renderer *TileRenderer) MaxZoom() int {
+	return renderer.options.MaxZoom
+}
+
+// resolution returns the size of a tile pixel at zoom z.
+func (renderer *TileRenderer) resolution(z int) float64 {
+	if renderer.options.Projection == nil {
+		return renderer.elevationMap.CellSize * math.Pow(2, float64(renderer.nativeZoom-z))
+	}
+	return 2 * webMercatorHalfWidth / (float64(renderer.options.TileSize) * math.Pow(2, float64(z)))
+}
+
+// origin returns the north west corner of tile 0, 0.
+func (renderer *TileRenderer) origin() (float64, float64) {
+	if renderer.options.Projection == nil {
+		return renderer.elevationMap.MinX, renderer.elevationMap.MaxY
+	}
+	return -webMercatorHalfWidth, webMercatorHalfWidth
+}
+
+// TileRange returns the first and last column and row of the tiles that
+// cover the map at zoom z, with rows numbered from the north.
+func (renderer *TileRenderer) TileRange(z int) (minX, minY, maxX, maxY int) {
+	tileExtent := renderer.resolution(z) * float64(renderer.options.TileSize)
+	originX, originY := renderer.origin()
+	minX = int(math.Floor((renderer.bounds[0] - originX) / tileExtent))
+	maxX = int(math.Ceil((renderer.bounds[2]-originX)/tileExtent)) - 1
+	minY = int(math.Floor((originY - renderer.bounds[3]) / tileExtent))
+	maxY = int(math.Ceil((originY-renderer.bounds[1])/tileExtent)) - 1
+	if renderer.options.Projection != nil {
+		last := 1<<z - 1
+		minX, minY = max(0, minX), max(0, minY)
+		maxX, maxY = min(last, maxX), min(last, maxY)
+	}
+	return minX, minY, max(minX, maxX), max(minY, maxY)
+}
+
+// tileRow converts between rows numbered from the north and rows of the
+// tile scheme, in either direction.
+func (renderer *TileRenderer) tileRow(z, y int) int {
+	if renderer.options.Scheme != SchemeTMS {
+		return y
+	}
+	if renderer.options.Projection != nil {
+		return 1<<z - 1 - y
+	}
+	_, _, _, maxY := renderer.TileRange(z)
+	return maxY - y
+}
+
+// Render renders a tile in the style of the options, with y in the tile
+// scheme. It returns false when no pixel of the tile has data.
+func (renderer *TileRenderer) Render(z, x, y int) (image.Image, bool) {
+	return renderer.RenderStyle(z, x, y, renderer.options.TileStyle)
+}
+
+// RenderStyle renders a tile like Render, in the given style. It is safe for
+// concurrent use.
+func (renderer *TileRenderer) RenderStyle(z, x, y int, style TileStyle) (image.Image, bool) {
+	var shade *ElevationMap
+	if style.Hillshade && style.Encoding == EncodingNone {
+		shade = renderer.hillshade()
+	}
+	return renderer.render(z, x, y, func(mapX, mapY, elevation float64) (uint8, uint8, uint8, uint8) {
+		if style.Encoding != EncodingNone {
+			c := style.Encoding.Encode(elevation)
+			return c.R, c.G, c.B, c.A
+		}
+		c := renderer.elevationMap.elevationColor(elevation, style.Ramp)
+		if shade != nil {
+			if value := shade.GetElevation(mapX, mapY); value != NodataValue {
+				c = shadeColor(c, value)
+			}
+		}
+		return c.R, c.G, c.B, c.A
+	})
+}
+
+// hillshade computes the illumination of the map on first use.
+func (renderer *TileRenderer) hillshade() *ElevationMap {
+	renderer.shadeOnce.Do(func() {
+		zFactor := 1.0
+		// Cells of geographic maps are in degrees, elevations in metres.
+		if _, isGeographic := renderer.options.Projection.(geographicProjection); isGeographic {
+			zFactor = 180 / (math.Pi * wgs84SemiMajorAxis)
+		}
+		renderer.shade = renderer.elevationMap.Hillshade(defaultHillshadeAzimuth, defaultHillshadeAltitude, zFactor)
+	})
+	return renderer.shade
+}
+
+func (renderer *TileRenderer) render(z, x, y int, pixel func(mapX, mapY, elevation float64) (r, g, b, a uint8)) (*image.RGBA, bool) {
+	tileSize := renderer.options.TileSize
+	resolution := renderer.resolution(z)
+	originX, originY := renderer.origin()
+	y = renderer.tileRow(z, y)
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+
+	hasData := false
+	for py := 0; py < tileSize; py++ {
+		for px := 0; px < tileSize; px++ {
+			mapX := originX + (float64(x*tileSize+px)+0.5)*resolution
+			mapY := originY - (float64(y*tileSize+py)+0.5)*resolution
+			if renderer.options.Projection != nil {
+				if mapX < renderer.bounds[0] || mapX > renderer.bounds[2] || mapY < renderer.bounds[1] || mapY > renderer.bounds[3] {
+					continue
+				}
+				lon, lat := webMercatorProjection{}.ToGeographic(mapX, mapY)
+				mapX, mapY = renderer.options.Projection.FromGeographic(lon, lat)
+			}
+			elevation := renderer.elevationMap.GetElevation(mapX, mapY)
+			if elevation == NodataValue {
+				continue
+			}
+			i := img.PixOffset(px, py)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = pixel(mapX, mapY, elevation)
+			hasData = true
+		}
+	}
+
+	return img, hasData
+}
+
+type TileJSON struct {
+	TileJSON string     `json:"tilejson"`
+	Name     string     `json:"name,omitempty"`
+	Scheme   string     `json:"scheme"`
+	Tiles    []string   `json:"tiles"`
+	MinZoom  int        `json:"minzoom"`
+	MaxZoom  int        `json:"maxzoom"`
+	Bounds   [4]float64 `json:"bounds"`
+	Center   [3]float64 `json:"center"`
+	Encoding string     `json:"encoding,omitempty"`
+	TileSize int        `json:"tileSize,omitempty"`
+}
+
+// TileJSON describes the pyramid with tiles at url, which contains {z}, {x}
+// and {y} placeholders. Bounds of local tiles are in map units.
+func (renderer *TileRenderer) TileJSON(name, url string) TileJSON {
+	bounds := renderer.geographicBounds
+	return TileJSON{
+		TileJSON: "2.2.0",
+		Name:     name,
+		Scheme:   renderer.options.Scheme.String(),
+		Tiles:    []string{url},
+		MinZoom:  renderer.options.MinZoom,
+		MaxZoom:  renderer.options.MaxZoom,
+		Bounds:   bounds,
+		Center:   [3]float64{(bounds[0] + bounds[2]) / 2, (bounds[1] + bounds[3]) / 2, float64(renderer.options.MinZoom)},
+		Encoding: renderer.options.Encoding.TileJSONName(),
+		TileSize: renderer.options.TileSize,
+	}
+}
+
+// WriteTilePyramid writes the tiles with data as dir/{z}/{x}/{y}.png and
+// their description as dir/tiles.json. It returns the number of tiles
+// written.
+func (renderer *TileRenderer) WriteTilePyramid(dir, name, url string) (int, error) {
+	written := 0
+	for z := renderer.options.MinZoom; z <= renderer.options.MaxZoom; z++ {
+		minX, minY, maxX, maxY := renderer.TileRange(z)
+		for x := minX; x <= maxX; x++ {
+			for y := minY; y <= maxY; y++ {
+				tileY := renderer.tileRow(z, y)
+				img, hasData := renderer.Render(z, x, tileY)
+				if !hasData {
+					continue
+				}
+				path := filepath.Join(dir, fmt.Sprint(z), fmt.Sprint(x), fmt.Sprintf("%d.png", tileY))
+				err := writeFile(path, func(writer *bufio.Writer) error {
+					return png.Encode(writer, img)
+				})
+				if err != nil {
+					return written, err
+				}
+				written++
+			}
+		}
+	}
+
+	if url == "" {
+		url = "{z}/{x}/{y}.png"
+	}
+	data, err := json.MarshalIndent(renderer.TileJSON(name, url), "", "  ")
+	if err != nil {
+		return written, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tiles.json"), append(data, '\n'), 0644); err != nil {
+		return written, err
+	}
+
+	return written, nil
+}
@@ -0,0 +1,403 @@
+package asctools
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+type TINOptions struct {
+	// MaxError is the largest allowed vertical distance between the surface
+	// and any grid point, in elevation units.
+	MaxError float64
+	// MaxTriangles limits the number of surface triangles. Walls and base
+	// are not counted.
+	MaxTriangles int
+}
+
+func (options TINOptions) Enabled() bool {
+	return options.MaxError > 0 || options.MaxTriangles > 0
+}
+
+// minSurfaceHeight keeps the surface from touching the floor, so that walls
+// never collapse into zero area triangles.
+const minSurfaceHeight = 0.0001
+
+type tinCandidate struct {
+	triangle int
+	version  int
+	err      float64
+	col, row int
+}
+
+type tinCandidateHeap []tinCandidate
+
+func (h tinCandidateHeap) Len() int           { return len(h) }
+func (h tinCandidateHeap) Less(i, j int) bool { return h[i].err > h[j].err }
+func (h tinCandidateHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *tinCandidateHeap) Push(x any)        { *h = append(*h, x.(tinCandidate)) }
+func (h *tinCandidateHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+type tinBuilder struct {
+	elevationMap   *ElevationMap
+	floorElevation float64
+	heights        []float64
+	d              *delaunay
+	z              []float64
+	candidates     tinCandidateHeap
+	// cells, when set, are the cells the grid mesh would keep. The surface
+	// is then limited to them.
+	cells *gridMesher
+}
+
+// height is the surface height above the floor at a grid point, with the row
+// counted from the bottom.
+func (builder *tinBuilder) height(col, row int) float64 {
+	return builder.heights[row*builder.elevationMap.NumCols+col]
+}
+
+// BuildTIN triangulates the map by greedy insertion: starting from the four
+// corners, the grid point furthest from the current surface is inserted
+// until the error or triangle limit is reached. The result is closed with
+// walls down to the floor and a flat base. On maps with nodata, the surface
+// covers the same cells as BuildGridMesh, with the outlines of the left out
+// cells at full resolution, and is walled along them in the same way.
+func (elevationMap *ElevationMap) BuildTIN(floorElevation float64, options TINOptions) (*Mesh, error) {
+	builder, err := elevationMap.buildTINSurface(floorElevation, options, true)
+	if err != nil {
+		return nil, err
+	}
+	if builder.cells != nil {
+		return builder.meshWithinCells()
+	}
+	return builder.mesh(), nil
+}
+
+// buildTINSurface runs the greedy insertion. The triangulation is left in
+// grid coordinates, with rows counted from the bottom, and heights above the
+// floor. With withinData, a map with nodata is triangulated so that no
+// triangle crosses the outline of the cells BuildGridMesh would keep.
+func (elevationMap *ElevationMap) buildTINSurface(floorElevation float64, options TINOptions, withinData bool) (*tinBuilder, error) {
+	if elevationMap.NumRows < 2 || elevationMap.NumCols < 2 {
+		return nil, fmt.Errorf("map must have at least 2 rows and 2 columns")
+	}
+	if options.MaxError < 0 || options.MaxTriangles < 0 {
+		return nil, fmt.Errorf("TIN limits must not be negative")
+	}
+	if options.MaxTriangles > 0 && options.MaxTriangles < 2 {
+		return nil, fmt.Errorf("TIN needs at least 2 triangles")
+	}
+
+	maxCol := elevationMap.NumCols - 1
+	maxRow := elevationMap.NumRows - 1
+
+	builder := &tinBuilder{
+		elevationMap:   elevationMap,
+		floorElevation: floorElevation,
+		heights:        make([]float64, elevationMap.NumRows*elevationMap.NumCols),
+		d:              newDelaunayRectangle(0, 0, float64(maxCol), float64(maxRow)),
+	}
+	for row := 0; row < elevationMap.NumRows; row++ {
+		for col := 0; col < elevationMap.NumCols; col++ {
+			height := minSurfaceHeight
+			if e := elevationMap.GetRowCol(row, col, true); e != NodataValue {
+				height = math.Max(e-floorElevation, minSurfaceHeight)
+			}
+			builder.heights[row*elevationMap.NumCols+col] = height
+		}
+	}
+	builder.z = []float64{
+		builder.height(0, 0),
+		builder.height(maxCol, 0),
+		builder.height(maxCol, maxRow),
+		builder.height(0, maxRow),
+	}
+	if withinData && slices.Contains(elevationMap.Data, NodataValue) {
+		if err := builder.insertOutline(options); err != nil {
+			return nil, err
+		}
+	}
+	for i, t := range builder.d.triangles {
+		if !t.dead {
+			builder.scan(i)
+		}
+	}
+
+	for builder.candidates.Len() > 0 {
+		// An insertion adds at most two triangles.
+		if options.MaxTriangles > 0 && builder.d.live+2 > options.MaxTriangles {
+			break
+		}
+		c := heap.Pop(&builder.candidates).(tinCandidate)
+		if builder.d.triangles[c.triangle].dead || builder.d.version[c.triangle] != c.version {
+			continue
+		}
+		if c.err <= options.MaxError {
+			break
+		}
+		point := builder.d.addPoint(float64(c.col), float64(c.row))
+		builder.z = append(builder.z, builder.height(c.col, c.row))
+		for _, t := range builder.d.insert(point, c.triangle) {
+			builder.scan(t)
+		}
+	}
+
+	return builder, nil
+}
+
+// scan finds the grid point inside the triangle with the largest error and
+// queues it as a candidate for insertion.
+func (builder *tinBuilder) scan(triangle int) {
+	d := builder.d
+	v := d.triangles[triangle].vertices
+	p0, p1, p2 := d.points[v[0]], d.points[v[1]], d.points[v[2]]
+	z0, z1, z2 := builder.z[v[0]], builder.z[v[1]], builder.z[v[2]]
+
+	// Plane through the three vertices: z = a*x + b*y + c.
+	det := (p1.X-p0.X)*(p2.Y-p0.Y) - (p2.X-p0.X)*(p1.Y-p0.Y)
+	if det == 0 {
+		return
+	}
+	a := ((z1-z0)*(p2.Y-p0.Y) - (z2-z0)*(p1.Y-p0.Y)) / det
+	b := ((p1.X-p0.X)*(z2-z0) - (p2.X-p0.X)*(z1-z0)) / det
+	c := z0 - a*p0.X - b*p0.Y
+
+	best := tinCandidate{triangle: triangle, version: d.version[triangle], err: -1}
+
+	minY := int(math.Ceil(math.Min(p0.Y, math.Min(p1.Y, p2.Y))))
+	maxY := int(math.Floor(math.Max(p0.Y, math.Max(p1.Y, p2.Y))))
+	corners := [3]delaunayPoint{p0, p1, p2}
+	for row := minY; row <= maxY; row++ {
+		y := float64(row)
+		minX, maxX := math.Inf(1), math.Inf(-1)
+		for i := 0; i < 3; i++ {
+			e0, e1 := corners[i], corners[(i+1)%3]
+			if (y < e0.Y && y < e1.Y) || (y > e0.Y && y > e1.Y) {
+				continue
+			}
+			if e0.Y == e1.Y {
+				minX = math.Min(minX, math.Min(e0.X, e1.X))
+				maxX = math.Max(maxX, math.Max(e0.X, e1.X))
+				continue
+			}
+			x := e0.X + (y-e0.Y)*(e1.X-e0.X)/(e1.Y-e0.Y)
+			minX = math.Min(minX, x)
+			maxX = math.Max(maxX, x)
+		}
+		for col := int(math.Ceil(minX - 1e-9)); col <= int(math.Floor(maxX+1e-9)); col++ {
+			if isTriangleCorner(corners, float64(col), y) {
+				continue
+			}
+			if builder.cells != nil && !builder.touchesCells(col, row, true) {
+				continue
+			}
+			err := math.Abs(builder.height(col, row) - (a*float64(col) + b*y + c))
+			if err > best.err {
+				best.err, best.col, best.row = err, col, row
+			}
+		}
+	}
+
+	if best.err > 0 {
+		heap.Push(&builder.candidates, best)
+	}
+}
+
+// insertOutline selects the cells BuildGridMesh would keep and inserts the
+// grid points where they meet cells that are left out. A grid edge between
+// two neighbouring points is always a Delaunay edge, since no other grid
+// point lies in the circle over it, so the whole outline ends up in the
+// triangulation and every triangle lies either within the kept cells or
+// outside them.
+func (builder *tinBuilder) insertOutline(options TINOptions) error {
+	elevationMap := builder.elevationMap
+	builder.cells = &gridMesher{
+		elevationMap:   elevationMap,
+		floorElevation: math.Inf(-1),
+		row1:           elevationMap.NumRows - 1,
+		col1:           elevationMap.NumCols - 1,
+	}
+	builder.cells.selectCells()
+
+	triangle := -1
+	for row := 0; row < elevationMap.NumRows; row++ {
+		for col := 0; col < elevationMap.NumCols; col++ {
+			if !builder.touchesCells(col, row, true) || !builder.touchesCells(col, row, false) {
+				continue
+			}
+			p := delaunayPoint{float64(col), float64(row)}
+			triangle = builder.d.locate(p, triangle)
+			if isTriangleCorner(builder.triangleCorners(triangle), p.X, p.Y) {
+				continue
+			}
+			point := builder.d.addPoint(p.X, p.Y)
+			builder.z = append(builder.z, builder.height(col, row))
+			triangle = builder.d.insert(point, triangle)[0]
+		}
+	}
+
+	if options.MaxTriangles > 0 && builder.d.live > options.MaxTriangles {
+		return fmt.Errorf("outlining nodata takes %d triangles, more than the limit of %d", builder.d.live, options.MaxTriangles)
+	}
+	return nil
+}
+
+// touchesCells reports whether any cell of the map around a grid point is
+// kept, or with kept false, left out.
+func (builder *tinBuilder) touchesCells(col, row int, kept bool) bool {
+	for r := max(row-1, 0); r <= min(row, builder.elevationMap.NumRows-2); r++ {
+		for c := max(col-1, 0); c <= min(col, builder.elevationMap.NumCols-2); c++ {
+			if builder.cells.hasCell(r, c) == kept {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (builder *tinBuilder) triangleCorners(triangle int) [3]delaunayPoint {
+	v := builder.d.triangles[triangle].vertices
+	return [3]delaunayPoint{builder.d.points[v[0]], builder.d.points[v[1]], builder.d.points[v[2]]}
+}
+
+func isTriangleCorner(corners [3]delaunayPoint, x, y float64) bool {
+	for _, p := range corners {
+		if p.X == x && p.Y == y {
+			return true
+		}
+	}
+	return false
+}
+
+func (builder *tinBuilder) mesh() *Mesh {
+	d := builder.d
+	cellSize := builder.elevationMap.CellSize
+	maxCol := float64(builder.elevationMap.NumCols - 1)
+	maxRow := float64(builder.elevationMap.NumRows - 1)
+
+	mesh := &Mesh{}
+	for i, p := range d.points {
+		mesh.AddVertex(Vector3{float32(p.X * cellSize), float32(p.Y * cellSize), float32(builder.z[i])})
+	}
+	for _, t := range d.triangles {
+		if !t.dead {
+			mesh.AddFace(t.vertices[0], t.vertices[1], t.vertices[2])
+		}
+	}
+
+	// Boundary points in counter-clockwise order, starting at the lower left
+	// corner.
+	perimeter := func(p delaunayPoint) float64 {
+		switch {
+		case p.Y == 0:
+			return p.X
+		case p.X == maxCol:
+			return maxCol + p.Y
+		case p.Y == maxRow:
+			return maxCol + maxRow + (maxCol - p.X)
+		default:
+			return 2*maxCol + maxRow + (maxRow - p.Y)
+		}
+	}
+	boundary := []int{}
+	for i, p := range d.points {
+		if p.X == 0 || p.Y == 0 || p.X == maxCol || p.Y == maxRow {
+			boundary = append(boundary, i)
+		}
+	}
+	sort.Slice(boundary, func(i, j int) bool {
+		return perimeter(d.points[boundary[i]]) < perimeter(d.points[boundary[j]])
+	})
+
+	floor := make([]int, len(boundary))
+	for i, top := range boundary {
+		v := mesh.Vertices[top]
+		floor[i] = mesh.AddVertex(Vector3{v.X, v.Y, 0})
+	}
+	center := mesh.AddVertex(Vector3{float32(maxCol * cellSize / 2), float32(maxRow * cellSize / 2), 0})
+
+	for i := range boundary {
+		j := (i + 1) % len(boundary)
+		mesh.AddFace(boundary[i], floor[i], floor[j])
+		mesh.AddFace(boundary[i], floor[j], boundary[j])
+		mesh.AddFace(center, floor[j], floor[i])
+	}
+
+	return mesh
+}
+
+// meshWithinCells closes the triangles over the kept cells like
+// BuildGridMesh closes its cells: walls follow every edge of the surface,
+// including the outlines of interior holes, down to a base mirroring the
+// surface.
+func (builder *tinBuilder) meshWithinCells() (*Mesh, error) {
+	d := builder.d
+	cellSize := builder.elevationMap.CellSize
+	maxCellCol := builder.elevationMap.NumCols - 2
+	maxCellRow := builder.elevationMap.NumRows - 2
+
+	kept := []delaunayTriangle{}
+	edges := map[[2]int]bool{}
+	for _, t := range d.triangles {
+		if t.dead {
+			continue
+		}
+		// Triangles never cross the outline, so the cell under the centroid
+		// tells on which side of it a triangle lies.
+		p0, p1, p2 := d.points[t.vertices[0]], d.points[t.vertices[1]], d.points[t.vertices[2]]
+		col := min(max(int(math.Floor((p0.X+p1.X+p2.X)/3)), 0), maxCellCol)
+		row := min(max(int(math.Floor((p0.Y+p1.Y+p2.Y)/3)), 0), maxCellRow)
+		if !builder.cells.hasCell(row, col) {
+			continue
+		}
+		kept = append(kept, t)
+		for i := 0; i < 3; i++ {
+			edges[[2]int{t.vertices[i], t.vertices[(i+1)%3]}] = true
+		}
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("map has no cells with valid elevations")
+	}
+
+	mesh := &Mesh{}
+	top := map[int]int{}
+	floor := map[int]int{}
+	vertex := func(point int) (int, int) {
+		if _, ok := top[point]; !ok {
+			p := d.points[point]
+			x, y := float32(p.X*cellSize), float32(p.Y*cellSize)
+			top[point] = mesh.AddVertex(Vector3{x, y, float32(builder.z[point])})
+			floor[point] = mesh.AddVertex(Vector3{x, y, 0})
+		}
+		return top[point], floor[point]
+	}
+
+	for _, t := range kept {
+		v0, f0 := vertex(t.vertices[0])
+		v1, f1 := vertex(t.vertices[1])
+		v2, f2 := vertex(t.vertices[2])
+		mesh.AddFace(v0, v1, v2)
+		mesh.AddFace(f0, f2, f1)
+
+		for i := 0; i < 3; i++ {
+			a, b := t.vertices[i], t.vertices[(i+1)%3]
+			if edges[[2]int{b, a}] {
+				continue
+			}
+			// The edge runs counter-clockwise around the surface.
+			at, af := vertex(a)
+			bt, bf := vertex(b)
+			mesh.AddFace(at, af, bf)
+			mesh.AddFace(at, bf, bt)
+		}
+	}
+
+	return mesh, nil
+}
@@ -0,0 +1,109 @@
+package asctools
+
+import (
+	"fmt"
+	"math"
+)
+
+// Transformation converts coordinates between two CRSs through WGS84
+// longitude and latitude.
+type Transformation struct {
+	source Projection
+	target Projection
+}
+
+func NewTransformation(source, target *CRS) (*Transformation, error) {
+	sourceProjection, err := source.Projection()
+	if err != nil {
+		return nil, err
+	}
+	targetProjection, err := target.Projection()
+	if err != nil {
+		return nil, err
+	}
+	return &Transformation{source: sourceProjection, target: targetProjection}, nil
+}
+
+// Transform converts source coordinates to target coordinates.
+func (transformation *Transformation) Transform(x, y float64) (float64, float64) {
+	return transformation.target.FromGeographic(transformation.source.ToGeographic(x, y))
+}
+
+// Inverse converts target coordinates to source coordinates.
+func (transformation *Transformation) Inverse(x, y float64) (float64, float64) {
+	return transformation.source.FromGeographic(transformation.target.ToGeographic(x, y))
+}
+
+type WarpOptions struct {
+	// CellSize is the cell size of the output grid in target units. When 0
+	// it is chosen so that the cell at the centre of the map keeps its area,
+	// rounded to three significant digits.
+	CellSize   float64
+	Resampling Resampling
+	Workers    int
+}
+
+// Warp reprojects the map to the target CRS. The output grid covers the
+// transformed map and is aligned to multiples of the cell size, and every
+// output cell samples the map at its centre transformed back to the map's
+// CRS. Elevations are not changed.
+func (elevationMap *ElevationMap) Warp(target *CRS, options WarpOptions) (*ElevationMap, error) {
+	if elevationMap.CRS == nil {
+		return nil, fmt.Errorf("map has no CRS to warp from")
+	}
+	if options.CellSize < 0 {
+		return nil, fmt.Errorf("cell size must not be negative")
+	}
+	transformation, err := NewTransformation(elevationMap.CRS, target)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := elevationMap.transformedBounds(transformation.Transform)
+	if bounds[0] >= bounds[2] || bounds[1] >= bounds[3] {
+		return nil, fmt.Errorf("map cannot be transformed to %s", target)
+	}
+	cellSize := options.CellSize
+	if cellSize == 0 {
+		cellSize = elevationMap.warpedCellSize(transformation)
+	}
+
+	minX := math.Floor(bounds[0]/cellSize) * cellSize
+	minY := math.Floor(bounds[1]/cellSize) * cellSize
+	numCols := int(math.Ceil((bounds[2] - minX) / cellSize))
+	numRows := int(math.Ceil((bounds[3] - minY) / cellSize))
+	result := makeElevationMapWithSize(minX, minY, numRows, numCols, cellSize)
+	result.CRS = target
+
+	parallelRows(result.NumRows, options.Workers, func(worker, startRow, endRow int) {
+		for row := startRow; row < endRow; row++ {
+			for col := 0; col < result.NumCols; col++ {
+				x, y := transformation.Inverse(result.CellCenter(row, col))
+				if math.IsNaN(x) || math.IsNaN(y) {
+					continue
+				}
+				result.SetRowCol(row, col, elevationMap.Sample(x, y, options.Resampling))
+			}
+		}
+	})
+	result.UpdateElevationRange()
+
+	return result, nil
+}
+
+// warpedCellSize returns the side of the square with the area of the centre
+// cell of the map transformed, rounded to three significant digits.
+func (elevationMap *ElevationMap) warpedCellSize(transformation *Transformation) float64 {
+	centerX := (elevationMap.MinX + elevationMap.MaxX) / 2
+	centerY := (elevationMap.MinY + elevationMap.MaxY) / 2
+	half := elevationMap.CellSize / 2
+	westX, westY := transformation.Transform(centerX-half, centerY)
+	eastX, eastY := transformation.Transform(centerX+half, centerY)
+	southX, southY := transformation.Transform(centerX, centerY-half)
+	northX, northY := transformation.Transform(centerX, centerY+half)
+	width := math.Hypot(eastX-westX, eastY-westY)
+	height := math.Hypot(northX-southX, northY-southY)
+	size := math.Sqrt(width * height)
+	digit := math.Pow(10, math.Floor(math.Log10(size))-2)
+	return math.Round(size/digit) * digit
+}
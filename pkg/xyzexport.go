@@ -0,0 +1,85 @@
+package asctools
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+)
+
+type XYZOptions struct {
+	SkipNodata bool
+	Delimiter  string
+	// Precision is the number of decimals written, -1 writes the shortest
+	// representation that reads back exactly.
+	Precision int
+	Header    bool
+	// Every writes only every Nth row and column, starting with the first.
+	Every int
+}
+
+func DefaultXYZOptions() XYZOptions {
+	return XYZOptions{
+		SkipNodata: true,
+		Delimiter:  " ",
+		Precision:  -1,
+		Every:      1,
+	}
+}
+
+// ParseXYZDelimiter accepts a delimiter by name, which is easier to pass on
+// a command line, or as the delimiter itself.
+func ParseXYZDelimiter(value string) (string, error) {
+	switch value {
+	case "space", " ", "":
+		return " ", nil
+	case "tab", "\t", "\\t":
+		return "\t", nil
+	case "comma", ",":
+		return ",", nil
+	case "semicolon", ";":
+		return ";", nil
+	default:
+		return "", fmt.Errorf("unknown delimiter: %s", value)
+	}
+}
+
+// WriteXYZ writes one line per cell with the world coordinates of its centre
+// and its elevation, from the northern row down.
+func (elevationMap *ElevationMap) WriteXYZ(writer *bufio.Writer, options XYZOptions) error {
+	if options.Every < 1 {
+		return fmt.Errorf("every must be greater than or equal to 1")
+	}
+	if options.Precision < -1 {
+		return fmt.Errorf("precision must not be negative")
+	}
+
+	if options.Header {
+		if _, err := writer.WriteString("x" + options.Delimiter + "y" + options.Delimiter + "z\n"); err != nil {
+			return fmt.Errorf("failed to write header: %v", err)
+		}
+	}
+
+	line := []byte{}
+	for row := 0; row < elevationMap.NumRows; row += options.Every {
+		for col := 0; col < elevationMap.NumCols; col += options.Every {
+			value := elevationMap.GetRowCol(row, col, false)
+			if value == NodataValue && options.SkipNodata {
+				continue
+			}
+			x, y := elevationMap.CellCenter(row, col)
+			line = strconv.AppendFloat(line[:0], x, 'f', options.Precision, 64)
+			line = append(line, options.Delimiter...)
+			line = strconv.AppendFloat(line, y, 'f', options.Precision, 64)
+			line = append(line, options.Delimiter...)
+			// Elevations are stored as float32, so format them as such to
+			// write 100.1 rather than 100.0999984741211.
+			line = strconv.AppendFloat(line, value, 'f', options.Precision, 32)
+			line = append(line, '\n')
+			if _, err := writer.Write(line); err != nil {
+				return fmt.Errorf("failed to write point: %v", err)
+			}
+		}
+	}
+
+	return writer.Flush()
+}
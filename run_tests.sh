@@ -49,6 +49,50 @@ run_split_test() {
     fi
 }
 
+run_crs_test() {
+    local INPUT_FILE="test/merged.asc"
+    local TEMP_SPLIT_DIR="test/temp/crs_split"
+    local TEMP_OUTPUT_DIR="test/temp/crs"
+    local EXPECTED_OUTPUT_DIR="test/crs"
+
+    rm -rf "$TEMP_SPLIT_DIR" "$TEMP_OUTPUT_DIR"
+    mkdir -p "$TEMP_SPLIT_DIR" "$TEMP_OUTPUT_DIR"
+
+    echo "Running crs test..."
+    ./asctools split -nrows 2 -ncols 2 -crs EPSG:2180 -output_dir "$TEMP_SPLIT_DIR" < "$INPUT_FILE"
+    ./asctools merge -input_dir "$TEMP_SPLIT_DIR" -output "$TEMP_OUTPUT_DIR/merged.asc"
+
+    echo "Comparing crs directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ crs Test PASSED: Directories are identical."
+    else
+        echo "❌ crs Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
+run_warp_test() {
+    local INPUT_FILE="test/merged.asc"
+    local TEMP_OUTPUT_DIR="test/temp/warp"
+    local EXPECTED_OUTPUT_DIR="test/warp"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+    mkdir -p "$TEMP_OUTPUT_DIR"
+
+    echo "Running warp test..."
+    ./asctools warp -crs EPSG:2180 -target_crs EPSG:32633 -cellsize 1 -resampling nearest -output "$TEMP_OUTPUT_DIR/merged.asc" < "$INPUT_FILE"
+
+    echo "Comparing warp directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ warp Test PASSED: Directories are identical."
+    else
+        echo "❌ warp Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
 run_asc2png_test() {
     local TEMP_OUTPUT="test/temp/1to9.png"
     local EXPECTED_OUTPUT="test/1to9.png"
@@ -70,6 +114,27 @@ run_asc2png_test() {
     fi
 }
 
+run_asc2png_georef_test() {
+    local INPUT_FILE="test/merged.asc"
+    local TEMP_OUTPUT_DIR="test/temp/georef"
+    local EXPECTED_OUTPUT_DIR="test/georef"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+    mkdir -p "$TEMP_OUTPUT_DIR"
+
+    echo "Running asc2png georef test..."
+    ./asctools asc2png -scaling_operation down -scale 2 -ramp terrain -output "$TEMP_OUTPUT_DIR/merged.png" -aux_xml -crs EPSG:2180 < "$INPUT_FILE"
+
+    echo "Comparing asc2png georef directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ asc2png georef Test PASSED: Directories are identical."
+    else
+        echo "❌ asc2png georef Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
 run_asc2stl_test() {
     local TEMP_OUTPUT="test/temp/1to9.stl"
     local EXPECTED_OUTPUT="test/1to9.stl"
@@ -91,6 +156,305 @@ run_asc2stl_test() {
     fi
 }
 
+run_asc2stl_tin_test() {
+    local TEMP_OUTPUT="test/temp/merged_tin.stl"
+    local EXPECTED_OUTPUT="test/merged_tin.stl"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2stl TIN test..."
+    ./asctools asc2stl -max_error 1 < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing asc2stl TIN output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2stl TIN Test PASSED: Files are identical."
+    else
+        echo "❌ asc2stl TIN Test FAILED: Files are different."
+        return 1
+    fi
+}
+
+run_asc2mesh_test() {
+    local TEMP_OUTPUT="test/temp/merged.ply"
+    local EXPECTED_OUTPUT="test/merged.ply"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2mesh test..."
+    ./asctools asc2mesh -format ply -ramp terrain < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing asc2mesh output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2mesh Test PASSED: Files are identical."
+    else
+        echo "❌ asc2mesh Test FAILED: Files are different."
+        return 1
+    fi
+}
+
+run_meshcheck_test() {
+    local TEMP_OUTPUT="test/temp/merged_meshcheck.txt"
+    local EXPECTED_OUTPUT="test/merged_meshcheck.txt"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running meshcheck test..."
+    ./asctools asc2stl < "$INPUT_FILE" | ./asctools meshcheck > "$TEMP_OUTPUT"
+
+    echo "Comparing meshcheck output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ meshcheck Test PASSED: Files are identical."
+    else
+        echo "❌ meshcheck Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_asc2stl_lithophane_test() {
+    local TEMP_OUTPUT="test/temp/merged_lithophane.stl"
+    local EXPECTED_OUTPUT="test/merged_lithophane.stl"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2stl lithophane test..."
+    ./asctools asc2stl -mode lithophane -scale 10 < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing lithophane STL files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2stl lithophane Test PASSED: Files are identical."
+    else
+        echo "❌ asc2stl lithophane Test FAILED: Files are different."
+        return 1
+    fi
+}
+
+run_asc2stl_plinth_test() {
+    local TEMP_OUTPUT="test/temp/merged_plinth_meshcheck.txt"
+    local EXPECTED_OUTPUT="test/merged_plinth_meshcheck.txt"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2stl plinth test..."
+    ./asctools asc2stl -scale 10 -plinth 3 -plinth_text "Test" -scale_bar -north_arrow -text_height 4 < "$INPUT_FILE" | ./asctools meshcheck > "$TEMP_OUTPUT"
+
+    echo "Comparing plinth meshcheck output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2stl plinth Test PASSED: Files are identical."
+    else
+        echo "❌ asc2stl plinth Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_stl2asc_test() {
+    local TEMP_OUTPUT="test/temp/merged_stl2asc.asc"
+    local EXPECTED_OUTPUT="test/merged_stl2asc.asc"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running stl2asc test..."
+    ./asctools asc2stl < "$INPUT_FILE" | ./asctools stl2asc -cellsize 1 -origin_x 0.5 -origin_y 0.5 > "$TEMP_OUTPUT"
+
+    echo "Comparing stl2asc output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ stl2asc Test PASSED: Files are identical."
+    else
+        echo "❌ stl2asc Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_png2asc_test() {
+    local TEMP_OUTPUT="test/temp/merged_terrarium.asc"
+    local EXPECTED_OUTPUT="test/merged.asc"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running png2asc test..."
+    ./asctools asc2png -encoding terrarium < "$INPUT_FILE" | ./asctools png2asc -encoding terrarium -origin_x 0.5 -origin_y 0.5 > "$TEMP_OUTPUT"
+
+    echo "Comparing png2asc output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ png2asc Test PASSED: Files are identical."
+    else
+        echo "❌ png2asc Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_tiles_test() {
+    local INPUT_FILE="test/merged.asc"
+    local TEMP_OUTPUT_DIR="test/temp/tiles"
+    local EXPECTED_OUTPUT_DIR="test/tiles"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+
+    echo "Running tiles test..."
+    ./asctools tiles -input "$INPUT_FILE" -output "$TEMP_OUTPUT_DIR" -tile_size 4 -max_zoom 2 -scheme tms 2> /dev/null
+
+    echo "Comparing tiles directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ tiles Test PASSED: Directories are identical."
+    else
+        echo "❌ tiles Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
+run_terrain_test() {
+    local INPUT_FILE="test/merged.asc"
+    local TEMP_OUTPUT_DIR="test/temp/terrain"
+    local EXPECTED_OUTPUT_DIR="test/terrain"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+
+    echo "Running terrain test..."
+    ./asctools terrain -input "$INPUT_FILE" -output "$TEMP_OUTPUT_DIR" -crs EPSG:4326 -max_level 3 -normals 2> /dev/null
+
+    echo "Comparing terrain directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ terrain Test PASSED: Directories are identical."
+    else
+        echo "❌ terrain Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
+run_asc2xyz_test() {
+    local TEMP_OUTPUT="test/temp/merged.xyz"
+    local EXPECTED_OUTPUT="test/merged.xyz"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2xyz test..."
+    ./asctools asc2xyz -header -delimiter comma < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing asc2xyz output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2xyz Test PASSED: Files are identical."
+    else
+        echo "❌ asc2xyz Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_asc2xyz_fraction_test() {
+    local TEMP_OUTPUT="test/temp/points_mean.xyz"
+    local EXPECTED_OUTPUT="test/points_mean.xyz"
+    local INPUT_FILE="test/points_mean.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2xyz fraction test..."
+    ./asctools asc2xyz -skip_nodata < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing asc2xyz fraction output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2xyz fraction Test PASSED: Files are identical."
+    else
+        echo "❌ asc2xyz fraction Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_asc2html_test() {
+    local TEMP_OUTPUT="test/temp/merged.html"
+    local EXPECTED_OUTPUT="test/merged.html"
+    local INPUT_FILE="test/merged.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2html test..."
+    ./asctools asc2html -title merged -exaggeration 2 -ramp viridis < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing asc2html output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2html Test PASSED: Files are identical."
+    else
+        echo "❌ asc2html Test FAILED: Files are different."
+        return 1
+    fi
+}
+
+run_grid_test() {
+    local TEMP_OUTPUT="test/temp/points_mean.asc"
+    local TEMP_COUNT="test/temp/points_count.asc"
+    local EXPECTED_OUTPUT="test/points_mean.asc"
+    local EXPECTED_COUNT="test/points_count.asc"
+    local INPUT_FILE="test/points.las"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running grid test..."
+    ./asctools grid -input "$INPUT_FILE" -classes 2 -cellsize 2 -method mean -count "$TEMP_COUNT" > "$TEMP_OUTPUT"
+
+    echo "Comparing grid output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT" && diff -q "$TEMP_COUNT" "$EXPECTED_COUNT"; then
+        echo "✅ grid Test PASSED: Files are identical."
+    else
+        echo "❌ grid Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        diff "$TEMP_COUNT" "$EXPECTED_COUNT"
+        return 1
+    fi
+}
+
+run_grid_tin_test() {
+    local TEMP_OUTPUT="test/temp/points_tin.asc"
+    local EXPECTED_OUTPUT="test/points_tin.asc"
+    local INPUT_FILE="test/points.xyz"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running grid tin test..."
+    ./asctools grid -method tin < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing grid tin output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ grid tin Test PASSED: Files are identical."
+    else
+        echo "❌ grid tin Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_stltiles_test() {
+    local INPUT_FILE="test/merged.asc"
+    local TEMP_OUTPUT_DIR="test/temp/stltiles"
+    local EXPECTED_OUTPUT_DIR="test/stltiles"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+    mkdir -p "$TEMP_OUTPUT_DIR"
+
+    echo "Running stltiles test..."
+    ./asctools stltiles -nrows 2 -ncols 2 -scale 10 -output_dir "$TEMP_OUTPUT_DIR" < "$INPUT_FILE"
+
+    echo "Comparing stltiles directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ stltiles Test PASSED: Directories are identical."
+    else
+        echo "❌ stltiles Test FAILED: Directories are different."
+        return 1
+    fi
+}
+
 run_crop_test() {
     local TEMP_OUTPUT="test/temp/1to9_cropped.asc"
     local EXPECTED_OUTPUT="test/1to9_cropped.asc"
@@ -112,6 +476,66 @@ run_crop_test() {
     fi
 }
 
+run_diffasc2png_test() {
+    local TEMP_OUTPUT="test/temp/merged_diff.png"
+    local EXPECTED_OUTPUT="test/merged_diff.png"
+    local INPUT1="test/merged.asc"
+    local INPUT2="test/merged_changed.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running diffasc2png test..."
+    ./asctools diffasc2png -input1 "$INPUT1" -input2 "$INPUT2" -clamp_percentile 60 -dead_band 0.5 -hillshade -legend > "$TEMP_OUTPUT"
+
+    echo "Comparing diffasc2png output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ diffasc2png Test PASSED: Files are identical."
+    else
+        echo "❌ diffasc2png Test FAILED: Files are different."
+        return 1
+    fi
+}
+
+run_diffasc2png_union_test() {
+    local TEMP_OUTPUT="test/temp/merged_diff_union.png"
+    local EXPECTED_OUTPUT="test/merged_diff_union.png"
+    local INPUT1="test/merged.asc"
+    local INPUT2="test/merged_stl2asc.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running diffasc2png union test..."
+    ./asctools diffasc2png -input1 "$INPUT1" -input2 "$INPUT2" -extent union -clamp 30 > "$TEMP_OUTPUT"
+
+    echo "Comparing diffasc2png union output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ diffasc2png union Test PASSED: Files are identical."
+    else
+        echo "❌ diffasc2png union Test FAILED: Files are different."
+        return 1
+    fi
+}
+
+run_diffasc2png_pow_test() {
+    local TEMP_OUTPUT="test/temp/merged_diff_pow.png"
+    local EXPECTED_OUTPUT="test/merged_diff_pow.png"
+    local INPUT1="test/merged.asc"
+    local INPUT2="test/merged_changed.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running diffasc2png pow test..."
+    ./asctools diffasc2png -input1 "$INPUT1" -input2 "$INPUT2" -diff_pow 2 > "$TEMP_OUTPUT"
+
+    echo "Comparing diffasc2png pow output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ diffasc2png pow Test PASSED: Files are identical."
+    else
+        echo "❌ diffasc2png pow Test FAILED: Files are different."
+        return 1
+    fi
+}
+
 run_subtract_test() {
     local TEMP_OUTPUT="test/temp/subtracted.asc"
     local EXPECTED_OUTPUT="test/subtracted.asc"
@@ -133,9 +557,266 @@ run_subtract_test() {
     fi
 }
 
+run_calc_test() {
+    local TEMP_OUTPUT="test/temp/calc.asc"
+    local EXPECTED_OUTPUT="test/subtracted.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running calc test..."
+    ./asctools calc -input A=test/1to9.asc -input B=test/0to8.asc -expr "A - B" > "$TEMP_OUTPUT"
+
+    echo "Comparing calc output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ calc Test PASSED: Files are identical."
+    else
+        echo "❌ calc Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_pipeline_test() {
+    local RECIPE="test/pipeline.yaml"
+    local TEMP_OUTPUT_DIR="test/temp/pipeline"
+    local EXPECTED_OUTPUT_DIR="test/pipeline"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+    mkdir -p "$TEMP_OUTPUT_DIR"
+
+    echo "Running pipeline test..."
+    ./asctools pipeline -recipe "$RECIPE"
+
+    echo "Comparing pipeline directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ pipeline Test PASSED: Directories are identical."
+    else
+        echo "❌ pipeline Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
+run_denoise_gaussian_test() {
+    local TEMP_OUTPUT="test/temp/points_mean_gaussian.asc"
+    local EXPECTED_OUTPUT="test/points_mean_gaussian.asc"
+    local INPUT_FILE="test/points_mean.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running denoise gaussian test..."
+    ./asctools denoise -method gaussian -window 5 < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing denoise gaussian output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ denoise gaussian Test PASSED: Files are identical."
+    else
+        echo "❌ denoise gaussian Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_denoise_bilateral_test() {
+    local TEMP_OUTPUT="test/temp/points_mean_bilateral.asc"
+    local EXPECTED_OUTPUT="test/points_mean_bilateral.asc"
+    local INPUT_FILE="test/points_mean.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running denoise bilateral test..."
+    ./asctools denoise -method bilateral -window 5 -range_sigma 0.5 < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing denoise bilateral output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ denoise bilateral Test PASSED: Files are identical."
+    else
+        echo "❌ denoise bilateral Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_denoise_spike_test() {
+    local TEMP_OUTPUT="test/temp/spike_spike.asc"
+    local EXPECTED_OUTPUT="test/spike_spike.asc"
+    local INPUT_FILE="test/spike.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running denoise spike test..."
+    ./asctools denoise -method spike -window 3 < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing denoise spike output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ denoise spike Test PASSED: Files are identical."
+    else
+        echo "❌ denoise spike Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_denoise_preserve_test() {
+    local TEMP_OUTPUT="test/temp/spike_preserve.asc"
+    local EXPECTED_OUTPUT="test/spike_preserve.asc"
+    local INPUT_FILE="test/spike.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running denoise preserve test..."
+    ./asctools denoise -method gaussian -window 3 -nodata preserve < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing denoise preserve output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ denoise preserve Test PASSED: Files are identical."
+    else
+        echo "❌ denoise preserve Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_denoise_median_test() {
+    local TEMP_OUTPUT="test/temp/points_mean_median.asc"
+    local EXPECTED_OUTPUT="test/points_mean_median.asc"
+    local INPUT_FILE="test/points_mean.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running denoise median test..."
+    ./asctools denoise -method median -window 5 < "$INPUT_FILE" > "$TEMP_OUTPUT"
+
+    echo "Comparing denoise median output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ denoise median Test PASSED: Files are identical."
+    else
+        echo "❌ denoise median Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_downscale_test() {
+    local INPUT_FILE="test/points_mean.asc"
+    local TEMP_OUTPUT_DIR="test/temp/downscale"
+    local EXPECTED_OUTPUT_DIR="test/downscale"
+
+    rm -rf "$TEMP_OUTPUT_DIR"
+    mkdir -p "$TEMP_OUTPUT_DIR"
+
+    echo "Running downscale test..."
+    for aggregation in mean min max median mode first percentile; do
+        ./asctools downscale -factor 2 -aggregation "$aggregation" -percentile 25 < "$INPUT_FILE" > "$TEMP_OUTPUT_DIR/$aggregation.asc"
+    done
+    ./asctools downscale -factor 2.5 -edges pad -min_coverage 0.5 < "$INPUT_FILE" > "$TEMP_OUTPUT_DIR/fractional.asc"
+    ./asctools downscale -cellsize 3 -aggregation min -edges pad < "$INPUT_FILE" > "$TEMP_OUTPUT_DIR/cellsize.asc"
+    ./asctools downscale -factor 2 -aggregation mode -edges pad < test/spike.asc > "$TEMP_OUTPUT_DIR/mode_repeated.asc"
+
+    echo "Comparing downscale directories..."
+    if diff -r -q "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"; then
+        echo "✅ downscale Test PASSED: Directories are identical."
+    else
+        echo "❌ downscale Test FAILED: Directories are different."
+        diff -r "$TEMP_OUTPUT_DIR" "$EXPECTED_OUTPUT_DIR"
+        return 1
+    fi
+}
+
+run_calc_first_test() {
+    local TEMP_OUTPUT="test/temp/calc_first.asc"
+    local EXPECTED_OUTPUT="test/calc_first.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running calc first test..."
+    ./asctools calc -input B=test/1to9_cropped.asc -input A=test/1to9.asc -expr "A - B" -extent first > "$TEMP_OUTPUT"
+
+    echo "Comparing calc first output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ calc first Test PASSED: Files are identical."
+    else
+        echo "❌ calc first Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_warp_denoise_test() {
+    local TEMP_OUTPUT="test/temp/hills_4326_denoised.asc"
+    local EXPECTED_OUTPUT="test/hills_4326_denoised.asc"
+    local INPUT_FILE="test/hills.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running warp denoise test..."
+    ./asctools warp -crs EPSG:2180 -target_crs EPSG:4326 < "$INPUT_FILE" | ./asctools denoise -method spike > "$TEMP_OUTPUT"
+
+    echo "Comparing warp denoise output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ warp denoise Test PASSED: Files are identical."
+    else
+        echo "❌ warp denoise Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
+run_asc2stl_tin_nodata_test() {
+    local TEMP_OUTPUT="test/temp/points_mean_tin_meshcheck.txt"
+    local EXPECTED_OUTPUT="test/points_mean_tin_meshcheck.txt"
+    local INPUT_FILE="test/points_mean.asc"
+
+    mkdir -p "$(dirname "$TEMP_OUTPUT")"
+
+    echo "Running asc2stl tin nodata test..."
+    ./asctools asc2stl -max_error=0.5 < "$INPUT_FILE" | ./asctools meshcheck > "$TEMP_OUTPUT"
+
+    echo "Comparing asc2stl tin nodata output files..."
+    if diff -q "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"; then
+        echo "✅ asc2stl tin nodata Test PASSED: Files are identical."
+    else
+        echo "❌ asc2stl tin nodata Test FAILED: Files are different."
+        diff "$TEMP_OUTPUT" "$EXPECTED_OUTPUT"
+        return 1
+    fi
+}
+
 run_merge_test
 run_split_test
+run_crs_test
+run_warp_test
+run_warp_denoise_test
 run_asc2png_test
+run_asc2png_georef_test
 run_asc2stl_test
+run_asc2stl_tin_test
+run_asc2stl_tin_nodata_test
+run_asc2mesh_test
+run_meshcheck_test
+run_asc2stl_lithophane_test
+run_asc2stl_plinth_test
+run_stl2asc_test
+run_stltiles_test
+run_png2asc_test
+run_tiles_test
+run_terrain_test
+run_asc2xyz_test
+run_asc2xyz_fraction_test
+run_asc2html_test
+run_grid_test
+run_grid_tin_test
 run_crop_test
 run_subtract_test
+run_diffasc2png_test
+run_diffasc2png_union_test
+run_diffasc2png_pow_test
+run_calc_test
+run_pipeline_test
+run_denoise_gaussian_test
+run_denoise_bilateral_test
+run_denoise_spike_test
+run_denoise_preserve_test
+run_denoise_median_test
+run_downscale_test
+run_calc_first_test
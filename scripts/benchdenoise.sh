@@ -0,0 +1,36 @@
+#!/bin/bash
+# Usage: ./scripts/benchdenoise.sh [size] [window]
+# Times every denoise method on a synthetic size x size map.
+set -e
+
+SIZE="${1:-1000}"
+WINDOW="${2:-15}"
+
+BENCH_DIR="$(mktemp -d)"
+trap 'rm -rf "$BENCH_DIR"' EXIT
+
+INPUT="$BENCH_DIR/input.asc"
+
+go build -o "$BENCH_DIR/asctools" ./cmd
+
+awk -v size="$SIZE" 'BEGIN {
+    srand(42)
+    printf "ncols %d\nnrows %d\nxllcenter 0.00\nyllcenter 0.00\ncellsize 1.00\nnodata_value -9999\n", size, size
+    for (row = 0; row < size; row++) {
+        line = ""
+        for (col = 0; col < size; col++) {
+            value = 100 + row * 0.05 + col * 0.03 + (rand() - 0.5) * 2
+            if (rand() < 0.01) value += 50
+            if (rand() < 0.01) value = -9999
+            line = line (col > 0 ? " " : "") sprintf("%.2f", value)
+        }
+        print line
+    }
+}' > "$INPUT"
+
+for METHOD in median gaussian bilateral spike; do
+    START=$(date +%s%N)
+    "$BENCH_DIR/asctools" denoise -method "$METHOD" -window "$WINDOW" < "$INPUT" > /dev/null
+    END=$(date +%s%N)
+    echo "$METHOD ${SIZE}x${SIZE} window $WINDOW: $(( (END - START) / 1000000 )) ms"
+done